@@ -0,0 +1,401 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mekapi/trc/eztrc"
+	"net/http"
+	"strconv"
+	"time"
+
+	"zenith/block"
+	"zenith/store"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+)
+
+// AdminHandler serves operator requests that manage chains, validators, and
+// auctions directly in the store. It has no authentication of its own, so it
+// should only be served behind an authenticated listener, like the debug
+// server with auth. See debug.WithHandler.
+type AdminHandler struct {
+	router  *mux.Router
+	store   store.Store
+	manager *block.ServiceManager // optional, refreshed after chain changes
+	logger  log.Logger
+}
+
+func NewAdminHandler(store store.Store, manager *block.ServiceManager, logger log.Logger) *AdminHandler {
+	s := &AdminHandler{
+		router:  mux.NewRouter(),
+		store:   store,
+		manager: manager,
+		logger:  logger,
+	}
+
+	s.router.Methods("GET").Path("/admin/chains").HandlerFunc(s.handleListChains)
+	s.router.Methods("GET").Path("/admin/chains/{chain_id}").HandlerFunc(s.handleGetChain)
+	s.router.Methods("PUT").Path("/admin/chains/{chain_id}").HandlerFunc(s.handlePutChain)
+	s.router.Methods("PATCH").Path("/admin/chains/{chain_id}").HandlerFunc(s.handlePatchChain)
+	s.router.Methods("GET").Path("/admin/chains/{chain_id}/validators").HandlerFunc(s.handleListValidators)
+	s.router.Methods("DELETE").Path("/admin/chains/{chain_id}/validators/{address}").HandlerFunc(s.handleDeleteValidator)
+	s.router.Methods("POST").Path("/admin/chains/{chain_id}/auctions/reset").HandlerFunc(s.handleResetAuctions)
+	s.router.Methods("DELETE").Path("/admin/chains/{chain_id}/auctions").HandlerFunc(s.handleDeleteAuctions)
+
+	s.router.Use(
+		// observability middlewares are in the debug handler
+		panicRecoveryMiddleware(s.logger),
+		// the handler executes here
+	)
+
+	return s
+}
+
+func (s *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+//
+//
+//
+
+// AdminChain is the admin API representation of a store.Chain.
+type AdminChain struct {
+	ID                    string             `json:"id"`
+	Network               string             `json:"network"`
+	PaymentDenom          string             `json:"payment_denom"`
+	PaymentDenomRates     map[string]float64 `json:"payment_denom_rates,omitempty"`
+	MekatekPaymentAddress string             `json:"mekatek_payment_address"`
+	Timeout               string             `json:"timeout"`               // e.g. "5s"
+	HedgeDelay            string             `json:"hedge_delay,omitempty"` // e.g. "50ms", empty disables hedging
+	NodeURIs              []string           `json:"node_uris"`
+	BidSelection          string             `json:"bid_selection"`
+	AllocationPolicy      string             `json:"allocation_policy"`
+	Allocation            *float64           `json:"allocation,omitempty"` // null for the default
+	ValidatorAllocations  map[string]float64 `json:"validator_allocations,omitempty"`
+	AllocationTolerance   *float64           `json:"allocation_tolerance,omitempty"` // null for the default
+	RetentionTime         string             `json:"retention_time,omitempty"`       // e.g. "720h", empty keeps auctions forever
+	CreatedAt             time.Time          `json:"created_at,omitempty"`
+	UpdatedAt             time.Time          `json:"updated_at,omitempty"`
+}
+
+// newAdminChain copies the chain, so that decoding into the result, as a patch
+// does, doesn't change the store's copy.
+func newAdminChain(c *store.Chain) *AdminChain {
+	var retentionTime, hedgeDelay string
+	if c.RetentionTime > 0 {
+		retentionTime = c.RetentionTime.String()
+	}
+	if c.HedgeDelay > 0 {
+		hedgeDelay = c.HedgeDelay.String()
+	}
+	return &AdminChain{
+		ID:                    c.ID,
+		Network:               c.Network,
+		PaymentDenom:          c.PaymentDenom,
+		PaymentDenomRates:     copyFloats(c.PaymentDenomRates),
+		MekatekPaymentAddress: c.MekatekPaymentAddress,
+		Timeout:               c.Timeout.String(),
+		HedgeDelay:            hedgeDelay,
+		NodeURIs:              append([]string(nil), c.NodeURIs...),
+		BidSelection:          string(c.BidSelection),
+		AllocationPolicy:      string(c.AllocationPolicy),
+		Allocation:            copyFloat(c.Allocation),
+		ValidatorAllocations:  copyFloats(c.ValidatorAllocations),
+		AllocationTolerance:   copyFloat(c.AllocationTolerance),
+		RetentionTime:         retentionTime,
+		CreatedAt:             c.CreatedAt,
+		UpdatedAt:             c.UpdatedAt,
+	}
+}
+
+func copyFloat(f *float64) *float64 {
+	if f == nil {
+		return nil
+	}
+	return store.Float64(*f)
+}
+
+func copyFloats(m map[string]float64) map[string]float64 {
+	if m == nil {
+		return nil
+	}
+	cp := make(map[string]float64, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
+
+func (c *AdminChain) validate() error {
+	_, timeoutErr := time.ParseDuration(c.Timeout)
+	_, hedgeErr := parseOptionalDuration(c.HedgeDelay)
+	_, retentionErr := parseOptionalDuration(c.RetentionTime)
+
+	var merr multiError
+	merr.addIf(timeoutErr != nil, fmt.Errorf("invalid timeout %q", c.Timeout))
+	merr.addIf(hedgeErr != nil, fmt.Errorf("invalid hedge delay %q", c.HedgeDelay))
+	merr.addIf(retentionErr != nil, fmt.Errorf("invalid retention time %q", c.RetentionTime))
+	if err := merr.yield(); err != nil {
+		return err
+	}
+
+	return c.storeChain().Validate()
+}
+
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func (c *AdminChain) storeChain() *store.Chain {
+	timeout, _ := time.ParseDuration(c.Timeout)                // validated
+	hedgeDelay, _ := parseOptionalDuration(c.HedgeDelay)       // validated
+	retentionTime, _ := parseOptionalDuration(c.RetentionTime) // validated
+	return &store.Chain{
+		ID:                    c.ID,
+		Network:               c.Network,
+		PaymentDenom:          c.PaymentDenom,
+		PaymentDenomRates:     c.PaymentDenomRates,
+		MekatekPaymentAddress: c.MekatekPaymentAddress,
+		Timeout:               timeout,
+		HedgeDelay:            hedgeDelay,
+		NodeURIs:              c.NodeURIs,
+		BidSelection:          store.ParseBidSelection(c.BidSelection),
+		AllocationPolicy:      store.ParseAllocationPolicy(c.AllocationPolicy),
+		Allocation:            c.Allocation,
+		ValidatorAllocations:  c.ValidatorAllocations,
+		AllocationTolerance:   c.AllocationTolerance,
+		RetentionTime:         retentionTime,
+	}
+}
+
+// AdminValidator is the admin API representation of a store.Validator.
+type AdminValidator struct {
+	ChainID        string    `json:"chain_id"`
+	Address        string    `json:"address"`
+	Moniker        string    `json:"moniker,omitempty"`
+	PubKeyType     string    `json:"pub_key_type"`
+	PubKeyBytes    []byte    `json:"pub_key_bytes"`
+	PaymentAddress string    `json:"payment_address"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// AdminAuctionsResult is the response to requests that change auctions.
+type AdminAuctionsResult struct {
+	ChainID   string `json:"chain_id"`
+	MinHeight int64  `json:"min_height"`
+	Auctions  int64  `json:"auctions"` // affected
+}
+
+//
+//
+//
+
+func (s *AdminHandler) handleListChains(w http.ResponseWriter, r *http.Request) {
+	chains, err := s.store.ListChains(r.Context())
+	if err != nil {
+		respondError(w, r, fmt.Errorf("list chains: %w", err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	resp := make([]*AdminChain, len(chains))
+	for i, c := range chains {
+		resp[i] = newAdminChain(c)
+	}
+
+	respondOK(w, r, resp)
+}
+
+func (s *AdminHandler) handleGetChain(w http.ResponseWriter, r *http.Request) {
+	chainID := mux.Vars(r)["chain_id"]
+
+	c, err := s.store.SelectChain(r.Context(), chainID)
+	if err != nil {
+		respondError(w, r, fmt.Errorf("get chain %s: %w", chainID, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	respondOK(w, r, newAdminChain(c))
+}
+
+// handlePutChain creates or replaces the chain with the request body.
+func (s *AdminHandler) handlePutChain(w http.ResponseWriter, r *http.Request) {
+	s.saveChain(w, r, &AdminChain{})
+}
+
+// handlePatchChain updates the fields of an existing chain that are in the
+// request body, and leaves the rest as they are. Maps are replaced as a whole.
+func (s *AdminHandler) handlePatchChain(w http.ResponseWriter, r *http.Request) {
+	chainID := mux.Vars(r)["chain_id"]
+
+	c, err := s.store.SelectChain(r.Context(), chainID)
+	if err != nil {
+		respondError(w, r, fmt.Errorf("get chain %s: %w", chainID, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	s.saveChain(w, r, newAdminChain(c))
+}
+
+func (s *AdminHandler) saveChain(w http.ResponseWriter, r *http.Request, c *AdminChain) {
+	ctx := r.Context()
+	chainID := mux.Vars(r)["chain_id"]
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		respondError(w, r, fmt.Errorf("read chain: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	// Decoding into a map adds to its keys, so maps in the body replace the
+	// chain's instead, and keys can be removed by leaving them out.
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		respondError(w, r, fmt.Errorf("decode chain: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+	if _, ok := fields["payment_denom_rates"]; ok {
+		c.PaymentDenomRates = nil
+	}
+	if _, ok := fields["validator_allocations"]; ok {
+		c.ValidatorAllocations = nil
+	}
+
+	if err := json.Unmarshal(body, c); err != nil {
+		respondError(w, r, fmt.Errorf("decode chain: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	if c.ID == "" {
+		c.ID = chainID
+	}
+
+	if c.ID != chainID {
+		respondError(w, r, fmt.Errorf("chain ID %q doesn't match path %q", c.ID, chainID), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	if err := c.validate(); err != nil {
+		respondError(w, r, fmt.Errorf("chain invalid: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	sc := c.storeChain()
+	if err := s.store.UpsertChain(ctx, sc); err != nil {
+		respondError(w, r, fmt.Errorf("upsert chain %s: %w", chainID, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	eztrc.Tracef(ctx, "saved chain %s", chainID)
+	level.Info(s.logger).Log("msg", "admin saved chain", "chain_id", chainID)
+
+	// The services are refreshed periodically anyway, so a failure here
+	// isn't a failure of the request.
+	if s.manager != nil {
+		if err := s.manager.Refresh(ctx); err != nil {
+			eztrc.Errorf(ctx, "refresh services: %v", err)
+			level.Warn(s.logger).Log("msg", "refresh services after chain change failed", "chain_id", chainID, "err", err)
+		}
+	}
+
+	respondOK(w, r, newAdminChain(sc))
+}
+
+func (s *AdminHandler) handleListValidators(w http.ResponseWriter, r *http.Request) {
+	chainID := mux.Vars(r)["chain_id"]
+
+	validators, err := s.store.ListValidators(r.Context(), chainID)
+	if err != nil {
+		respondError(w, r, fmt.Errorf("list validators on %s: %w", chainID, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	resp := make([]*AdminValidator, len(validators))
+	for i, v := range validators {
+		resp[i] = &AdminValidator{
+			ChainID:        v.ChainID,
+			Address:        v.Address,
+			Moniker:        v.Moniker,
+			PubKeyType:     v.PubKeyType,
+			PubKeyBytes:    v.PubKeyBytes,
+			PaymentAddress: v.PaymentAddress,
+			CreatedAt:      v.CreatedAt,
+			UpdatedAt:      v.UpdatedAt,
+		}
+	}
+
+	respondOK(w, r, resp)
+}
+
+func (s *AdminHandler) handleDeleteValidator(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx     = r.Context()
+		chainID = mux.Vars(r)["chain_id"]
+		address = mux.Vars(r)["address"]
+	)
+
+	if err := s.store.DeleteValidator(ctx, chainID, address); err != nil {
+		respondError(w, r, fmt.Errorf("delete validator %s on %s: %w", address, chainID, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	eztrc.Tracef(ctx, "deleted validator %s on %s", address, chainID)
+	level.Info(s.logger).Log("msg", "admin deleted validator", "chain_id", chainID, "address", address)
+
+	respondOK(w, r, struct{}{})
+}
+
+func (s *AdminHandler) handleResetAuctions(w http.ResponseWriter, r *http.Request) {
+	s.changeAuctions(w, r, "reset", s.store.ResetAuctions)
+}
+
+func (s *AdminHandler) handleDeleteAuctions(w http.ResponseWriter, r *http.Request) {
+	s.changeAuctions(w, r, "delete", s.store.DeleteAuctions)
+}
+
+// changeAuctions applies the change to the auctions of the chain from the
+// min_height query param, or from the first height if it's not given.
+func (s *AdminHandler) changeAuctions(w http.ResponseWriter, r *http.Request, verb string, change func(ctx context.Context, chainID string, minHeight int64) (int64, error)) {
+	var (
+		ctx     = r.Context()
+		chainID = mux.Vars(r)["chain_id"]
+	)
+
+	var minHeight int64
+	if str := r.URL.Query().Get("min_height"); str != "" {
+		h, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || h < 0 {
+			respondError(w, r, fmt.Errorf("invalid min height %q", str), http.StatusBadRequest, s.logger)
+			return
+		}
+		minHeight = h
+	}
+
+	if _, err := s.store.SelectChain(ctx, chainID); err != nil {
+		respondError(w, r, fmt.Errorf("get chain %s: %w", chainID, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	n, err := change(ctx, chainID, minHeight)
+	if err != nil {
+		respondError(w, r, fmt.Errorf("%s auctions on %s from %d: %w", verb, chainID, minHeight, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	eztrc.Tracef(ctx, "%s %d auctions on %s from %d", verb, n, chainID, minHeight)
+	level.Info(s.logger).Log("msg", "admin "+verb+" auctions", "chain_id", chainID, "min_height", minHeight, "auctions", n)
+
+	respondOK(w, r, AdminAuctionsResult{
+		ChainID:   chainID,
+		MinHeight: minHeight,
+		Auctions:  n,
+	})
+}
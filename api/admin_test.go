@@ -0,0 +1,126 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"zenith/api"
+	"zenith/store/memstore"
+	"zenith/store/storetest"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAdminHandler(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		store   = memstore.NewStore()
+		handler = api.NewAdminHandler(store, nil, log.NewNopLogger())
+	)
+
+	do := func(method, path, body string, wantCode int, resp any) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if want, have := wantCode, rec.Code; want != have {
+			t.Fatalf("%s %s: want %d, have %d (%s)", method, path, want, have, strings.TrimSpace(rec.Body.String()))
+		}
+		if resp != nil {
+			if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+				t.Fatalf("%s %s: decode response: %v", method, path, err)
+			}
+		}
+	}
+
+	t.Run("chains", func(t *testing.T) {
+		do("PUT", "/admin/chains/new-chain", `{"network":"osmosis"}`, http.StatusBadRequest, nil)
+		do("PATCH", "/admin/chains/new-chain", `{"node_uris":["http://a:26657"]}`, http.StatusNotFound, nil)
+
+		var created api.AdminChain
+		do("PUT", "/admin/chains/new-chain", `{
+			"network": "osmosis",
+			"payment_denom": "uosmo",
+			"mekatek_payment_address": "osmo1abc",
+			"timeout": "5s",
+			"node_uris": ["http://a:26657"],
+			"allocation": 0.9,
+			"payment_denom_rates": {"uion": 2, "uatom": 3}
+		}`, http.StatusOK, &created)
+
+		var patched api.AdminChain
+		do("PATCH", "/admin/chains/new-chain", `{"node_uris":["http://b:26657"]}`, http.StatusOK, &patched)
+		if want, have := []string{"http://b:26657"}, patched.NodeURIs; !cmp.Equal(want, have) {
+			t.Errorf("patched node URIs: want %v, have %v", want, have)
+		}
+		if want, have := created.MekatekPaymentAddress, patched.MekatekPaymentAddress; want != have {
+			t.Errorf("patched payment address: want %q, have %q", want, have)
+		}
+
+		if want, have := created.PaymentDenomRates, patched.PaymentDenomRates; !cmp.Equal(want, have) {
+			t.Errorf("patched payment denom rates: want %v, have %v", want, have)
+		}
+
+		// Maps in a patch replace the chain's, rather than being merged.
+		var replaced, cleared api.AdminChain
+		do("PATCH", "/admin/chains/new-chain", `{"payment_denom_rates":{"uion":4}}`, http.StatusOK, &replaced)
+		if want, have := map[string]float64{"uion": 4}, replaced.PaymentDenomRates; !cmp.Equal(want, have) {
+			t.Errorf("replaced payment denom rates: want %v, have %v", want, have)
+		}
+		do("PATCH", "/admin/chains/new-chain", `{"payment_denom_rates":{}}`, http.StatusOK, &cleared)
+		if want, have := 0, len(cleared.PaymentDenomRates); want != have {
+			t.Errorf("cleared payment denom rates: want %d, have %d", want, have)
+		}
+
+		c, err := store.SelectChain(ctx, "new-chain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := []string{"http://b:26657"}, c.NodeURIs; !cmp.Equal(want, have) {
+			t.Errorf("stored node URIs: want %v, have %v", want, have)
+		}
+
+		var chains []api.AdminChain
+		do("GET", "/admin/chains", "", http.StatusOK, &chains)
+		if want, have := 1, len(chains); want != have {
+			t.Errorf("chain count: want %d, have %d", want, have)
+		}
+	})
+
+	t.Run("validators and auctions", func(t *testing.T) {
+		chain := storetest.NewChain(t, store)
+		validator := storetest.NewValidator(t, store, chain)
+		auction := storetest.NewAuction(t, store, chain, 10, validator)
+		bid := storetest.NewBid(t, store, chain, auction)
+
+		var validators []api.AdminValidator
+		do("GET", "/admin/chains/"+chain.ID+"/validators", "", http.StatusOK, &validators)
+		if want, have := 1, len(validators); want != have {
+			t.Fatalf("validator count: want %d, have %d", want, have)
+		}
+
+		do("DELETE", "/admin/chains/"+chain.ID+"/validators/"+validator.Address, "", http.StatusOK, nil)
+		do("DELETE", "/admin/chains/"+chain.ID+"/validators/"+validator.Address, "", http.StatusNotFound, nil)
+
+		var result api.AdminAuctionsResult
+		do("POST", "/admin/chains/"+chain.ID+"/auctions/reset?min_height=11", "", http.StatusOK, &result)
+		if want, have := int64(0), result.Auctions; want != have {
+			t.Errorf("reset auctions: want %d, have %d", want, have)
+		}
+
+		do("DELETE", "/admin/chains/"+chain.ID+"/auctions?min_height=x", "", http.StatusBadRequest, nil)
+		do("DELETE", "/admin/chains/"+chain.ID+"/auctions", "", http.StatusOK, &result)
+		if want, have := int64(1), result.Auctions; want != have {
+			t.Errorf("deleted auctions: want %d, have %d", want, have)
+		}
+
+		if _, err := store.SelectBid(ctx, bid.ID.String()); err == nil {
+			t.Errorf("bid of deleted auction still exists")
+		}
+	})
+}
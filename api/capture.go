@@ -0,0 +1,326 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mekapi/trc/eztrc"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"zenith/block"
+	"zenith/chain"
+	"zenith/store"
+	"zenith/store/memstore"
+
+	"github.com/meka-dev/mekatek-go/mekabuild"
+)
+
+// BuildCapture is a build request as it was received, the chain state that
+// was read to serve it, and the response that was sent.
+type BuildCapture struct {
+	Time     time.Time                     `json:"time"`
+	Path     string                        `json:"path"` // e.g. "/v1/build"
+	Request  mekabuild.BuildBlockRequest   `json:"request"`
+	Chain    *chain.Recording              `json:"chain"`
+	Response *mekabuild.BuildBlockResponse `json:"response,omitempty"`
+	Error    string                        `json:"error,omitempty"`
+}
+
+// CaptureSink receives build captures. It's called after the response has
+// been written, but before the handler returns.
+type CaptureSink interface {
+	WriteCapture(c *BuildCapture) error
+}
+
+// HandlerOption configures optional parts of a Handler.
+type HandlerOption func(*Handler)
+
+// WithCaptureSink makes the handler capture every build request to the sink.
+// The chains of the services should be wrapped with chain.WithRecording, or
+// the captures won't include any chain state.
+func WithCaptureSink(sink CaptureSink) HandlerOption {
+	return func(h *Handler) { h.captures = sink }
+}
+
+// maybeCapture returns a context that records chain state, and a func to write
+// the capture once the request has been served. If capture isn't enabled, the
+// context is returned as is, and the func does nothing.
+func (s *Handler) maybeCapture(ctx context.Context, path string, req *mekabuild.BuildBlockRequest) (context.Context, func(*mekabuild.BuildBlockResponse, error)) {
+	if s.captures == nil {
+		return ctx, func(*mekabuild.BuildBlockResponse, error) {}
+	}
+
+	ctx, rec := chain.NewRecordingContext(ctx)
+	begin := time.Now().UTC()
+
+	return ctx, func(resp *mekabuild.BuildBlockResponse, err error) {
+		c := &BuildCapture{
+			Time:     begin,
+			Path:     path,
+			Request:  *req,
+			Chain:    rec,
+			Response: resp,
+		}
+		if err != nil {
+			c.Error = err.Error()
+		}
+		if err := s.captures.WriteCapture(c); err != nil {
+			eztrc.Errorf(ctx, "write capture: %v", err)
+		}
+	}
+}
+
+// SameResponse returns true if the capture has a response with the same txs
+// and validator payment.
+func (c *BuildCapture) SameResponse(resp *mekabuild.BuildBlockResponse) bool {
+	if c.Response == nil || resp == nil {
+		return c.Response == resp
+	}
+	if c.Response.ValidatorPayment != resp.ValidatorPayment || len(c.Response.Txs) != len(resp.Txs) {
+		return false
+	}
+	for i := range c.Response.Txs {
+		if !bytes.Equal(c.Response.Txs[i], resp.Txs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+//
+//
+//
+
+// ReadBuildCaptures reads captures written by a FileCaptureSink.
+func ReadBuildCaptures(r io.Reader) ([]*BuildCapture, error) {
+	var (
+		captures []*BuildCapture
+		dec      = json.NewDecoder(bufio.NewReader(r))
+	)
+	for {
+		var c BuildCapture
+		switch err := dec.Decode(&c); {
+		case err == io.EOF:
+			return captures, nil
+		case err != nil:
+			return nil, fmt.Errorf("decode capture %d: %w", len(captures)+1, err)
+		}
+		captures = append(captures, &c)
+	}
+}
+
+// ReplayBuildCapture feeds a captured build request through a CoreService that
+// serves the captured chain state, and returns its response. The codec decodes
+// txs and finds their payments, and can be a chain without working nodes.
+//
+// The chain, validator, auction, and bids are copied from the source store to
+// a scratch store, where the auction is reopened, and the bids for the height
+// are pending again. The source store isn't changed.
+func ReplayBuildCapture(ctx context.Context, c *BuildCapture, codec chain.Chain, src store.Store, options ...block.CoreServiceOption) (*mekabuild.BuildBlockResponse, error) {
+	if c.Chain == nil {
+		return nil, fmt.Errorf("capture has no chain state")
+	}
+
+	req := c.Request
+
+	st, err := newReplayStore(ctx, src, req.ChainID, req.ValidatorAddress, req.Height)
+	if err != nil {
+		return nil, fmt.Errorf("create replay store: %w", err)
+	}
+
+	var (
+		rc = c.Chain.Chain(req.ChainID, codec)
+		sv = block.NewCoreService(rc, st, options...)
+	)
+
+	build := sv.BuildV1
+	if c.Path == "/v0/build" {
+		build = sv.Build
+	}
+
+	txs, payment, err := build(ctx, req.Height, req.ValidatorAddress, req.MaxBytes, req.MaxGas, req.Txs, req.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("build block for %s/%d: %w", req.ChainID, req.Height, err)
+	}
+
+	return &mekabuild.BuildBlockResponse{Txs: txs, ValidatorPayment: payment}, nil
+}
+
+func newReplayStore(ctx context.Context, src store.Store, chainID, validatorAddr string, height int64) (store.Store, error) {
+	dst := memstore.NewStore()
+
+	ch, err := src.SelectChain(ctx, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("get chain: %w", err)
+	}
+	if err := dst.UpsertChain(ctx, ch); err != nil {
+		return nil, fmt.Errorf("copy chain: %w", err)
+	}
+
+	v, err := src.SelectValidator(ctx, chainID, validatorAddr)
+	switch {
+	case err == nil:
+		if err := dst.UpsertValidator(ctx, v); err != nil {
+			return nil, fmt.Errorf("copy validator: %w", err)
+		}
+	case errors.Is(err, store.ErrNotFound):
+		// the build request will fail the same way it did originally
+	default:
+		return nil, fmt.Errorf("get validator: %w", err)
+	}
+
+	a, err := src.SelectAuction(ctx, chainID, height)
+	switch {
+	case err == nil:
+		a := *a
+		a.FinishedAt = time.Time{}
+		if err := dst.UpsertAuction(ctx, &a); err != nil {
+			return nil, fmt.Errorf("copy auction: %w", err)
+		}
+	case errors.Is(err, store.ErrNotFound):
+		// the build request creates it
+	default:
+		return nil, fmt.Errorf("get auction: %w", err)
+	}
+
+	bids, err := src.ListBids(ctx, chainID, height)
+	if err != nil {
+		return nil, fmt.Errorf("list bids: %w", err)
+	}
+	for _, b := range bids {
+		b := *b
+		if b.Height == height {
+			b.State, b.RejectionReason = store.BidStatePending, ""
+		}
+		if err := dst.InsertBid(ctx, &b); err != nil {
+			return nil, fmt.Errorf("copy bid %s: %w", b.ID, err)
+		}
+	}
+
+	return dst, nil
+}
+
+//
+//
+//
+
+// FileCaptureSink writes captures to JSONL files in a directory, starting a
+// new file when the current one would grow past a size, and removing the
+// oldest files beyond a count.
+type FileCaptureSink struct {
+	dir      string
+	maxBytes int64
+	maxFiles int
+
+	mtx  sync.Mutex
+	f    *os.File
+	size int64
+}
+
+var _ CaptureSink = (*FileCaptureSink)(nil)
+
+const captureFilePrefix = "build-captures-"
+
+// NewFileCaptureSink creates the directory if it doesn't exist. A maxFiles of
+// zero keeps every file.
+func NewFileCaptureSink(dir string, maxBytes int64, maxFiles int) (*FileCaptureSink, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("max bytes must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create capture dir: %w", err)
+	}
+	return &FileCaptureSink{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxFiles: maxFiles,
+	}, nil
+}
+
+func (s *FileCaptureSink) WriteCapture(c *BuildCapture) error {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("encode capture: %w", err)
+	}
+	buf = append(buf, '\n')
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.f == nil || (s.size > 0 && s.size+int64(len(buf)) > s.maxBytes) {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("rotate capture file: %w", err)
+		}
+	}
+
+	n, err := s.f.Write(buf)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write capture: %w", err)
+	}
+
+	return nil
+}
+
+// Files returns the capture files in the directory, oldest first.
+func (s *FileCaptureSink) Files() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, captureFilePrefix+"*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files) // names sort by creation time
+	return files, nil
+}
+
+func (s *FileCaptureSink) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+func (s *FileCaptureSink) rotate() error {
+	if s.f != nil {
+		if err := s.f.Close(); err != nil {
+			return fmt.Errorf("close: %w", err)
+		}
+		s.f = nil
+	}
+
+	name := filepath.Join(s.dir, captureFilePrefix+time.Now().UTC().Format("20060102T150405.000000000")+".jsonl")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	s.f, s.size = f, 0
+
+	if s.maxFiles <= 0 {
+		return nil
+	}
+
+	files, err := s.Files()
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	for len(files) > s.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return fmt.Errorf("remove: %w", err)
+		}
+		files = files[1:]
+	}
+
+	return nil
+}
@@ -36,6 +36,10 @@ var (
 	ErrNoPaymentAddress   = errors.New("no payment address")
 	ErrNoChallengeID      = errors.New("no challenge ID")
 	ErrNoSignature        = errors.New("no signature")
+	ErrNoBidID            = errors.New("no bid ID")
+	ErrNoPubKey           = errors.New("no pub key")
+	ErrNoTxs              = errors.New("no txs")
+	ErrNoSearcherID       = errors.New("no searcher ID")
 )
 
 type Handler struct {
@@ -50,17 +54,25 @@ type Handler struct {
 	// who won't be sending that information. This can be removed once we no
 	// longer support the "v0" registration flow.
 	//
+	// Bid status lookups also use the store directly, for the same reason.
+	//
 	// TODO: remove
 	store store.Store
+
+	captures CaptureSink  // optional
+	limiter  *RateLimiter // optional
 }
 
-func NewHandler(store store.Store, manager *block.ServiceManager, logger log.Logger) *Handler {
+func NewHandler(store store.Store, manager *block.ServiceManager, logger log.Logger, options ...HandlerOption) *Handler {
 	s := &Handler{
 		router:  mux.NewRouter(),
 		store:   store, // TODO: remove
 		manager: manager,
 		logger:  logger,
 	}
+	for _, option := range options {
+		option(s)
+	}
 
 	s.router.Path("/").Handler(redirectHandler)
 
@@ -69,15 +81,21 @@ func NewHandler(store store.Store, manager *block.ServiceManager, logger log.Log
 
 	s.router.Methods("GET").Path("/v0/auction").HandlerFunc(s.handleGetAuctionV0)
 	s.router.Methods("POST").Path("/v0/bid").HandlerFunc(s.handlePostBidV0)
+	s.router.Methods("GET").Path("/v0/bid/{id}").HandlerFunc(s.handleGetBidV0)
+	s.router.Methods("POST").Path("/v0/bid/cancel").HandlerFunc(s.handlePostBidCancelV0)
+	s.router.Methods("POST").Path("/v0/bid/replace").HandlerFunc(s.handlePostBidReplaceV0)
 	s.router.Methods("POST").Path("/v0/register").HandlerFunc(s.handlePostRegisterV0)
+	s.router.Methods("POST").Path("/v0/searcher/register").HandlerFunc(s.handlePostSearcherRegisterV0)
 	s.router.Methods("POST").Path("/v0/build").HandlerFunc(s.handlePostBuildV0)
 
 	s.router.Methods("POST").Path("/v1/build").HandlerFunc(s.handlePostBuildV1) // same API, different behavior
+	s.router.Methods("POST").Path("/v1/preview").HandlerFunc(s.handlePostPreviewV1)
 
 	s.router.Use(
 		mekabuild.GunzipRequestMiddleware,
 		debug.TracingMiddleware,
 		debug.MetricsMiddleware,
+		rateLimitMiddleware(s.limiter, s.logger),
 		panicRecoveryMiddleware(s.logger), // should be after observability middlewares
 		// the handler executes here
 	)
@@ -227,25 +245,148 @@ func (s *Handler) handlePostRegisterV0(w http.ResponseWriter, r *http.Request) {
 //
 //
 
+// searcherRegisterRequest registers a searcher pub key, in two steps like a
+// validator registration: the apply request gets a challenge, and the register
+// request returns it signed over block.SearcherChallengeSignBytes.
+type searcherRegisterRequest struct {
+	ChainID string `json:"chain_id"`
+
+	// Initial apply request.
+	PubKeyType string `json:"pub_key_type,omitempty"`
+	PubKey     []byte `json:"pub_key,omitempty"`
+
+	// Second register request.
+	ChallengeID string `json:"challenge_id,omitempty"`
+	Signature   []byte `json:"signature,omitempty"`
+}
+
+func (req *searcherRegisterRequest) isApplyRequest() bool { return req.ChallengeID == "" }
+
+func (req *searcherRegisterRequest) validate() error {
+	var merr multiError
+	merr.addIf(req.ChainID == "", ErrNoChainID)
+	switch {
+	case req.isApplyRequest():
+		merr.addIf(len(req.PubKey) == 0, ErrNoPubKey)
+	default:
+		merr.addIf(len(req.Signature) == 0, ErrNoSignature)
+	}
+	return merr.yield()
+}
+
+type searcherRegisterResponse struct {
+	// Initial apply response.
+	ChallengeID string `json:"challenge_id,omitempty"`
+	Challenge   []byte `json:"challenge,omitempty"`
+
+	// Second register response.
+	SearcherID string `json:"searcher_id,omitempty"`
+	Address    string `json:"address,omitempty"`
+}
+
+func (s *Handler) handlePostSearcherRegisterV0(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req searcherRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, fmt.Errorf("decode searcher register request: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, r, fmt.Errorf("request invalid: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	eztrc.Tracef(ctx, "chain ID %q", req.ChainID)
+
+	sv, ok := s.manager.GetService(req.ChainID)
+	if !ok {
+		respondError(w, r, fmt.Errorf("%s: %w", req.ChainID, ErrUnknownChainID), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	var resp *searcherRegisterResponse
+	switch {
+	case req.isApplyRequest():
+		c, err := sv.ApplySearcher(ctx, req.PubKeyType, req.PubKey)
+		if err != nil {
+			respondError(w, r, fmt.Errorf("apply: %w", err), http.StatusInternalServerError, s.logger)
+			return
+		}
+		eztrc.Tracef(ctx, "issuing challenge ID %s", c.ID)
+		resp = &searcherRegisterResponse{ChallengeID: c.ID.String(), Challenge: c.Challenge}
+
+	default:
+		eztrc.Tracef(ctx, "register: challenge ID %s", req.ChallengeID)
+		searcher, err := sv.RegisterSearcher(ctx, req.ChallengeID, req.Signature)
+		if err != nil {
+			respondError(w, r, fmt.Errorf("register: %w", err), http.StatusInternalServerError, s.logger)
+			return
+		}
+		eztrc.Tracef(ctx, "registered searcher ID %s", searcher.ID)
+		resp = &searcherRegisterResponse{SearcherID: searcher.ID.String(), Address: searcher.Address}
+	}
+
+	respondOK(w, r, resp)
+}
+
+//
+//
+//
+
+// bidRequest places a bid. Registered searchers also give their searcher ID,
+// and a signature over block.BidSignBytes.
 type bidRequest struct {
-	ChainID string   `json:"chain_id"`
-	Height  int64    `json:"height"`
-	Kind    string   `json:"kind"`
-	Txs     [][]byte `json:"txs"`
+	ChainID      string   `json:"chain_id"`
+	Height       int64    `json:"height"`
+	MaxHeight    int64    `json:"max_height,omitempty"` // last eligible height, if more than one
+	Kind         string   `json:"kind"`
+	TargetTxHash string   `json:"target_tx_hash,omitempty"` // backrun bids only
+	Txs          [][]byte `json:"txs"`
+	SearcherID   string   `json:"searcher_id,omitempty"`
+	Signature    []byte   `json:"signature,omitempty"` // with searcher ID only
 }
 
 func (req *bidRequest) validate() error {
 	var merr multiError
 	merr.addIf(req.ChainID == "", ErrNoChainID)
 	merr.addIf(req.Height <= 0, fmt.Errorf("invalid height"))
+	merr.addIf(req.MaxHeight != 0 && req.MaxHeight < req.Height, fmt.Errorf("invalid max height"))
+	merr.addIf(req.SearcherID != "" && len(req.Signature) == 0, ErrNoSignature)
+	merr.addIf(req.SearcherID == "" && len(req.Signature) > 0, ErrNoSearcherID)
 	return merr.yield()
 }
 
 type bidResponse struct {
-	ChainID  string   `json:"chain_id"`
-	Height   int64    `json:"height"`
-	Kind     string   `json:"kind"`
-	TxHashes []string `json:"tx_hashes"`
+	ID           string   `json:"id"`
+	ChainID      string   `json:"chain_id"`
+	Height       int64    `json:"height"`
+	MaxHeight    int64    `json:"max_height,omitempty"`
+	Kind         string   `json:"kind"`
+	TargetTxHash string   `json:"target_tx_hash,omitempty"`
+	TxHashes     []string `json:"tx_hashes"`
+	SearcherID   string   `json:"searcher_id,omitempty"`
+}
+
+func newBidResponse(bid *block.Bid) bidResponse {
+	return bidResponse{
+		ID:           bid.ID.String(),
+		ChainID:      bid.ChainID,
+		Height:       bid.Height,
+		MaxHeight:    bid.MaxHeight,
+		Kind:         string(bid.Kind),
+		TargetTxHash: bid.TargetTxHash,
+		TxHashes:     cryptoutil.HashTxs(bid.Txs),
+		SearcherID:   searcherID(bid),
+	}
+}
+
+func searcherID(bid *block.Bid) string {
+	if bid.SearcherID == nil {
+		return ""
+	}
+	return bid.SearcherID.String()
 }
 
 func (s *Handler) handlePostBidV0(w http.ResponseWriter, r *http.Request) {
@@ -264,6 +405,7 @@ func (s *Handler) handlePostBidV0(w http.ResponseWriter, r *http.Request) {
 
 	eztrc.Tracef(ctx, "chain ID %q", req.ChainID)
 	eztrc.Tracef(ctx, "height %d", req.Height)
+	eztrc.Tracef(ctx, "max height %d", req.MaxHeight)
 
 	sv, ok := s.manager.GetService(req.ChainID)
 	if !ok {
@@ -271,7 +413,7 @@ func (s *Handler) handlePostBidV0(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bid, err := sv.Bid(ctx, req.Height, req.Kind, req.Txs)
+	bid, err := sv.Bid(ctx, req.Height, req.MaxHeight, req.Kind, req.TargetTxHash, req.Txs, req.SearcherID, req.Signature)
 	if err != nil {
 		respondError(w, r, fmt.Errorf("bid on %s/%d: %w", req.ChainID, req.Height, err), http.StatusInternalServerError, s.logger)
 		return
@@ -279,14 +421,157 @@ func (s *Handler) handlePostBidV0(w http.ResponseWriter, r *http.Request) {
 
 	eztrc.Tracef(ctx, "bid on %s/%d, kind %s, tx count %d", req.ChainID, req.Height, req.Kind, len(req.Txs))
 
-	respondOK(w, r, bidResponse{
-		ChainID:  bid.ChainID,
-		Height:   bid.Height,
-		Kind:     string(bid.Kind),
-		TxHashes: cryptoutil.HashTxs(bid.Txs),
+	respondOK(w, r, newBidResponse(bid))
+}
+
+type bidStatusResponse struct {
+	ID              string          `json:"id"`
+	ChainID         string          `json:"chain_id"`
+	Height          int64           `json:"height"`
+	MaxHeight       int64           `json:"max_height,omitempty"`
+	Kind            string          `json:"kind"`
+	TargetTxHash    string          `json:"target_tx_hash,omitempty"`
+	TxHashes        []string        `json:"tx_hashes"`
+	State           string          `json:"state"`
+	Priority        int64           `json:"priority"`
+	Payments        []block.Payment `json:"payments"`
+	RejectionReason string          `json:"rejection_reason,omitempty"`
+	SearcherID      string          `json:"searcher_id,omitempty"`
+}
+
+func (s *Handler) handleGetBidV0(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := mux.Vars(r)["id"]
+
+	eztrc.Tracef(ctx, "bid ID %q", id)
+
+	// Bid IDs are unique across chains, and the request doesn't include a
+	// chain ID, so we look the bid up in the store directly.
+	bid, err := s.store.SelectBid(ctx, id)
+	if err != nil {
+		respondError(w, r, fmt.Errorf("get bid %s: %w", id, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	if _, ok := s.manager.GetService(bid.ChainID); !ok {
+		respondError(w, r, fmt.Errorf("%s: %w", bid.ChainID, ErrUnknownChainID), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	eztrc.Tracef(ctx, "bid on %s/%d, state %s", bid.ChainID, bid.Height, bid.State)
+
+	respondOK(w, r, bidStatusResponse{
+		ID:              bid.ID.String(),
+		ChainID:         bid.ChainID,
+		Height:          bid.Height,
+		MaxHeight:       bid.MaxHeight,
+		Kind:            string(bid.Kind),
+		TargetTxHash:    bid.TargetTxHash,
+		TxHashes:        cryptoutil.HashTxs(bid.Txs),
+		State:           string(bid.State),
+		Priority:        bid.Priority,
+		Payments:        bid.Payments,
+		RejectionReason: bid.RejectionReason,
+		SearcherID:      searcherID(bid),
 	})
 }
 
+// bidChangeRequest cancels a bid, or, if txs are given, replaces it. The
+// signature is over block.CancelBidSignBytes or block.ReplaceBidSignBytes,
+// and must be from one of the addresses paying for the bid.
+type bidChangeRequest struct {
+	ChainID    string   `json:"chain_id"`
+	BidID      string   `json:"bid_id"`
+	Txs        [][]byte `json:"txs,omitempty"` // replace only
+	PubKeyType string   `json:"pub_key_type"`
+	PubKey     []byte   `json:"pub_key"`
+	Signature  []byte   `json:"signature"`
+}
+
+func (req *bidChangeRequest) validate() error {
+	var merr multiError
+	merr.addIf(req.ChainID == "", ErrNoChainID)
+	merr.addIf(req.BidID == "", ErrNoBidID)
+	merr.addIf(len(req.PubKey) == 0, ErrNoPubKey)
+	merr.addIf(len(req.Signature) == 0, ErrNoSignature)
+	return merr.yield()
+}
+
+type bidCancelResponse struct {
+	Result string `json:"result"`
+}
+
+func (s *Handler) handlePostBidCancelV0(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req bidChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, fmt.Errorf("decode bid cancel request: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, r, fmt.Errorf("request invalid: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	eztrc.Tracef(ctx, "chain ID %q", req.ChainID)
+	eztrc.Tracef(ctx, "bid ID %q", req.BidID)
+
+	sv, ok := s.manager.GetService(req.ChainID)
+	if !ok {
+		respondError(w, r, fmt.Errorf("%s: %w", req.ChainID, ErrUnknownChainID), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	if err := sv.CancelBid(ctx, req.BidID, req.PubKeyType, req.PubKey, req.Signature); err != nil {
+		respondError(w, r, fmt.Errorf("cancel bid %s: %w", req.BidID, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	respondOK(w, r, bidCancelResponse{Result: "success"})
+}
+
+func (s *Handler) handlePostBidReplaceV0(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req bidChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, fmt.Errorf("decode bid replace request: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	if len(req.Txs) == 0 {
+		respondError(w, r, fmt.Errorf("request invalid: %w", ErrNoTxs), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		respondError(w, r, fmt.Errorf("request invalid: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	eztrc.Tracef(ctx, "chain ID %q", req.ChainID)
+	eztrc.Tracef(ctx, "bid ID %q", req.BidID)
+
+	sv, ok := s.manager.GetService(req.ChainID)
+	if !ok {
+		respondError(w, r, fmt.Errorf("%s: %w", req.ChainID, ErrUnknownChainID), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	bid, err := sv.ReplaceBid(ctx, req.BidID, req.Txs, req.PubKeyType, req.PubKey, req.Signature)
+	if err != nil {
+		respondError(w, r, fmt.Errorf("replace bid %s: %w", req.BidID, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	eztrc.Tracef(ctx, "replaced bid %s with %s", req.BidID, bid.ID)
+
+	respondOK(w, r, newBidResponse(bid))
+}
+
 //
 //
 //
@@ -380,9 +665,10 @@ func (req *auctionRequest) validate() error {
 }
 
 type auctionResponse struct {
-	ChainID  string    `json:"chain_id"`
-	Height   int64     `json:"height"`
-	Payments []payment `json:"payments"`
+	ChainID           string             `json:"chain_id"`
+	Height            int64              `json:"height"`
+	Payments          []payment          `json:"payments"`
+	PaymentDenomRates map[string]float64 `json:"payment_denom_rates,omitempty"` // other accepted denoms, to the payment denom
 }
 
 type payment struct {
@@ -435,9 +721,10 @@ func (s *Handler) handleGetAuctionV0(w http.ResponseWriter, r *http.Request) {
 	eztrc.Tracef(ctx, "auction for %s/%d, payments count %d", req.ChainID, req.Height, len(payments))
 
 	respondOK(w, r, auctionResponse{
-		ChainID:  auction.ChainID,
-		Height:   auction.Height,
-		Payments: payments,
+		ChainID:           auction.ChainID,
+		Height:            auction.Height,
+		Payments:          payments,
+		PaymentDenomRates: auction.PaymentDenomRates,
 	})
 }
 
@@ -509,15 +796,21 @@ func (s *Handler) handlePostBuildV0(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, capture := s.maybeCapture(ctx, r.URL.Path, &req)
+
 	txs, payment, err := sv.Build(ctx, req.Height, req.ValidatorAddress, req.MaxBytes, req.MaxGas, req.Txs, req.Signature)
 	if err != nil {
-		respondError(w, r, fmt.Errorf("build block for %s/%d: %w", req.ChainID, req.Height, err), http.StatusInternalServerError, s.logger)
+		err = fmt.Errorf("build block for %s/%d: %w", req.ChainID, req.Height, err)
+		respondError(w, r, err, http.StatusInternalServerError, s.logger)
+		capture(nil, err)
 		return
 	}
 
 	eztrc.Tracef(ctx, "build OK, tx count %d, validator payment %s", len(txs), payment)
 
-	respondOK(w, r, mekabuild.BuildBlockResponse{Txs: txs, ValidatorPayment: payment})
+	resp := mekabuild.BuildBlockResponse{Txs: txs, ValidatorPayment: payment}
+	respondOK(w, r, resp)
+	capture(&resp, nil)
 }
 
 func (s *Handler) handlePostBuildV1(w http.ResponseWriter, r *http.Request) {
@@ -544,15 +837,80 @@ func (s *Handler) handlePostBuildV1(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, capture := s.maybeCapture(ctx, r.URL.Path, &req)
+
 	txs, payment, err := sv.BuildV1(ctx, req.Height, req.ValidatorAddress, req.MaxBytes, req.MaxGas, req.Txs, req.Signature)
 	if err != nil {
-		respondError(w, r, fmt.Errorf("build block for %s/%d: %w", req.ChainID, req.Height, err), http.StatusInternalServerError, s.logger)
+		err = fmt.Errorf("build block for %s/%d: %w", req.ChainID, req.Height, err)
+		respondError(w, r, err, http.StatusInternalServerError, s.logger)
+		capture(nil, err)
 		return
 	}
 
 	eztrc.Tracef(ctx, "build OK, tx count %d, validator payment %s", len(txs), payment)
 
-	respondOK(w, r, mekabuild.BuildBlockResponse{Txs: txs, ValidatorPayment: payment})
+	resp := mekabuild.BuildBlockResponse{Txs: txs, ValidatorPayment: payment}
+	respondOK(w, r, resp)
+	capture(&resp, nil)
+}
+
+// previewRequest has the same fields as a build request, but is signed over
+// block.PreviewSignBytes.
+type previewRequest = mekabuild.BuildBlockRequest
+
+type previewResponse struct {
+	ChainID                 string          `json:"chain_id"`
+	Height                  int64           `json:"height"`
+	ValidatorAddress        string          `json:"validator_address"`
+	ValidatorPaymentAddress string          `json:"validator_payment_address"`
+	ValidatorPayment        string          `json:"validator_payment"`
+	UsedBytes               int64           `json:"used_bytes"`
+	UsedGas                 int64           `json:"used_gas"`
+	Txs                     []store.BuildTx `json:"txs"`
+}
+
+func (s *Handler) handlePostPreviewV1(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req previewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, fmt.Errorf("decode preview request: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	if err := validateBuildBlockRequest(&req); err != nil {
+		respondError(w, r, fmt.Errorf("invalid request: %w", err), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	eztrc.Tracef(ctx, "chain ID %s", req.ChainID)
+	eztrc.Tracef(ctx, "height %d", req.Height)
+	eztrc.Tracef(ctx, "validator address %s", req.ValidatorAddress)
+
+	sv, ok := s.manager.GetService(req.ChainID)
+	if !ok {
+		respondError(w, r, fmt.Errorf("%s: %w", req.ChainID, ErrUnknownChainID), http.StatusBadRequest, s.logger)
+		return
+	}
+
+	p, err := sv.Preview(ctx, req.Height, req.ValidatorAddress, req.MaxBytes, req.MaxGas, req.Txs, req.Signature)
+	if err != nil {
+		respondError(w, r, fmt.Errorf("preview block for %s/%d: %w", req.ChainID, req.Height, err), http.StatusInternalServerError, s.logger)
+		return
+	}
+
+	eztrc.Tracef(ctx, "preview OK, tx count %d, validator payment %s", len(p.Txs), p.ValidatorCoins)
+
+	respondOK(w, r, previewResponse{
+		ChainID:                 p.ChainID,
+		Height:                  p.Height,
+		ValidatorAddress:        p.ValidatorAddress,
+		ValidatorPaymentAddress: p.ValidatorPaymentAddress,
+		ValidatorPayment:        p.ValidatorCoins,
+		UsedBytes:               p.UsedBytes,
+		UsedGas:                 p.UsedGas,
+		Txs:                     p.Txs,
+	})
 }
 
 type multiError struct {
@@ -16,6 +16,7 @@ import (
 	"zenith/api"
 	"zenith/block"
 	"zenith/chain"
+	"zenith/store"
 	"zenith/store/memstore"
 	"zenith/store/storetest"
 
@@ -159,6 +160,83 @@ func TestGetAuctionContentTypes(t *testing.T) {
 	})
 }
 
+func TestGetBid(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		testStore  = memstore.NewStore()
+		storeChain = storetest.NewChain(t, testStore)
+		validator  = storetest.NewValidator(t, testStore, storeChain)
+		auction    = storetest.NewAuction(t, testStore, storeChain, 1, validator)
+		bid        = storetest.NewBid(t, testStore, storeChain, auction)
+		service    = block.NewMockServiceErr(storeChain.ID, nil)
+		manager    = block.NewStaticServiceManager(service)
+		logger     = log.NewNopLogger()
+		handler    = api.NewHandler(testStore, manager, logger)
+	)
+
+	bid.State = store.BidStateRejected
+	bid.RejectionReason = "insufficient funds for payments"
+	if err := testStore.UpdateBids(ctx, bid); err != nil {
+		t.Fatalf("update bid: %v", err)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() { server.Close() })
+
+	t.Run("found", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/v0/bid/" + bid.ID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if want, have := http.StatusOK, resp.StatusCode; want != have {
+			t.Fatalf("status code: want %d, have %d", want, have)
+		}
+
+		var status struct {
+			ID              string `json:"id"`
+			State           string `json:"state"`
+			Priority        int64  `json:"priority"`
+			RejectionReason string `json:"rejection_reason"`
+			Payments        []struct {
+				Amount int64 `json:"amount"`
+			} `json:"payments"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+
+		if want, have := bid.ID.String(), status.ID; want != have {
+			t.Errorf("ID: want %s, have %s", want, have)
+		}
+		if want, have := string(store.BidStateRejected), status.State; want != have {
+			t.Errorf("state: want %s, have %s", want, have)
+		}
+		if want, have := bid.Priority, status.Priority; want != have {
+			t.Errorf("priority: want %d, have %d", want, have)
+		}
+		if want, have := bid.RejectionReason, status.RejectionReason; want != have {
+			t.Errorf("rejection reason: want %q, have %q", want, have)
+		}
+		if want, have := len(bid.Payments), len(status.Payments); want != have {
+			t.Errorf("payment count: want %d, have %d", want, have)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/v0/bid/00000000-0000-0000-0000-000000000000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if want, have := http.StatusNotFound, resp.StatusCode; want != have {
+			t.Fatalf("status code: want %d, have %d", want, have)
+		}
+	})
+}
+
 func TestHandlerGzip(t *testing.T) {
 	var (
 		ctx      = context.Background()
@@ -199,6 +277,192 @@ func TestHandlerGzip(t *testing.T) {
 	}
 }
 
+func TestBuildCapture(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		buildHeight = height + 1
+		searcher    = storetest.GenBech32Addr(t, storetest.Network)
+		testStore   = memstore.NewStore()
+		storeChain  = storetest.NewChain(t, testStore)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service     = block.NewCoreService(chain.WithRecording(mockChain), testStore)
+		manager     = block.NewStaticServiceManager(service)
+		logger      = log.NewNopLogger()
+		captureDir  = t.TempDir()
+	)
+
+	sink, err := api.NewFileCaptureSink(captureDir, 1, 0) // a new file for every capture
+	if err != nil {
+		t.Fatalf("create capture sink: %v", err)
+	}
+	t.Cleanup(func() { sink.Close() })
+
+	server := httptest.NewServer(api.NewHandler(testStore, manager, logger, api.WithCaptureSink(sink)))
+	t.Cleanup(func() { server.Close() })
+
+	if err := testStore.UpsertValidator(ctx, &store.Validator{
+		ChainID:        storeChain.ID,
+		Address:        bar.Address,
+		PubKeyBytes:    bar.PubKeyBytes,
+		PubKeyType:     bar.PubKeyType,
+		PaymentAddress: storetest.GetBech32AddrString(t, storetest.Network, bar.Address),
+	}); err != nil {
+		t.Fatalf("register validator: %v", err)
+	}
+
+	auction, err := service.Auction(ctx, buildHeight)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	mockChain.Payments = map[string][]chain.Payment{
+		"pay": {
+			{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+			{From: searcher, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+		},
+	}
+
+	if _, err := service.Bid(ctx, buildHeight, 0, string(store.BidKindTop), "", [][]byte{[]byte("pay")}, "", nil); err != nil {
+		t.Fatalf("bid: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		path string
+		tx   string
+	}{
+		{path: "/v1/build", tx: "dHgx"},
+		{path: "/v0/build", tx: "dHgy"}, // auction is finished
+	} {
+		body := fmt.Sprintf(`{"chain_id":%q,"height":%d,"validator_address":%q,"max_bytes":-1,"max_gas":-1,"txs":[%q],"signature":"c2ln"}`, storeChain.ID, buildHeight, bar.Address, testcase.tx)
+		resp, err := http.Post(server.URL+testcase.path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	files, err := sink.Files()
+	if err != nil {
+		t.Fatalf("list capture files: %v", err)
+	}
+	if want, have := 2, len(files); want != have {
+		t.Fatalf("capture file count: want %d, have %d", want, have)
+	}
+
+	var captures []*api.BuildCapture
+	for _, filename := range files {
+		f, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs, err := api.ReadBuildCaptures(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("read captures: %v", err)
+		}
+		captures = append(captures, cs...)
+	}
+
+	if want, have := 2, len(captures); want != have {
+		t.Fatalf("capture count: want %d, have %d", want, have)
+	}
+
+	first, second := captures[0], captures[1]
+
+	if first.Response == nil {
+		t.Fatalf("first capture: error %s", first.Error)
+	}
+	if want, have := 2, len(first.Response.Txs); want != have {
+		t.Errorf("first capture tx count: want %d, have %d", want, have)
+	}
+	if want, have := height, first.Chain.LatestHeight; want != have {
+		t.Errorf("first capture latest height: want %d, have %d", want, have)
+	}
+	if want, have := bar.Address, first.Chain.Proposer.Address; want != have {
+		t.Errorf("first capture proposer: want %s, have %s", want, have)
+	}
+	if want, have := int64(100), first.Chain.Balances[searcher][storetest.Denom]; want != have {
+		t.Errorf("first capture searcher balance: want %d, have %d", want, have)
+	}
+	if want, have := "/v0/build", second.Path; want != have {
+		t.Errorf("second capture path: want %s, have %s", want, have)
+	}
+	if second.Error == "" {
+		t.Errorf("second capture: want error, have none")
+	}
+
+	for _, testcase := range []struct {
+		name     string
+		balance  int64
+		wantSame bool
+	}{
+		{name: "captured balance", balance: 100, wantSame: true},
+		{name: "insufficient balance", balance: 10, wantSame: false},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			c := *first
+			c.Chain = &chain.Recording{
+				LatestHeight: first.Chain.LatestHeight,
+				Validators:   first.Chain.Validators,
+				Proposer:     first.Chain.Proposer,
+				Balances:     map[string]map[string]int64{searcher: {storetest.Denom: testcase.balance}},
+			}
+
+			resp, err := api.ReplayBuildCapture(ctx, &c, mockChain, testStore)
+			if err != nil {
+				t.Fatalf("replay: %v", err)
+			}
+
+			if want, have := testcase.wantSame, c.SameResponse(resp); want != have {
+				t.Errorf("same response: want %v, have %v (tx count %d)", want, have, len(resp.Txs))
+			}
+		})
+	}
+
+	// Replaying doesn't change the source store.
+	{
+		a, err := testStore.SelectAuction(ctx, storeChain.ID, buildHeight)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.FinishedAt.IsZero() {
+			t.Errorf("source auction: want finished, have open")
+		}
+	}
+
+	// Another sink in the same dir removes the oldest files past its count.
+	{
+		sink, err := api.NewFileCaptureSink(captureDir, 1, 2)
+		if err != nil {
+			t.Fatalf("create capture sink: %v", err)
+		}
+		defer sink.Close()
+
+		if err := sink.WriteCapture(second); err != nil {
+			t.Fatalf("write capture: %v", err)
+		}
+
+		remaining, err := sink.Files()
+		if err != nil {
+			t.Fatalf("list capture files: %v", err)
+		}
+		if want, have := []string{files[1]}, remaining[:1]; fmt.Sprint(want) != fmt.Sprint(have) || len(remaining) != 2 {
+			t.Errorf("remaining files: want %s and a new one, have %s", want, remaining)
+		}
+	}
+}
+
 //
 //
 //
@@ -212,3 +476,89 @@ func (m *mockSigner) SignBuildBlockRequest(*mekabuild.BuildBlockRequest) error {
 func (m *mockSigner) SignRegisterChallenge(*mekabuild.RegisterChallenge) error {
 	return m.Error
 }
+
+func TestBidSearcherRateLimit(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		foo        = newTestValidator()
+		height     = int64(123)
+		bidHeight  = height + 1
+		payer      = storetest.GenBech32Addr(t, storetest.Network)
+		testStore  = memstore.NewStore()
+		storeChain = storetest.NewChain(t, testStore)
+		searcher   = storetest.NewSearcher(t, testStore, storeChain)
+		mockChain  = &chain.TestChain{
+			ChainID:           storeChain.ID,
+			Height:            height,
+			Validators:        chain.ValidatorSet{Height: height, Set: map[string]*chain.Validator{foo.Address: foo.Validator}, TotalPower: foo.VotingPower},
+			PredictedProposer: *foo.Validator,
+		}
+		service = block.NewCoreService(mockChain, testStore)
+		manager = block.NewStaticServiceManager(service)
+		limiter = api.NewRateLimiter(api.RateLimitConfig{
+			Routes: map[string]api.RateLimits{
+				"POST /v0/bid": {Searcher: api.RateLimit{Rate: 0.1, Burst: 1}},
+			},
+		})
+	)
+
+	server := httptest.NewServer(api.NewHandler(testStore, manager, log.NewNopLogger(), api.WithRateLimiter(limiter)))
+	t.Cleanup(func() { server.Close() })
+
+	if err := testStore.UpsertValidator(ctx, &store.Validator{
+		ChainID:        storeChain.ID,
+		Address:        foo.Address,
+		PubKeyBytes:    foo.PubKeyBytes,
+		PubKeyType:     foo.PubKeyType,
+		PaymentAddress: storetest.GetBech32AddrString(t, storetest.Network, foo.Address),
+	}); err != nil {
+		t.Fatalf("register validator: %v", err)
+	}
+
+	auction, err := service.Auction(ctx, bidHeight)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	mockChain.Payments = map[string][]chain.Payment{
+		"pay": {
+			{From: payer, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+			{From: payer, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+		},
+	}
+
+	bid := func(searcherID string) *http.Response {
+		t.Helper()
+		body := fmt.Sprintf(`{"chain_id":%q,"height":%d,"kind":"top","txs":["cGF5"],"searcher_id":%q,"signature":"c2ln"}`, storeChain.ID, bidHeight, searcherID)
+		if searcherID == "" {
+			body = fmt.Sprintf(`{"chain_id":%q,"height":%d,"kind":"top","txs":["cGF5"]}`, storeChain.ID, bidHeight)
+		}
+		resp, err := http.Post(server.URL+"/v0/bid", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// Bids that can't be verified don't take a token from any searcher bucket.
+	if want, have := http.StatusUnauthorized, bid("6f1c2a9e-5d4b-4c3a-9e8f-7a6b5c4d3e2f").StatusCode; want != have {
+		t.Fatalf("unregistered searcher: want %d, have %d", want, have)
+	}
+
+	if want, have := http.StatusOK, bid(searcher.ID.String()).StatusCode; want != have {
+		t.Fatalf("first signed bid: want %d, have %d", want, have)
+	}
+
+	resp := bid(searcher.ID.String())
+	if want, have := http.StatusTooManyRequests, resp.StatusCode; want != have {
+		t.Fatalf("second signed bid: want %d, have %d", want, have)
+	}
+	if want, have := "10", resp.Header.Get("retry-after"); want != have {
+		t.Errorf("retry-after: want %q, have %q", want, have)
+	}
+
+	if want, have := http.StatusOK, bid("").StatusCode; want != have {
+		t.Fatalf("unsigned bid: want %d, have %d", want, have)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestGetBestMediaType(t *testing.T) {
@@ -32,3 +33,65 @@ func TestGetBestMediaType(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	l := NewRateLimiter(RateLimitConfig{
+		Default: RateLimits{IP: RateLimit{Rate: 1, Burst: 2}},
+		Routes: map[string]RateLimits{
+			"POST /v0/bid": {IP: RateLimit{Rate: 1, Burst: 2}, Searcher: RateLimit{Rate: 1, Burst: 1}, Chain: RateLimit{Rate: 10, Burst: 10}},
+		},
+		Chains: map[string]RateLimit{"slow-chain": {Rate: 1, Burst: 1}},
+	})
+	l.now = func() time.Time { return now }
+
+	check := func(route string, keys rateLimitKeys, wantKind string, wantWait time.Duration) {
+		t.Helper()
+		kind, wait, ok := l.allow(route, keys)
+		if want, have := wantKind == "", ok; want != have {
+			t.Fatalf("%s %+v: ok: want %v, have %v", route, keys, want, have)
+		}
+		if want, have := wantKind, kind; want != have {
+			t.Errorf("%s %+v: kind: want %q, have %q", route, keys, want, have)
+		}
+		if want, have := wantWait, wait; want != have {
+			t.Errorf("%s %+v: wait: want %s, have %s", route, keys, want, have)
+		}
+	}
+
+	var (
+		ip       = rateLimitKeys{IP: "1.2.3.4"}
+		other    = rateLimitKeys{IP: "5.6.7.8"}
+		searcher = rateLimitKeys{IP: "1.2.3.4", SearcherID: "abc", ChainID: "fast-chain"}
+		slow     = rateLimitKeys{IP: "9.9.9.9", ChainID: "slow-chain"}
+	)
+
+	check("GET /v0/auction", ip, "", 0)
+	check("GET /v0/auction", ip, "", 0)
+	check("GET /v0/auction", ip, "ip", time.Second)
+	check("GET /v0/auction", other, "", 0) // different client
+	check("POST /v0/bid", searcher, "", 0) // different route
+
+	now = now.Add(500 * time.Millisecond)
+	check("GET /v0/auction", ip, "ip", 500*time.Millisecond)
+	check("POST /v0/bid", searcher, "searcher", 500*time.Millisecond)
+
+	now = now.Add(500 * time.Millisecond)
+	check("GET /v0/auction", ip, "", 0)
+	check("POST /v0/bid", searcher, "", 0)
+
+	// A rejected request takes no tokens, so the IP bucket still has one.
+	check("POST /v0/bid", searcher, "searcher", time.Second)
+	check("POST /v0/bid", rateLimitKeys{IP: searcher.IP}, "", 0)
+
+	check("POST /v0/bid", slow, "", 0)
+	check("POST /v0/bid", slow, "chain", time.Second)
+
+	// Full buckets are pruned after a while.
+	now = now.Add(time.Hour)
+	check("GET /v0/auction", ip, "", 0)
+	if want, have := 1, len(l.buckets); want != have {
+		t.Errorf("buckets after prune: want %d, have %d", want, have)
+	}
+}
@@ -25,14 +25,18 @@ type Proxy struct {
 	router  *mux.Router
 	manager ReverseProxyManager
 	logger  log.Logger
+	limiter *RateLimiter // optional
 }
 
-func NewProxy(manager ReverseProxyManager, logger log.Logger) (*Proxy, error) {
+func NewProxy(manager ReverseProxyManager, logger log.Logger, options ...ProxyOption) (*Proxy, error) {
 	p := &Proxy{
 		router:  mux.NewRouter(),
 		manager: manager,
 		logger:  logger,
 	}
+	for _, option := range options {
+		option(p)
+	}
 
 	p.router.StrictSlash(true)
 	p.router.Methods("GET").Path("/-/ping").Name("GET /-/ping").HandlerFunc(p.handlePing)
@@ -43,6 +47,7 @@ func NewProxy(manager ReverseProxyManager, logger log.Logger) (*Proxy, error) {
 		corsHeadersMiddleware,
 		debug.TracingMiddleware,
 		debug.MetricsMiddleware,
+		rateLimitMiddleware(p.limiter, logger),
 		panicRecoveryMiddleware(logger), // should be after observability middlewares
 		// the handler executes here
 	)
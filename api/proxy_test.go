@@ -1,6 +1,7 @@
 package api_test
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -43,3 +44,48 @@ func TestProxyCORS(t *testing.T) {
 		}
 	}
 }
+
+func TestProxyRateLimit(t *testing.T) {
+	store := memstore.NewStore()
+	manager := api.NewStoreReverseProxyManager(store, map[string]string{})
+	limiter := api.NewRateLimiter(api.RateLimitConfig{
+		Default:        api.RateLimits{Chain: api.RateLimit{Rate: 0.1, Burst: 1}},
+		ClientIPHeader: "X-Forwarded-For",
+	})
+	proxy, err := api.NewProxy(manager, log.NewNopLogger(), api.WithProxyRateLimiter(limiter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(path, body string) *http.Response {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.1")
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	// No proxy for the chain, but the request got past the limiter.
+	if want, have := http.StatusBadRequest, do("/v0/bid", `{"chain_id":"foo"}`).StatusCode; want != have {
+		t.Fatalf("first request: want %d, have %d", want, have)
+	}
+
+	resp := do("/v0/bid", `{"chain_id":"foo"}`)
+	if want, have := http.StatusTooManyRequests, resp.StatusCode; want != have {
+		t.Fatalf("second request: want %d, have %d", want, have)
+	}
+	if want, have := "10", resp.Header.Get("retry-after"); want != have {
+		t.Errorf("retry-after: want %q, have %q", want, have)
+	}
+
+	if want, have := http.StatusBadRequest, do("/v0/bid", `{"chain_id":"bar"}`).StatusCode; want != have {
+		t.Fatalf("other chain: want %d, have %d", want, have)
+	}
+
+	// Builds don't use the chain bucket, whatever chain they claim to be.
+	for i := 0; i < 2; i++ {
+		if want, have := http.StatusBadRequest, do("/v1/build", `{"chain_id":"bar"}`).StatusCode; want != have {
+			t.Fatalf("build %d: want %d, have %d", i+1, want, have)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mekapi/trc/eztrc"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zenith/block"
+	"zenith/debug"
+	"zenith/metrics"
+
+	"github.com/go-kit/log"
+)
+
+// ErrRateLimited is returned to clients that exceed a rate limit.
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimit is a token bucket that refills at Rate tokens per second, and holds
+// at most Burst tokens. The zero value doesn't limit anything.
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// RateLimits are the limits for each request to a route. A request takes a
+// token from the bucket of its client IP, and from the bucket of its chain if
+// it names one, except for builds. A bid signed by a searcher also takes a
+// token from the bucket of the searcher, once its signature is verified.
+type RateLimits struct {
+	IP       RateLimit `json:"ip"`
+	Searcher RateLimit `json:"searcher"`
+	Chain    RateLimit `json:"chain"`
+}
+
+// RateLimitConfig is the rate limits of every route and chain.
+type RateLimitConfig struct {
+	Default RateLimits            `json:"default"`
+	Routes  map[string]RateLimits `json:"routes,omitempty"` // by route name e.g. "POST /v0/bid", replaces the default
+	Chains  map[string]RateLimit  `json:"chains,omitempty"` // by chain ID, replaces the chain limit of every route
+
+	// ClientIPHeader is a header with the client IP, set by a reverse proxy
+	// e.g. "X-Forwarded-For". If it's not set, the remote address is used.
+	ClientIPHeader string `json:"client_ip_header,omitempty"`
+}
+
+// ReadRateLimitConfig reads a JSON rate limit config file.
+func ReadRateLimitConfig(filename string) (*RateLimitConfig, error) {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg RateLimitConfig
+	if err := json.Unmarshal(buf, &cfg); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", filename, err)
+	}
+
+	return &cfg, nil
+}
+
+func (cfg *RateLimitConfig) limits(route, chainID string) RateLimits {
+	limits, ok := cfg.Routes[route]
+	if !ok {
+		limits = cfg.Default
+	}
+	if l, ok := cfg.Chains[chainID]; ok && chainID != "" {
+		limits.Chain = l
+	}
+	return limits
+}
+
+//
+//
+//
+
+// RateLimiter admits requests according to a RateLimitConfig. Buckets are
+// kept per route, so that e.g. polling auctions doesn't use up bids.
+type RateLimiter struct {
+	cfg RateLimitConfig
+	now func() time.Time
+
+	mtx     sync.Mutex
+	buckets map[string]*tokenBucket
+	pruned  time.Time
+}
+
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		cfg:     cfg,
+		now:     time.Now,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// rateLimitKeys identify the client of a request.
+type rateLimitKeys struct {
+	IP         string
+	SearcherID string
+	ChainID    string
+}
+
+// allow takes a token from every bucket of the request, and returns true if
+// it could. Otherwise, it takes no tokens, and returns the kind of the first
+// empty bucket, and how long until it has a token again.
+func (l *RateLimiter) allow(route string, keys rateLimitKeys) (string, time.Duration, bool) {
+	limits := l.cfg.limits(route, keys.ChainID)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := l.now()
+	l.maybePrune(now)
+
+	var buckets []*tokenBucket
+	for _, b := range []struct {
+		kind  string
+		key   string
+		limit RateLimit
+	}{
+		{"ip", keys.IP, limits.IP},
+		{"searcher", keys.SearcherID, limits.Searcher},
+		{"chain", keys.ChainID, limits.Chain},
+	} {
+		if b.key == "" || b.limit.Rate <= 0 {
+			continue
+		}
+
+		bucket := l.bucket(route+" "+b.kind+" "+b.key, b.limit, now)
+		if wait := bucket.wait(); wait > 0 {
+			return b.kind, wait, false
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+
+	return "", 0, true
+}
+
+func (l *RateLimiter) bucket(key string, limit RateLimit, now time.Time) *tokenBucket {
+	b, ok := l.buckets[key]
+	if !ok || b.limit != limit {
+		b = &tokenBucket{limit: limit, tokens: limit.burst(), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+	return b
+}
+
+// maybePrune drops full buckets once a minute, because they're the same as
+// new ones, to keep the number of buckets bounded by the number of active
+// clients.
+func (l *RateLimiter) maybePrune(now time.Time) {
+	if now.Sub(l.pruned) < time.Minute {
+		return
+	}
+	for key, b := range l.buckets {
+		if b.refill(now); b.tokens >= b.limit.burst() {
+			delete(l.buckets, key)
+		}
+	}
+	l.pruned = now
+}
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.limit.burst(), b.tokens+elapsed*b.limit.Rate)
+		b.last = now
+	}
+}
+
+// wait returns how long until the bucket has a token, or zero if it has one.
+func (b *tokenBucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+}
+
+func (l RateLimit) burst() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+// WithRateLimiter makes the handler respond 429 to requests that exceed the
+// limits of the rate limiter.
+func WithRateLimiter(l *RateLimiter) HandlerOption {
+	return func(h *Handler) { h.limiter = l }
+}
+
+// ProxyOption configures optional parts of a Proxy.
+type ProxyOption func(*Proxy)
+
+// WithProxyRateLimiter makes the proxy respond 429 to requests that exceed the
+// limits of the rate limiter, before they're proxied.
+func WithProxyRateLimiter(l *RateLimiter) ProxyOption {
+	return func(p *Proxy) { p.limiter = l }
+}
+
+//
+//
+//
+
+// rateLimitMiddleware responds 429 to requests that exceed a rate limit. It
+// should be after the observability middlewares, so those requests show up
+// there, and after any middlewares that decode the body.
+func rateLimitMiddleware(l *RateLimiter, logger log.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				ctx   = r.Context()
+				route = debug.RouteName(r)
+				keys  = readRateLimitKeys(w, r, l.cfg.ClientIPHeader)
+			)
+
+			kind, wait, ok := l.allow(route, keys)
+			if !ok {
+				eztrc.Tracef(ctx, "rate limited by %s bucket (ip %s, chain %q), retry after %s", kind, keys.IP, keys.ChainID, wait)
+				metrics.HTTPThrottledRequestsTotal.WithLabelValues(route, kind).Inc()
+				w.Header().Set("retry-after", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				respondError(w, r, fmt.Errorf("%s: %w", kind, ErrRateLimited), http.StatusTooManyRequests, logger)
+				return
+			}
+
+			// Searcher IDs can only be trusted once the bid signature is
+			// verified, so the searcher bucket is checked by the service.
+			r = r.WithContext(block.WithSearcherAdmission(ctx, func(searcherID string) error {
+				kind, wait, ok := l.allow(route, rateLimitKeys{SearcherID: searcherID})
+				if !ok {
+					eztrc.Tracef(ctx, "rate limited by %s bucket (searcher %s), retry after %s", kind, searcherID, wait)
+					metrics.HTTPThrottledRequestsTotal.WithLabelValues(route, kind).Inc()
+					w.Header().Set("retry-after", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+					return fmt.Errorf("%s: %w", kind, ErrRateLimited)
+				}
+				return nil
+			}))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isBuildPath is true for the build routes, which are called by validators
+// with a chain ID that's only checked by the service. They'd use up the chain
+// bucket of bids on behalf of anyone who claims to be the chain.
+func isBuildPath(p string) bool {
+	switch path.Clean(p) {
+	case "/v0/build", "/v1/build":
+		return true
+	}
+	return false
+}
+
+// readRateLimitKeys finds the client IP and chain ID of the request. The chain
+// ID is taken from the query, then the headers, and then the body, which is
+// left for the next handler to read. Build requests have no chain ID.
+func readRateLimitKeys(w http.ResponseWriter, r *http.Request, clientIPHeader string) rateLimitKeys {
+	keys := rateLimitKeys{
+		IP: clientIP(r, clientIPHeader),
+	}
+
+	if isBuildPath(r.URL.Path) {
+		return keys
+	}
+
+	keys.ChainID = r.URL.Query().Get("chain_id")
+	if keys.ChainID == "" {
+		keys.ChainID = r.Header.Get(ChainIDHeaderKey)
+	}
+	if keys.ChainID != "" || r.Body == nil || r.Body == http.NoBody {
+		return keys
+	}
+
+	bodyBytes, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(bodyBytes), errReader{err}))
+	if err != nil {
+		return keys // the next handler gets the error
+	}
+
+	var x struct {
+		ChainID string `json:"chain_id"`
+	}
+	if err := json.Unmarshal(bodyBytes, &x); err != nil {
+		if values, err := url.ParseQuery(string(bodyBytes)); err == nil {
+			x.ChainID = values.Get("chain_id")
+		}
+	}
+	keys.ChainID = x.ChainID
+
+	return keys
+}
+
+func clientIP(r *http.Request, header string) string {
+	if header != "" {
+		// The first address is the client, the rest are proxies.
+		if first, _, _ := strings.Cut(r.Header.Get(header), ","); strings.TrimSpace(first) != "" {
+			return strings.TrimSpace(first)
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// errReader returns its error, or EOF if it's nil.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
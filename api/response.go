@@ -54,6 +54,8 @@ func classifyError(err error, fallback int) (int, bool) {
 		return http.StatusGone, false
 	case errors.Is(err, block.ErrAuctionUnavailable):
 		return http.StatusExpectationFailed, false
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests, false
 	case errors.Is(err, chain.ErrBadSignature):
 		return http.StatusUnauthorized, true
 	case errors.Is(err, store.ErrNotFound):
@@ -0,0 +1,143 @@
+package block
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mekapi/trc"
+	"mekapi/trc/eztrc"
+
+	"zenith/metrics"
+	"zenith/store"
+
+	"github.com/meka-dev/mekatek-go/mekabuild"
+)
+
+// Preview is the block that would be built for a proposer, if it asked now.
+type Preview struct {
+	ChainID                 string
+	Height                  int64
+	ValidatorAddress        string
+	ValidatorPaymentAddress string
+	PaymentDenom            string
+	ValidatorPayment        int64  // converted to PaymentDenom
+	ValidatorCoins          string // actually paid to the validator, e.g. "5uatom,100uosmo"
+	UsedBytes               int64
+	UsedGas                 int64
+	Txs                     []store.BuildTx
+}
+
+// PreviewSignBytes returns the bytes a validator signs to preview a block. They
+// differ from the build request sign bytes, so that a preview request can't be
+// replayed as a build request.
+func PreviewSignBytes(chainID string, height int64, validatorAddr string, maxBytes, maxGas int64, txsHash []byte) []byte {
+	return []byte(fmt.Sprintf("zenith preview %s %d %s %d %d %X", chainID, height, validatorAddr, maxBytes, maxGas, txsHash))
+}
+
+// Preview runs the same pipeline as BuildV1 for a registered proposer, but
+// doesn't claim the auction, or change the state of any bid.
+func (s *CoreService) Preview(
+	ctx context.Context,
+	height int64,
+	validatorAddr string,
+	maxBytes, maxGas int64,
+	txs [][]byte,
+	signature []byte,
+) (_ *Preview, err error) {
+	ctx = trc.PrefixContextf(ctx, "[Preview]")
+	chainID := s.chain.ID()
+
+	defer func() {
+		result := boolString(err == nil, "success", "error")
+		metrics.PreviewRequestsTotal.WithLabelValues(chainID, result).Inc()
+		metrics.ValidatorRequestsTotal.WithLabelValues(chainID, validatorAddr, "Preview", result).Inc()
+	}()
+
+	eztrc.Tracef(ctx, "height %d", height)
+	eztrc.Tracef(ctx, "validator addr %s", validatorAddr)
+	eztrc.Tracef(ctx, "max bytes %d, max gas %d, tx count %d", maxBytes, maxGas, len(txs))
+	eztrc.Tracef(ctx, "signature %dB", len(signature))
+
+	ch, err := s.store.SelectChain(ctx, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("query for chain: %w", err)
+	}
+
+	vs, proposer, err := s.verifyProposer(ctx, height, validatorAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the request has been signed by the proposer.
+	{
+		msg := PreviewSignBytes(chainID, height, validatorAddr, maxBytes, maxGas, mekabuild.HashTxs(txs...))
+		if err := s.chain.VerifySignature(ctx, proposer.PubKeyType, proposer.PubKeyBytes, msg, signature); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.store.SelectValidator(ctx, chainID, validatorAddr); err != nil {
+		return nil, fmt.Errorf("%w: proposer %s not registered: %v", ErrAuctionUnavailable, validatorAddr, err)
+	}
+
+	// Use the auction as it stands, or as BuildV1 would create it.
+	var auction *store.Auction
+	{
+		a, err := s.store.SelectAuction(ctx, chainID, height)
+		switch {
+		case err == nil:
+			eztrc.Tracef(ctx, "auction found")
+		case errors.Is(err, store.ErrNotFound):
+			eztrc.Tracef(ctx, "auction not found, calculating allocation")
+			if a, err = s.newAuction(ctx, s.store, ch, vs, proposer, height); err != nil {
+				return nil, err
+			}
+		case err != nil:
+			return nil, fmt.Errorf("fetch auction: %w", err)
+		}
+
+		if !a.FinishedAt.IsZero() {
+			return nil, fmt.Errorf("%s/%d: %w", chainID, height, ErrAuctionFinished)
+		}
+
+		if want, have := proposer.Address, a.ValidatorAddress; want != have {
+			return nil, fmt.Errorf("mismatched validators: want %s, have %s", want, have)
+		}
+
+		auction = a
+	}
+
+	bids, err := s.store.ListBids(ctx, chainID, height)
+	if err != nil {
+		return nil, fmt.Errorf("get auction bids: %w", err)
+	}
+
+	build, replayed, err := Replay(ctx, s.chain, auction, store.ParseBidSelection(string(ch.BidSelection)), bids, txs, maxBytes, maxGas)
+	if err != nil {
+		return nil, err
+	}
+
+	var accepted []*Bid
+	for _, b := range replayed {
+		if b.State == store.BidStateAccepted {
+			accepted = append(accepted, b)
+		}
+	}
+
+	for _, tx := range build.Txs {
+		eztrc.Tracef(ctx, " - %s (%s)", tx.Hash, tx.Source)
+	}
+
+	return &Preview{
+		ChainID:                 chainID,
+		Height:                  height,
+		ValidatorAddress:        validatorAddr,
+		ValidatorPaymentAddress: auction.ValidatorPaymentAddress,
+		PaymentDenom:            auction.PaymentDenom,
+		ValidatorPayment:        build.ValidatorPayment,
+		ValidatorCoins:          validatorCoins(auction, accepted),
+		UsedBytes:               build.UsedBytes,
+		UsedGas:                 build.UsedGas,
+		Txs:                     build.Txs,
+	}, nil
+}
@@ -0,0 +1,142 @@
+package block
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mekapi/trc/eztrc"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"zenith/store"
+)
+
+// PriceSource provides conversion rates between payment denoms.
+type PriceSource interface {
+	// Rate returns how many units of baseDenom one unit of denom is worth.
+	Rate(ctx context.Context, denom, baseDenom string) (float64, error)
+}
+
+// FilePriceSource reads conversion rates from a local JSON file, which maps
+// each base denom to the rates of other denoms into it.
+//
+//	{"uosmo": {"ibc/27394FB0...": 12.5}}
+//
+// The file is read again whenever it changes, so rates can be updated without
+// a restart.
+type FilePriceSource struct {
+	path string
+
+	mtx     sync.Mutex
+	modTime time.Time
+	rates   map[string]map[string]float64
+}
+
+var _ PriceSource = (*FilePriceSource)(nil)
+
+func NewFilePriceSource(path string) *FilePriceSource {
+	return &FilePriceSource{
+		path: path,
+	}
+}
+
+func (s *FilePriceSource) Rate(ctx context.Context, denom, baseDenom string) (float64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return 0, fmt.Errorf("refresh %s: %w", s.path, err)
+	}
+
+	rate, ok := s.rates[baseDenom][denom]
+	if !ok || rate <= 0 {
+		return 0, fmt.Errorf("no rate for %s in %s", denom, baseDenom)
+	}
+
+	return rate, nil
+}
+
+func (s *FilePriceSource) refresh() error {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+
+	if s.rates != nil && fi.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	buf, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	var rates map[string]map[string]float64
+	if err := json.Unmarshal(buf, &rates); err != nil {
+		return fmt.Errorf("decode rates: %w", err)
+	}
+
+	s.rates, s.modTime = rates, fi.ModTime()
+	return nil
+}
+
+//
+//
+//
+
+// paymentDenomRates returns the rates of the chain's other accepted payment
+// denoms into its PaymentDenom, for a new auction. Rates from the price source
+// take precedence over the chain's static rates. Denoms without a positive
+// rate aren't accepted.
+func paymentDenomRates(ctx context.Context, prices PriceSource, ch *store.Chain) map[string]float64 {
+	rates := make(map[string]float64, len(ch.PaymentDenomRates))
+	for denom, rate := range ch.PaymentDenomRates {
+		if prices != nil {
+			switch r, err := prices.Rate(ctx, denom, ch.PaymentDenom); {
+			case err == nil:
+				rate = r
+			default:
+				eztrc.Tracef(ctx, "price source: %v, using static rate %v", err, rate)
+			}
+		}
+
+		if rate <= 0 {
+			eztrc.Tracef(ctx, "no rate for %s, not accepted", denom)
+			continue
+		}
+
+		rates[denom] = rate
+	}
+	return rates
+}
+
+// paymentDenoms returns the denoms accepted for payment in the auction, with
+// its PaymentDenom first.
+func paymentDenoms(auction *store.Auction) []string {
+	others := make([]string, 0, len(auction.PaymentDenomRates))
+	for denom := range auction.PaymentDenomRates {
+		if denom != auction.PaymentDenom {
+			others = append(others, denom)
+		}
+	}
+	sort.Strings(others)
+	return append([]string{auction.PaymentDenom}, others...)
+}
+
+// paymentValue converts an amount of denom to the auction's PaymentDenom,
+// rounding down. It returns false if the denom isn't accepted.
+func paymentValue(auction *store.Auction, denom string, amount int64) (int64, bool) {
+	if denom == "" || denom == auction.PaymentDenom {
+		return amount, true
+	}
+
+	rate, ok := auction.PaymentDenomRates[denom]
+	if !ok || rate <= 0 {
+		return 0, false
+	}
+
+	return int64(math.Floor(float64(amount) * rate)), true
+}
@@ -0,0 +1,69 @@
+package block
+
+import (
+	"context"
+	"fmt"
+	"mekapi/trc"
+	"mekapi/trc/eztrc"
+
+	"zenith/chain"
+	"zenith/store"
+)
+
+// Replay runs the bids and mempool txs of an auction through the same pipeline
+// as BuildV1, without a store, and returns the build it results in, along with
+// copies of the bids that took part, in their resulting states. The bids
+// themselves aren't changed, and their previous states are ignored.
+func Replay(
+	ctx context.Context,
+	c chain.Chain,
+	auction *Auction,
+	selection store.BidSelection,
+	bids []*Bid,
+	txs [][]byte,
+	maxBytes, maxGas int64,
+) (*store.Build, []*Bid, error) {
+	ctx = trc.PrefixContextf(ctx, "[replay]")
+
+	eztrc.Tracef(ctx, "%s/%d, bid count %d, mempool tx count %d", auction.ChainID, auction.Height, len(bids), len(txs))
+
+	replayBids := make([]*Bid, 0, len(bids))
+	for _, b := range bids {
+		b := copyBid(b)
+		if b.Height == auction.Height {
+			b.State, b.RejectionReason = store.BidStatePending, ""
+		}
+		replayBids = append(replayBids, b)
+	}
+
+	replayBids = evaluateRangeBids(ctx, c, auction, replayBids)
+
+	winningBids, _, remainingTxs, err := computeOrder(ctx, c, auction, replayBids, txs, newBidSelector(selection), maxBytes, maxGas)
+	if err != nil {
+		return nil, nil, fmt.Errorf("compute block order: %w", err)
+	}
+
+	txBundles, _, _, usedBytes, usedGas := selectTransactions(ctx, c, winningBids, remainingTxs, maxBytes, maxGas)
+
+	build := &store.Build{
+		ChainID:          auction.ChainID,
+		Height:           auction.Height,
+		ValidatorAddress: auction.ValidatorAddress,
+		MaxBytes:         maxBytes,
+		MaxGas:           maxGas,
+		UsedBytes:        usedBytes,
+		UsedGas:          usedGas,
+		PaymentDenom:     auction.PaymentDenom,
+	}
+	for _, b := range txBundles {
+		for _, tx := range b.txs {
+			build.Txs = append(build.Txs, newBuildTx(tx, b))
+		}
+		build.ValidatorPayment += b.validatorPayment
+		build.MekatekPayment += b.mekatekPayment
+	}
+
+	eztrc.Tracef(ctx, "tx count %d, %d %s to validator, %d %s to mekatek", len(build.Txs), build.ValidatorPayment, build.PaymentDenom, build.MekatekPayment, build.PaymentDenom)
+
+	return build, replayBids, nil
+}
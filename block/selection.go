@@ -0,0 +1,394 @@
+package block
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"mekapi/trc"
+	"mekapi/trc/eztrc"
+
+	"zenith/metrics"
+	"zenith/store"
+)
+
+// bidSelector picks the winning bids for a block. It's given bids which are
+// each valid on their own, and must resolve the conflicts between them: shared
+// txs, competing top-of-block and backrun bids, and payments from the same
+// sender. Losing bids are marked rejected, with a reason, and winning bids are
+// returned in the order they should appear in the block.
+type bidSelector interface {
+	selectBids(ctx context.Context, in *selectionInput) (winners, losers []*Bid)
+}
+
+// selectionInput is everything a bidSelector needs to know about an auction.
+type selectionInput struct {
+	chainID  string
+	bids     []*Bid       // sorted highest priority first
+	sizes    []bundleSize // of each bid, in the same order as bids
+	txHashes [][]string   // of the txs of each bid, in the same order as bids
+	balances map[balanceKey]int64
+	maxBytes int64 // -1 for no limit
+	maxGas   int64 // -1 for no limit
+}
+
+// balanceKey identifies the balance of one denom in an account.
+type balanceKey struct {
+	addr  string
+	denom string
+}
+
+type bundleSize struct {
+	bytes int64
+	gas   int64
+}
+
+func newBidSelector(s store.BidSelection) bidSelector {
+	switch s {
+	case store.BidSelectionGreedy:
+		return greedySelector{}
+	default:
+		return maxRevenueSelector{maxNodes: maxRevenueSearchNodes}
+	}
+}
+
+// rejectBid marks the bid as rejected for the given reason.
+func rejectBid(ctx context.Context, chainID string, eb *Bid, result, reason string) {
+	eb.State = store.BidStateRejected
+	eb.RejectionReason = reason
+	eztrc.Tracef(ctx, "rejected, %s", reason)
+	metrics.BidsEvaluatedTotal.WithLabelValues(chainID, result).Inc()
+}
+
+//
+//
+//
+
+// greedySelector walks the bids in priority order, and accepts each bid that
+// doesn't conflict with a higher priority winner. It ignores block capacity,
+// which is left to selectTransactions.
+type greedySelector struct{}
+
+func (greedySelector) selectBids(ctx context.Context, in *selectionInput) (winners, losers []*Bid) {
+	var (
+		balances       = copyBalances(in.balances)
+		claimedTxs     = map[string]bool{}
+		blockStarted   bool                // a non-backrun bid has won, so there's no more top-of-block
+		backrunTargets = map[string]bool{} // target tx hash of winning backrun bids
+	)
+	for i, eb := range in.bids {
+		ctx := trc.PrefixContextf(ctx, "bid %s (%d/%d) [%d]", eb.ID, i+1, len(in.bids), eb.Priority)
+
+		// Bids that need to be top-of-block are rejected if there is already a top-of-block bid.
+		if eb.Kind == store.BidKindTop && blockStarted {
+			rejectBid(ctx, in.chainID, eb, "top of block", "block already has a top-of-block bid")
+			losers = append(losers, eb)
+			continue
+		}
+
+		// Bids with transactions that have already been "claimed" by other bids are rejected.
+		if hasAnyTx(in.txHashes[i], claimedTxs) {
+			rejectBid(ctx, in.chainID, eb, "claimed txs", "txs already claimed by a higher priority bid")
+			losers = append(losers, eb)
+			continue
+		}
+
+		// Backrun bids are rejected if their target tx is already claimed or
+		// backrun by another bid.
+		if eb.Kind == store.BidKindBackrun {
+			var reason string
+			switch {
+			case claimedTxs[eb.TargetTxHash]:
+				reason = "target tx claimed by a higher priority bid"
+			case backrunTargets[eb.TargetTxHash]:
+				reason = "target tx already backrun by a higher priority bid"
+			}
+			if reason != "" {
+				rejectBid(ctx, in.chainID, eb, "backrun target", reason)
+				losers = append(losers, eb)
+				continue
+			}
+		}
+
+		// Bids which include the target tx of a winning backrun bid are rejected.
+		if hasAnyTx(in.txHashes[i], backrunTargets) {
+			rejectBid(ctx, in.chainID, eb, "claimed txs", "txs include the target of a higher priority backrun bid")
+			losers = append(losers, eb)
+			continue
+		}
+
+		// Bids with unsatisfiable payments are rejected.
+		if !chargePayments(ctx, balances, eb.Payments) {
+			rejectBid(ctx, in.chainID, eb, "insufficient funds", "insufficient funds for payments")
+			losers = append(losers, eb)
+			continue
+		}
+
+		// Otherwise, the bid is a winning bid.
+		winners = append(winners, eb)
+		switch eb.Kind {
+		case store.BidKindBackrun:
+			backrunTargets[eb.TargetTxHash] = true
+		default:
+			blockStarted = true
+		}
+		for _, h := range in.txHashes[i] { // claim txs in bid
+			claimedTxs[h] = true
+		}
+
+		eztrc.Tracef(ctx, "accepted")
+		metrics.BidsEvaluatedTotal.WithLabelValues(in.chainID, "won").Inc()
+	}
+
+	metrics.BidSelectionsTotal.WithLabelValues(in.chainID, string(store.BidSelectionGreedy), "none").Inc()
+
+	return winners, losers
+}
+
+//
+//
+//
+
+// maxRevenueSearchNodes bounds the work done by the max revenue selector for
+// a single block. Searches over roughly 16 bids or fewer always complete.
+const maxRevenueSearchNodes = 1 << 17
+
+// maxRevenueSelector picks the conflict-free set of bids that fits in the
+// block and maximizes the total payment, via a branch-and-bound search over
+// the bids in priority order. The first set it considers is the greedy one,
+// so if the search runs out of nodes, the result is still at least as good
+// as greedy selection under the same capacity limits.
+type maxRevenueSelector struct {
+	maxNodes int
+}
+
+func (s maxRevenueSelector) selectBids(ctx context.Context, in *selectionInput) (winners, losers []*Bid) {
+	var (
+		n         = len(in.bids)
+		remaining = make([]int64, n+1) // remaining[i] is the total priority of bids[i:]
+		maxBytes  = limitOrMax(in.maxBytes)
+		maxGas    = limitOrMax(in.maxGas)
+	)
+	for i := n - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + in.bids[i].Priority
+	}
+
+	var (
+		state    = newSelectionState(in.balances)
+		chosen   = make([]bool, n)
+		best     = make([]bool, n)
+		bestSum  = int64(-1)
+		nodes    int
+		complete = true
+	)
+
+	var search func(i int, sum int64)
+	search = func(i int, sum int64) {
+		if i >= n {
+			if sum > bestSum {
+				bestSum = sum
+				copy(best, chosen)
+			}
+			return
+		}
+		if sum+remaining[i] <= bestSum {
+			return // can't beat the best set found so far
+		}
+		if nodes >= s.maxNodes && bestSum >= 0 {
+			complete = false
+			return
+		}
+		nodes++
+
+		eb, size, hashes := in.bids[i], in.sizes[i], in.txHashes[i]
+		if _, ok := state.conflict(eb, hashes); !ok && state.fits(size, maxBytes, maxGas) {
+			state.add(eb, hashes, size)
+			chosen[i] = true
+			search(i+1, sum+eb.Priority)
+			chosen[i] = false
+			state.remove(eb, hashes, size)
+		}
+		search(i+1, sum)
+	}
+	search(0, 0)
+
+	eztrc.Tracef(ctx, "searched %d nodes, complete %v, total payment %d", nodes, complete, bestSum)
+	metrics.BidSelectionsTotal.WithLabelValues(in.chainID, string(store.BidSelectionMaxRevenue), boolString(complete, "complete", "truncated")).Inc()
+
+	// The top-of-block bid, if any, goes first. Otherwise, keep priority order.
+	final := newSelectionState(in.balances)
+	for i, eb := range in.bids {
+		if !best[i] {
+			continue
+		}
+		final.add(eb, in.txHashes[i], in.sizes[i])
+		if eb.Kind == store.BidKindTop {
+			winners = append([]*Bid{eb}, winners...)
+		} else {
+			winners = append(winners, eb)
+		}
+	}
+
+	for i, eb := range in.bids {
+		ctx := trc.PrefixContextf(ctx, "bid %s (%d/%d) [%d]", eb.ID, i+1, len(in.bids), eb.Priority)
+
+		if best[i] {
+			eztrc.Tracef(ctx, "accepted")
+			metrics.BidsEvaluatedTotal.WithLabelValues(in.chainID, "won").Inc()
+			continue
+		}
+
+		result, reason := "lower revenue", "not part of the highest paying set of bids"
+		switch conflict, ok := final.conflict(eb, in.txHashes[i]); {
+		case ok:
+			result, reason = conflict.result, conflict.reason
+		case !final.fits(in.sizes[i], maxBytes, maxGas):
+			result, reason = "block capacity", fmt.Sprintf("exceeds remaining block capacity (%d bytes, %d gas)", in.sizes[i].bytes, in.sizes[i].gas)
+		}
+		rejectBid(ctx, in.chainID, eb, result, reason)
+		losers = append(losers, eb)
+	}
+
+	return winners, losers
+}
+
+// selectionState tracks what a set of selected bids has claimed, so that
+// bids can be added and removed during a search.
+type selectionState struct {
+	claimedTxs     map[string]int // tx hash to number of selected bids including it
+	backrunTargets map[string]int // tx hash to number of selected backrun bids targeting it
+	topCount       int
+	balances       map[balanceKey]int64
+	bytes          int64
+	gas            int64
+}
+
+type selectionConflict struct {
+	result string
+	reason string
+}
+
+func newSelectionState(balances map[balanceKey]int64) *selectionState {
+	return &selectionState{
+		claimedTxs:     map[string]int{},
+		backrunTargets: map[string]int{},
+		balances:       copyBalances(balances),
+	}
+}
+
+// conflict returns the first reason the bid, whose txs have the given hashes,
+// can't join the selected bids. Block capacity is checked separately, by fits.
+func (s *selectionState) conflict(eb *Bid, txHashes []string) (selectionConflict, bool) {
+	if eb.Kind == store.BidKindTop && s.topCount > 0 {
+		return selectionConflict{"top of block", "block already has a top-of-block bid"}, true
+	}
+
+	for _, h := range txHashes {
+		if s.claimedTxs[h] > 0 {
+			return selectionConflict{"claimed txs", "txs already claimed by a winning bid"}, true
+		}
+	}
+
+	if eb.Kind == store.BidKindBackrun {
+		switch {
+		case s.claimedTxs[eb.TargetTxHash] > 0:
+			return selectionConflict{"backrun target", "target tx claimed by a winning bid"}, true
+		case s.backrunTargets[eb.TargetTxHash] > 0:
+			return selectionConflict{"backrun target", "target tx already backrun by a winning bid"}, true
+		}
+	}
+
+	for _, h := range txHashes {
+		if s.backrunTargets[h] > 0 {
+			return selectionConflict{"claimed txs", "txs include the target of a winning backrun bid"}, true
+		}
+	}
+
+	for k, amount := range sumPayments(eb.Payments) {
+		if s.balances[k] < amount {
+			return selectionConflict{"insufficient funds", "insufficient funds for payments"}, true
+		}
+	}
+
+	return selectionConflict{}, false
+}
+
+func (s *selectionState) fits(size bundleSize, maxBytes, maxGas int64) bool {
+	return s.bytes+size.bytes <= maxBytes && s.gas+size.gas <= maxGas
+}
+
+func (s *selectionState) add(eb *Bid, txHashes []string, size bundleSize) {
+	s.update(eb, txHashes, size, 1)
+}
+
+func (s *selectionState) remove(eb *Bid, txHashes []string, size bundleSize) {
+	s.update(eb, txHashes, size, -1)
+}
+
+func (s *selectionState) update(eb *Bid, txHashes []string, size bundleSize, delta int) {
+	for _, h := range txHashes {
+		s.claimedTxs[h] += delta
+	}
+	switch eb.Kind {
+	case store.BidKindTop:
+		s.topCount += delta
+	case store.BidKindBackrun:
+		s.backrunTargets[eb.TargetTxHash] += delta
+	}
+	for _, p := range eb.Payments {
+		s.balances[balanceKey{p.From, p.Denom}] -= int64(delta) * p.Amount
+	}
+	s.bytes += int64(delta) * size.bytes
+	s.gas += int64(delta) * size.gas
+}
+
+//
+//
+//
+
+func copyBalances(balances map[balanceKey]int64) map[balanceKey]int64 {
+	c := make(map[balanceKey]int64, len(balances))
+	for k, v := range balances {
+		c[k] = v
+	}
+	return c
+}
+
+// chargePayments deducts the payments from the balances, and returns true, if
+// every sender can afford them. Otherwise, it leaves the balances unchanged,
+// and returns false.
+func chargePayments(ctx context.Context, balances map[balanceKey]int64, payments []Payment) bool {
+	for k, amount := range sumPayments(payments) {
+		if balance := balances[k]; balance < amount {
+			eztrc.Tracef(ctx, "payment addr %s: %v - %v = %v %s -- insufficient funds", k.addr, balance, amount, balance-amount, k.denom)
+			return false
+		}
+	}
+	for _, p := range payments {
+		balances[balanceKey{p.From, p.Denom}] -= p.Amount
+	}
+	return true
+}
+
+func sumPayments(payments []Payment) map[balanceKey]int64 {
+	sums := make(map[balanceKey]int64, len(payments))
+	for _, p := range payments {
+		sums[balanceKey{p.From, p.Denom}] += p.Amount
+	}
+	return sums
+}
+
+func hasAnyTx(txHashes []string, hashes map[string]bool) bool {
+	for _, h := range txHashes {
+		if hashes[h] {
+			return true
+		}
+	}
+	return false
+}
+
+func limitOrMax(limit int64) int64 {
+	if limit == -1 {
+		return math.MaxInt64
+	}
+	return limit
+}
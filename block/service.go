@@ -9,6 +9,7 @@ import (
 	"mekapi/trc"
 	"mekapi/trc/eztrc"
 	"sort"
+	"strings"
 	"time"
 
 	"zenith/chain"
@@ -16,6 +17,7 @@ import (
 	"zenith/metrics"
 	"zenith/store"
 
+	"github.com/gofrs/uuid"
 	"github.com/meka-dev/mekatek-go/mekabuild"
 )
 
@@ -28,17 +30,26 @@ type (
 	Payment   = store.Payment
 	Challenge = store.Challenge
 	Validator = store.Validator
+
+	SearcherChallenge = store.SearcherChallenge
+	Searcher          = store.Searcher
 )
 
 type Service interface {
 	ChainID() string
 	Ping(ctx context.Context) error
 	Auction(ctx context.Context, height int64) (*Auction, error)
-	Bid(ctx context.Context, height int64, kind string, txs [][]byte) (*Bid, error)
+	Bid(ctx context.Context, height, maxHeight int64, kind string, targetTxHash string, txs [][]byte, searcherID string, signature []byte) (*Bid, error)
+	CancelBid(ctx context.Context, bidID string, pubKeyType string, pubKeyBytes []byte, signature []byte) error
+	ReplaceBid(ctx context.Context, bidID string, txs [][]byte, pubKeyType string, pubKeyBytes []byte, signature []byte) (*Bid, error)
 	Apply(ctx context.Context, validatorAddr string, paymentAddr string) (*Challenge, error)
 	Register(ctx context.Context, challengeID string, signature []byte) (*Validator, error)
+	ApplySearcher(ctx context.Context, pubKeyType string, pubKeyBytes []byte) (*SearcherChallenge, error)
+	RegisterSearcher(ctx context.Context, challengeID string, signature []byte) (*Searcher, error)
 	Build(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) ([][]byte, string, error)
 	BuildV1(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) ([][]byte, string, error)
+	Preview(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) (*Preview, error)
+	TrackInclusion(ctx context.Context) error
 }
 
 //
@@ -46,14 +57,20 @@ type Service interface {
 //
 
 type MockService struct {
-	ChainIDFunc  func() string
-	PingFunc     func(ctx context.Context) error
-	AuctionFunc  func(ctx context.Context, height int64) (*Auction, error)
-	BidFunc      func(ctx context.Context, height int64, kind string, txs [][]byte) (*Bid, error)
-	ApplyFunc    func(ctx context.Context, validatorAddr string, paymentAddr string) (*Challenge, error)
-	RegisterFunc func(ctx context.Context, challengeID string, signature []byte) (*Validator, error)
-	BuildFunc    func(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) ([][]byte, string, error)
-	BuildV1Func  func(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) ([][]byte, string, error)
+	ChainIDFunc          func() string
+	PingFunc             func(ctx context.Context) error
+	AuctionFunc          func(ctx context.Context, height int64) (*Auction, error)
+	BidFunc              func(ctx context.Context, height, maxHeight int64, kind string, targetTxHash string, txs [][]byte, searcherID string, signature []byte) (*Bid, error)
+	CancelBidFunc        func(ctx context.Context, bidID string, pubKeyType string, pubKeyBytes []byte, signature []byte) error
+	ReplaceBidFunc       func(ctx context.Context, bidID string, txs [][]byte, pubKeyType string, pubKeyBytes []byte, signature []byte) (*Bid, error)
+	ApplyFunc            func(ctx context.Context, validatorAddr string, paymentAddr string) (*Challenge, error)
+	RegisterFunc         func(ctx context.Context, challengeID string, signature []byte) (*Validator, error)
+	ApplySearcherFunc    func(ctx context.Context, pubKeyType string, pubKeyBytes []byte) (*SearcherChallenge, error)
+	RegisterSearcherFunc func(ctx context.Context, challengeID string, signature []byte) (*Searcher, error)
+	BuildFunc            func(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) ([][]byte, string, error)
+	BuildV1Func          func(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) ([][]byte, string, error)
+	PreviewFunc          func(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) (*Preview, error)
+	TrackInclusionFunc   func(ctx context.Context) error
 }
 
 func NewMockServiceErr(chainID string, err error) *MockService {
@@ -67,7 +84,13 @@ func NewMockServiceErr(chainID string, err error) *MockService {
 		AuctionFunc: func(ctx context.Context, height int64) (*Auction, error) {
 			return nil, err
 		},
-		BidFunc: func(ctx context.Context, height int64, kind string, txs [][]byte) (*Bid, error) {
+		BidFunc: func(ctx context.Context, height, maxHeight int64, kind string, targetTxHash string, txs [][]byte, searcherID string, signature []byte) (*Bid, error) {
+			return nil, err
+		},
+		CancelBidFunc: func(ctx context.Context, bidID string, pubKeyType string, pubKeyBytes []byte, signature []byte) error {
+			return err
+		},
+		ReplaceBidFunc: func(ctx context.Context, bidID string, txs [][]byte, pubKeyType string, pubKeyBytes []byte, signature []byte) (*Bid, error) {
 			return nil, err
 		},
 		ApplyFunc: func(ctx context.Context, validatorAddr string, paymentAddr string) (*Challenge, error) {
@@ -76,12 +99,24 @@ func NewMockServiceErr(chainID string, err error) *MockService {
 		RegisterFunc: func(ctx context.Context, challengeID string, signature []byte) (*Validator, error) {
 			return nil, err
 		},
+		ApplySearcherFunc: func(ctx context.Context, pubKeyType string, pubKeyBytes []byte) (*SearcherChallenge, error) {
+			return nil, err
+		},
+		RegisterSearcherFunc: func(ctx context.Context, challengeID string, signature []byte) (*Searcher, error) {
+			return nil, err
+		},
 		BuildFunc: func(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) ([][]byte, string, error) {
 			return nil, "", err
 		},
 		BuildV1Func: func(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) ([][]byte, string, error) {
 			return nil, "", err
 		},
+		PreviewFunc: func(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) (*Preview, error) {
+			return nil, err
+		},
+		TrackInclusionFunc: func(ctx context.Context) error {
+			return err
+		},
 	}
 }
 
@@ -97,8 +132,16 @@ func (m *MockService) Auction(ctx context.Context, height int64) (*Auction, erro
 	return m.AuctionFunc(ctx, height)
 }
 
-func (m *MockService) Bid(ctx context.Context, height int64, kind string, txs [][]byte) (*Bid, error) {
-	return m.BidFunc(ctx, height, kind, txs)
+func (m *MockService) Bid(ctx context.Context, height, maxHeight int64, kind string, targetTxHash string, txs [][]byte, searcherID string, signature []byte) (*Bid, error) {
+	return m.BidFunc(ctx, height, maxHeight, kind, targetTxHash, txs, searcherID, signature)
+}
+
+func (m *MockService) CancelBid(ctx context.Context, bidID string, pubKeyType string, pubKeyBytes []byte, signature []byte) error {
+	return m.CancelBidFunc(ctx, bidID, pubKeyType, pubKeyBytes, signature)
+}
+
+func (m *MockService) ReplaceBid(ctx context.Context, bidID string, txs [][]byte, pubKeyType string, pubKeyBytes []byte, signature []byte) (*Bid, error) {
+	return m.ReplaceBidFunc(ctx, bidID, txs, pubKeyType, pubKeyBytes, signature)
 }
 
 func (m *MockService) Apply(ctx context.Context, validatorAddr string, paymentAddr string) (*Challenge, error) {
@@ -109,6 +152,14 @@ func (m *MockService) Register(ctx context.Context, challengeID string, signatur
 	return m.RegisterFunc(ctx, challengeID, signature)
 }
 
+func (m *MockService) ApplySearcher(ctx context.Context, pubKeyType string, pubKeyBytes []byte) (*SearcherChallenge, error) {
+	return m.ApplySearcherFunc(ctx, pubKeyType, pubKeyBytes)
+}
+
+func (m *MockService) RegisterSearcher(ctx context.Context, challengeID string, signature []byte) (*Searcher, error) {
+	return m.RegisterSearcherFunc(ctx, challengeID, signature)
+}
+
 func (m *MockService) Build(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) ([][]byte, string, error) {
 	return m.BuildFunc(ctx, height, validatorAddr, maxBytes, maxGas, txs, signature)
 }
@@ -117,22 +168,46 @@ func (m *MockService) BuildV1(ctx context.Context, height int64, validatorAddr s
 	return m.BuildV1Func(ctx, height, validatorAddr, maxBytes, maxGas, txs, signature)
 }
 
+func (m *MockService) Preview(ctx context.Context, height int64, validatorAddr string, maxBytes, maxGas int64, txs [][]byte, signature []byte) (*Preview, error) {
+	return m.PreviewFunc(ctx, height, validatorAddr, maxBytes, maxGas, txs, signature)
+}
+
+func (m *MockService) TrackInclusion(ctx context.Context) error {
+	return m.TrackInclusionFunc(ctx)
+}
+
 //
 //
 //
 
 type CoreService struct {
-	chain chain.Chain
-	store store.Store
+	chain      chain.Chain
+	store      store.Store
+	prices     PriceSource   // optional
+	prefetches chan struct{} // semaphore for balance prefetches
 }
 
 var _ Service = (*CoreService)(nil)
 
-func NewCoreService(c chain.Chain, s store.Store) *CoreService {
-	return &CoreService{
-		chain: c,
-		store: s,
+// CoreServiceOption configures optional parts of a CoreService.
+type CoreServiceOption func(*CoreService)
+
+// WithPriceSource sets the source of conversion rates for accepted payment
+// denoms. Without one, the static rates of the chain are used.
+func WithPriceSource(p PriceSource) CoreServiceOption {
+	return func(s *CoreService) { s.prices = p }
+}
+
+func NewCoreService(c chain.Chain, s store.Store, options ...CoreServiceOption) *CoreService {
+	cs := &CoreService{
+		chain:      c,
+		store:      s,
+		prefetches: make(chan struct{}, MaxBalancePrefetches),
+	}
+	for _, option := range options {
+		option(cs)
 	}
+	return cs
 }
 
 func (s *CoreService) ChainID() string {
@@ -159,29 +234,41 @@ func (s *CoreService) Auction(ctx context.Context, height int64) (_ *Auction, er
 
 	eztrc.Tracef(ctx, "requested auction height %d", height)
 
+	return s.auction(ctx, height)
+}
+
+// auction is Auction without the metrics, for internal use.
+func (s *CoreService) auction(ctx context.Context, height int64) (*Auction, error) {
 	var auction *Auction
-	{
-		if err := s.store.Transact(ctx, func(tx store.Store) error {
-			a, _, err := verifyAuction(ctx, s.chain, height, 10, tx)
-			if err != nil {
-				return err
-			}
-			auction = a
-			return nil
-		}); err != nil {
-			return nil, err
+	if err := s.store.Transact(ctx, func(tx store.Store) error {
+		a, _, err := verifyAuction(ctx, s.chain, s.prices, height, 10, tx)
+		if err != nil {
+			return err
 		}
+		auction = a
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	return auction, nil
 }
 
-func (s *CoreService) Bid(ctx context.Context, height int64, kind string, txs [][]byte) (_ *Bid, err error) {
+// MaxBidHeightRange is the most auctions beyond its first that a bid can be
+// eligible for.
+const MaxBidHeightRange = 10
+
+// Bid places a bid. If a searcher ID is given, the signature must be from that
+// searcher, over BidSignBytes, and the bid is recorded as the searcher's.
+func (s *CoreService) Bid(ctx context.Context, height, maxHeight int64, kind string, targetTxHash string, txs [][]byte, searcherID string, signature []byte) (_ *Bid, err error) {
 	ctx = trc.PrefixContextf(ctx, "[Bid]")
 
 	eztrc.Tracef(ctx, "height %d", height)
+	eztrc.Tracef(ctx, "max height %d", maxHeight)
 	eztrc.Tracef(ctx, "kind %s", kind)
+	eztrc.Tracef(ctx, "target tx hash %q", targetTxHash)
 	eztrc.Tracef(ctx, "tx count %d", len(txs))
+	eztrc.Tracef(ctx, "searcher ID %q", searcherID)
 
 	defer func() {
 		switch {
@@ -194,30 +281,318 @@ func (s *CoreService) Bid(ctx context.Context, height int64, kind string, txs []
 		}
 	}()
 
-	auction, err := s.Auction(ctx, height)
-	if err != nil {
-		return nil, fmt.Errorf("fetch auction: %w", err)
+	switch {
+	case maxHeight == 0:
+		// only eligible for height
+	case maxHeight < height:
+		return nil, fmt.Errorf("%w: max height %d is before height %d", ErrInvalidRequest, maxHeight, height)
+	case maxHeight-height > MaxBidHeightRange:
+		return nil, fmt.Errorf("%w: max height %d is more than %d past height %d", ErrInvalidRequest, maxHeight, MaxBidHeightRange, height)
 	}
 
 	bid := &Bid{
-		ChainID: auction.ChainID,
-		Height:  auction.Height,
-		Kind:    store.ParseBidKind(kind),
-		State:   store.BidStatePending,
-		Txs:     txs,
+		ChainID:      s.chain.ID(),
+		Height:       height,
+		MaxHeight:    maxHeight,
+		Kind:         store.ParseBidKind(kind),
+		TargetTxHash: targetTxHash,
+		State:        store.BidStatePending,
+		Txs:          txs,
 	}
 
-	if err := evaluateBid(ctx, s.chain, auction, bid); err != nil {
-		return nil, fmt.Errorf("evaluate bid: %w", err)
+	switch {
+	case searcherID != "":
+		// Sign bytes are over the bid as submitted, before evaluation normalizes it.
+		msg := BidSignBytes(s.chain.ID(), height, maxHeight, kind, targetTxHash, txs)
+		searcher, err := s.authenticateSearcher(ctx, searcherID, msg, signature)
+		if err != nil {
+			return nil, err
+		}
+		if err := admitSearcher(ctx, searcher.ID.String()); err != nil {
+			return nil, err
+		}
+		bid.SearcherID = &searcher.ID
+	case len(signature) > 0:
+		return nil, fmt.Errorf("%w: signature without searcher ID", ErrInvalidRequest)
+	}
+
+	if err := s.evaluateBidRange(ctx, bid); err != nil {
+		return nil, err
 	}
 
 	if err := s.store.InsertBid(ctx, bid); err != nil {
 		return nil, fmt.Errorf("place bid: %w", err)
 	}
 
+	s.prefetchBalances(ctx, bid)
+
 	return bid, nil
 }
 
+func (s *CoreService) CancelBid(ctx context.Context, bidID string, pubKeyType string, pubKeyBytes []byte, signature []byte) (err error) {
+	ctx = trc.PrefixContextf(ctx, "[CancelBid]")
+
+	defer func() {
+		result := boolString(err == nil, "success", "error")
+		metrics.BidChangesTotal.WithLabelValues(s.chain.ID(), "cancel", result).Inc()
+	}()
+
+	eztrc.Tracef(ctx, "bid ID %s", bidID)
+
+	msg := CancelBidSignBytes(s.chain.ID(), bidID)
+
+	return s.store.Transact(ctx, func(tx store.Store) error {
+		bid, err := s.authorizeBidChange(ctx, tx, bidID, msg, pubKeyType, pubKeyBytes, signature)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.DeleteBid(ctx, bid.ID.String()); err != nil {
+			return fmt.Errorf("delete bid: %w", err)
+		}
+
+		eztrc.Tracef(ctx, "canceled bid on %s/%d", bid.ChainID, bid.Height)
+
+		return nil
+	})
+}
+
+func (s *CoreService) ReplaceBid(ctx context.Context, bidID string, txs [][]byte, pubKeyType string, pubKeyBytes []byte, signature []byte) (_ *Bid, err error) {
+	ctx = trc.PrefixContextf(ctx, "[ReplaceBid]")
+
+	defer func() {
+		result := boolString(err == nil, "success", "error")
+		metrics.BidChangesTotal.WithLabelValues(s.chain.ID(), "replace", result).Inc()
+	}()
+
+	eztrc.Tracef(ctx, "bid ID %s", bidID)
+	eztrc.Tracef(ctx, "tx count %d", len(txs))
+
+	// Sign bytes are over the txs as submitted, before evaluation normalizes them.
+	msg := ReplaceBidSignBytes(s.chain.ID(), bidID, txs)
+
+	// Authorize the change before the new txs are evaluated, so that attempts
+	// by anyone else don't get them checked by the chain. It's authorized
+	// again when the bid is replaced, in case the bid changed meanwhile.
+	oldBid, err := s.authorizeBidChange(ctx, s.store, bidID, msg, pubKeyType, pubKeyBytes, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	bid := &Bid{
+		ChainID:      oldBid.ChainID,
+		Height:       oldBid.Height,
+		MaxHeight:    oldBid.MaxHeight,
+		Kind:         oldBid.Kind,
+		TargetTxHash: oldBid.TargetTxHash,
+		SearcherID:   oldBid.SearcherID,
+		State:        store.BidStatePending,
+		Txs:          txs,
+	}
+
+	if err := s.evaluateBidRange(ctx, bid); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Transact(ctx, func(tx store.Store) error {
+		if _, err := s.authorizeBidChange(ctx, tx, bidID, msg, pubKeyType, pubKeyBytes, signature); err != nil {
+			return err
+		}
+
+		if err := tx.ReplaceBid(ctx, bidID, bid); err != nil {
+			return fmt.Errorf("replace bid: %w", err)
+		}
+
+		eztrc.Tracef(ctx, "replaced with bid %s", bid.ID)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	s.prefetchBalances(ctx, bid)
+
+	return bid, nil
+}
+
+// balancePrefetchTimeout bounds fetching the balances of a bid's senders after
+// it's placed.
+const balancePrefetchTimeout = 10 * time.Second
+
+// MaxBalancePrefetches is the most bids a service prefetches balances for at
+// once. Prefetches for more bids are dropped.
+const MaxBalancePrefetches = 16
+
+// prefetchBalances fetches the balances of the bid's payment senders in the
+// background, at the height the auction will check them, so that they're
+// cached by the time the block is built. Errors are ignored, since the build
+// fetches any balance that's missing. Bids for later heights are skipped,
+// since their balances can't be queried until the height before is committed,
+// and so are bids placed while MaxBalancePrefetches are running.
+func (s *CoreService) prefetchBalances(ctx context.Context, bid *Bid) {
+	keys := map[balanceKey]struct{}{}
+	for _, p := range bid.Payments {
+		if p.Denom != "" {
+			keys[balanceKey{p.From, p.Denom}] = struct{}{}
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	height := bid.Height - 1
+	if latest, err := s.chain.LatestHeight(ctx); err != nil || height > latest {
+		eztrc.Tracef(ctx, "not prefetching balances at %d (latest %d, err %v)", height, latest, err)
+		return
+	}
+
+	select {
+	case s.prefetches <- struct{}{}:
+	default:
+		eztrc.Tracef(ctx, "not prefetching balances at %d, too many prefetches running", height)
+		return
+	}
+
+	go func() {
+		defer func() { <-s.prefetches }()
+		ctx, cancel := context.WithTimeout(context.Background(), balancePrefetchTimeout)
+		defer cancel()
+		fetchBalances(ctx, s.chain, height, keys)
+	}()
+}
+
+// evaluateBidRange evaluates a bid that's about to be placed against each
+// auction it's eligible for, from its Height through its MaxHeight, and moves
+// its Height to the first one where it's valid. If there's no such auction,
+// the error is from the last one tried. Then, its txs are checked, which
+// doesn't depend on the auction.
+func (s *CoreService) evaluateBidRange(ctx context.Context, bid *Bid) error {
+	if err := validateBidTarget(bid); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	var (
+		auction *Auction
+		err     error
+	)
+	for height := bid.Height; height <= bidMaxHeight(bid); height++ {
+		var a *Auction
+		if a, err = s.auction(ctx, height); err != nil {
+			err = fmt.Errorf("fetch auction: %w", err)
+			if errors.Is(err, ErrAuctionTooNew) {
+				break // so are the rest
+			}
+			continue
+		}
+
+		bid.ChainID, bid.Height = a.ChainID, a.Height
+		if err = evaluateBid(ctx, s.chain, a, bid); err == nil {
+			auction = a
+			break
+		}
+
+		err = fmt.Errorf("evaluate bid: %w", err)
+		eztrc.Tracef(ctx, "not eligible for %d: %v", height, err)
+	}
+	if auction == nil {
+		return err
+	}
+
+	if err := checkBid(ctx, s.chain, bid); err != nil {
+		if errors.Is(err, chain.ErrInvalidTx) {
+			metrics.BidsEvaluatedTotal.WithLabelValues(auction.ChainID, "check tx failed").Inc()
+			return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+		return fmt.Errorf("check bid: %w", err)
+	}
+
+	return nil
+}
+
+// authenticateSearcher returns the searcher if the signature over msg is from
+// its registered pub key.
+func (s *CoreService) authenticateSearcher(ctx context.Context, searcherID string, msg []byte, signature []byte) (*Searcher, error) {
+	searcher, err := s.store.SelectSearcher(ctx, searcherID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, fmt.Errorf("%w: searcher %s not registered", chain.ErrBadSignature, searcherID)
+	case err != nil:
+		return nil, fmt.Errorf("get searcher: %w", err)
+	}
+
+	if searcher.ChainID != s.chain.ID() {
+		return nil, fmt.Errorf("%w: searcher %s not registered on %s", chain.ErrBadSignature, searcherID, s.chain.ID())
+	}
+
+	if err := s.chain.VerifySignature(ctx, searcher.PubKeyType, searcher.PubKeyBytes, msg, signature); err != nil {
+		return nil, fmt.Errorf("verify searcher signature: %w", err)
+	}
+
+	eztrc.Tracef(ctx, "signed by searcher %s (%s)", searcher.ID, searcher.Address)
+
+	return searcher, nil
+}
+
+// authorizeBidChange returns the bid if it can still be changed, and the
+// signature over msg is from one of the addresses paying for the bid, or from
+// the searcher that signed it.
+func (s *CoreService) authorizeBidChange(ctx context.Context, tx store.Store, bidID string, msg []byte, pubKeyType string, pubKeyBytes []byte, signature []byte) (*Bid, error) {
+	bid, err := tx.SelectBid(ctx, bidID)
+	if err != nil {
+		return nil, fmt.Errorf("get bid: %w", err)
+	}
+
+	if bid.ChainID != s.chain.ID() {
+		return nil, fmt.Errorf("bid %s on %s: %w", bidID, s.chain.ID(), store.ErrNotFound)
+	}
+
+	// A bid that's still eligible for later auctions can be changed until the
+	// last of them has finished.
+	height := bid.Height
+	if bidEligible(bid) {
+		height = bidMaxHeight(bid)
+	}
+
+	auction, err := tx.SelectAuction(ctx, bid.ChainID, height)
+	switch {
+	case err == nil && !auction.FinishedAt.IsZero():
+		return nil, fmt.Errorf("%s/%d: %w", auction.ChainID, auction.Height, ErrAuctionFinished)
+	case errors.Is(err, store.ErrNotFound) && height != bid.Height:
+		eztrc.Tracef(ctx, "auction %d not started", height)
+	case err != nil:
+		return nil, fmt.Errorf("get auction: %w", err)
+	}
+
+	if err := s.chain.VerifySignature(ctx, pubKeyType, pubKeyBytes, msg, signature); err != nil {
+		return nil, fmt.Errorf("verify signature: %w", err)
+	}
+
+	addr, err := s.chain.AccountAddress(ctx, pubKeyType, pubKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("get signer address: %w", err)
+	}
+
+	for _, p := range bid.Payments {
+		if sameAddr(p.From, addr) {
+			eztrc.Tracef(ctx, "signed by payer %s", addr)
+			return bid, nil
+		}
+	}
+
+	if bid.SearcherID != nil {
+		searcher, err := tx.SelectSearcher(ctx, bid.SearcherID.String())
+		if err != nil {
+			return nil, fmt.Errorf("get searcher: %w", err)
+		}
+		if bytes.Equal(searcher.PubKeyBytes, pubKeyBytes) {
+			eztrc.Tracef(ctx, "signed by searcher %s", searcher.ID)
+			return bid, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: signer %s doesn't pay for bid %s", chain.ErrBadSignature, addr, bidID)
+}
+
 func (s *CoreService) Apply(ctx context.Context, validatorAddr string, paymentAddr string) (_ *Challenge, err error) {
 	ctx = trc.PrefixContextf(ctx, "[Apply]")
 
@@ -348,6 +723,100 @@ func (s *CoreService) Register(ctx context.Context, challengeID string, signatur
 	return validator, nil
 }
 
+func (s *CoreService) ApplySearcher(ctx context.Context, pubKeyType string, pubKeyBytes []byte) (_ *SearcherChallenge, err error) {
+	ctx = trc.PrefixContextf(ctx, "[ApplySearcher]")
+
+	defer func() {
+		result := boolString(err == nil, "challenged", "error")
+		metrics.SearcherRegisterRequestsTotal.WithLabelValues(s.chain.ID(), "apply", result).Inc()
+	}()
+
+	eztrc.Tracef(ctx, "pub key type %s, %dB", pubKeyType, len(pubKeyBytes))
+
+	if pubKeyType == "" || len(pubKeyBytes) == 0 {
+		return nil, fmt.Errorf("%w: pub key required", ErrInvalidRequest)
+	}
+
+	// The same check the signature on the challenge gets, but earlier.
+	if _, err := s.chain.AccountAddress(ctx, pubKeyType, pubKeyBytes); err != nil {
+		return nil, fmt.Errorf("%w: pub key: %v", ErrInvalidRequest, err)
+	}
+
+	c := &SearcherChallenge{
+		ChainID:     s.chain.ID(),
+		PubKeyBytes: pubKeyBytes,
+		PubKeyType:  pubKeyType,
+		Challenge:   cryptoutil.RandomBytes(32),
+	}
+
+	if err := s.store.InsertSearcherChallenge(ctx, c); err != nil {
+		return nil, fmt.Errorf("insert searcher challenge: %w", err)
+	}
+
+	eztrc.Tracef(ctx, "issuing challenge ID %s", c.ID)
+
+	return c, nil
+}
+
+func (s *CoreService) RegisterSearcher(ctx context.Context, challengeID string, signature []byte) (_ *Searcher, err error) {
+	ctx = trc.PrefixContextf(ctx, "[RegisterSearcher]")
+
+	defer func() {
+		result := boolString(err == nil, "success", "error")
+		metrics.SearcherRegisterRequestsTotal.WithLabelValues(s.chain.ID(), "register", result).Inc()
+	}()
+
+	eztrc.Tracef(ctx, "challenge ID %s", challengeID)
+
+	var searcher *Searcher
+	if err := s.store.Transact(ctx, func(tx store.Store) error {
+		challenge, err := tx.SelectSearcherChallenge(ctx, challengeID)
+		if err != nil {
+			return fmt.Errorf("retrieve challenge: %w", err)
+		}
+
+		// Outside of the transaction, so a failed attempt uses up the challenge.
+		defer func() {
+			if err := s.store.DeleteSearcherChallenge(ctx, challenge.ID.String()); err != nil {
+				eztrc.Errorf(ctx, "delete searcher challenge %s: %v", challengeID, err)
+			}
+		}()
+
+		if challenge.ChainID != s.chain.ID() {
+			return fmt.Errorf("challenge %s on %s: %w", challengeID, s.chain.ID(), store.ErrNotFound)
+		}
+
+		msg := SearcherChallengeSignBytes(challenge.ChainID, challenge.Challenge)
+		if err := s.chain.VerifySignature(ctx, challenge.PubKeyType, challenge.PubKeyBytes, msg, signature); err != nil {
+			return err
+		}
+
+		addr, err := s.chain.AccountAddress(ctx, challenge.PubKeyType, challenge.PubKeyBytes)
+		if err != nil {
+			return fmt.Errorf("get searcher address: %w", err)
+		}
+
+		searcher = &Searcher{
+			ChainID:     challenge.ChainID,
+			Address:     addr,
+			PubKeyBytes: challenge.PubKeyBytes,
+			PubKeyType:  challenge.PubKeyType,
+		}
+
+		if err := tx.UpsertSearcher(ctx, searcher); err != nil {
+			return fmt.Errorf("upsert searcher: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	eztrc.Tracef(ctx, "searcher ID %s, address %s", searcher.ID, searcher.Address)
+
+	return searcher, nil
+}
+
 func (s *CoreService) Build(
 	ctx context.Context,
 	height int64,
@@ -371,16 +840,30 @@ func (s *CoreService) Build(
 	tr.Tracef("max bytes %d, max gas %d, tx count %d", maxBytes, maxGas, len(txs))
 	tr.Tracef("signature %dB", len(signature))
 
+	txsHash := mekabuild.HashTxs(txs...)
+
 	var auction *Auction
 	var proposer *Validator
 	var allBids []*Bid
+	var claimed bool
 	{
 		// Run an atomic transaction to verify and claim the auction.
 		if err := s.store.Transact(ctx, func(tx store.Store) error {
 			// Verify we can build this auction.
 			{
-				a, v, err := verifyAuction(ctx, s.chain, height, 2, tx)
-				if err != nil {
+				a, v, err := verifyAuction(ctx, s.chain, s.prices, height, 2, tx)
+				if errors.Is(err, ErrAuctionFinished) {
+					// The validator may be retrying, which is only allowed
+					// for the validator of the auction, so it's authenticated
+					// below like any other build request.
+					if a, err = tx.SelectAuction(ctx, chainID, height); err != nil {
+						return fmt.Errorf("get finished auction: %w", err)
+					}
+					if v, err = tx.SelectValidator(ctx, chainID, a.ValidatorAddress); err != nil {
+						return fmt.Errorf("get auction validator: %w", err)
+					}
+					claimed = true
+				} else if err != nil {
 					return fmt.Errorf("verify auction: %w", err)
 				}
 
@@ -396,13 +879,17 @@ func (s *CoreService) Build(
 				// key and we verify a signature here, a rogue actor can't
 				// impersonate a validator by giving us a validator address that
 				// they don't own.
-				txsHash := mekabuild.HashTxs(txs...)
 				msg := mekabuild.BuildBlockRequestSignBytes(chainID, height, validatorAddr, maxBytes, maxGas, txsHash)
 				if err := s.chain.VerifySignature(ctx, proposer.PubKeyType, proposer.PubKeyBytes, msg, signature); err != nil {
 					return err
 				}
 			}
 
+			// A retry doesn't claim anything.
+			if claimed {
+				return nil
+			}
+
 			// Mark the auction as finished and UPSERT to the store.
 			{
 				auction.FinishedAt = time.Now().UTC()
@@ -427,6 +914,18 @@ func (s *CoreService) Build(
 			return nil, "", fmt.Errorf("claim failed: %w", err)
 		}
 
+		// If this exact request already claimed the auction, the validator is
+		// retrying, and should get the same block as before.
+		if claimed {
+			previous, err := s.awaitBuildResult(ctx, auction, validatorAddr, maxBytes, maxGas, txsHash, signature)
+			if err != nil {
+				return nil, "", fmt.Errorf("claim failed: %w", err)
+			}
+			tr.Tracef("returning previous build result, tx count %d, validator payment %s", len(previous.Txs), previous.ValidatorPayment)
+			metrics.BuildResultsReusedTotal.WithLabelValues(chainID).Inc()
+			return previous.Txs, previous.ValidatorPayment, nil
+		}
+
 		// If bidders made bids sending payments to a proposer which is no
 		// longer the actual proposer for the block, then we should fail the
 		// auction.
@@ -436,6 +935,12 @@ func (s *CoreService) Build(
 		}
 	}
 
+	// The auction is claimed, so the build has to finish and store its result
+	// even if the request is canceled, e.g. by a client timeout, so that the
+	// validator's retry gets the same block.
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, claimedBuildTimeout)
+	defer cancel()
+
 	{
 		tr.Tracef("total bid count %d", len(allBids))
 		for _, bid := range allBids {
@@ -452,13 +957,33 @@ func (s *CoreService) Build(
 		}
 	}
 
-	var txBundles []*txBundle
+	// Capture the strategy for picking winning bids.
+	var selector bidSelector
+	{
+		c, err := s.store.SelectChain(ctx, chainID)
+		if err != nil {
+			return nil, "", fmt.Errorf("query for chain: %w", err)
+		}
+
+		selector = newBidSelector(store.ParseBidSelection(string(c.BidSelection)))
+		tr.Tracef("bid selection %s", c.BidSelection)
+	}
+
+	var (
+		txBundles    []*txBundle
+		acceptedBids []*Bid
+		usedBytes    int64
+		usedGas      int64
+	)
 	{
+		// Bids placed for earlier auctions may still be eligible for this one.
+		allBids = evaluateRangeBids(ctx, s.chain, auction, allBids)
+
 		// Pick the winning bids for the auction. Those bids establish an implicit,
 		// ordered set of transactions to be included in the block. The original
 		// mempool transactions which were not included in those bids are also
 		// computed and returned.
-		winningBids, losingBids, remainingTxs, err := computeOrder(ctx, s.chain, auction, allBids, txs)
+		winningBids, losingBids, remainingTxs, err := computeOrder(ctx, s.chain, auction, allBids, txs, selector, maxBytes, maxGas)
 		if err != nil {
 			return nil, "", fmt.Errorf("compute winning bids: %w", err)
 		}
@@ -466,13 +991,19 @@ func (s *CoreService) Build(
 		tr.Tracef("winning bid count %d, remaining tx count %d", len(winningBids), len(remainingTxs))
 
 		// Select transactions to go in the block, respecting capacity limits.
-		bs, acceptedBids, rejectedBids, usedBytes, usedGas := selectTransactions(ctx, s.chain, winningBids, remainingTxs, maxBytes, maxGas)
+		bs, ab, rejectedBids, ub, ug := selectTransactions(ctx, s.chain, winningBids, remainingTxs, maxBytes, maxGas)
+		acceptedBids = ab
 
 		tr.Tracef("winning bid count %d, losing bid count %d", len(winningBids), len(losingBids))
 		tr.Tracef("remaining tx count %d", len(remainingTxs))
 		tr.Tracef("accepted winning bid count %d, rejected winning bid count %d", len(acceptedBids), len(rejectedBids))
 		tr.Tracef("ultimate block tx count %d", len(bs))
-		tr.Tracef("%d/%d bytes, %d/%d gas", usedBytes, maxBytes, usedGas, maxGas)
+		tr.Tracef("%d/%d bytes, %d/%d gas", ub, maxBytes, ug, maxGas)
+
+		// Accepted bids are consumed by this auction.
+		for _, bid := range acceptedBids {
+			bid.Height = auction.Height
+		}
 
 		// Both computeOrder and selectTransactions mutate each bid.State as they partition into winning, losing,
 		// accepted and rejected groups for tracing.
@@ -480,18 +1011,20 @@ func (s *CoreService) Build(
 			return nil, "", fmt.Errorf("update bids state: %w", err)
 		}
 
-		txBundles = bs
+		txBundles, usedBytes, usedGas = bs, ub, ug
 	}
 
 	var blockTxs [][]byte
+	var buildTxs []store.BuildTx
 	var validatorPayment int64
 	var mekatekPayment int64
 	{
 		var sources []string
 		for _, b := range txBundles {
 			blockTxs = append(blockTxs, b.txs...)
-			for range b.txs {
+			for _, tx := range b.txs {
 				sources = append(sources, b.source)
+				buildTxs = append(buildTxs, newBuildTx(tx, b))
 			}
 			validatorPayment += b.validatorPayment
 			mekatekPayment += b.mekatekPayment
@@ -510,9 +1043,40 @@ func (s *CoreService) Build(
 	metrics.PaymentsTotal.WithLabelValues(chainID, auction.PaymentDenom, "validator").Add(float64(validatorPayment))
 	metrics.PaymentsTotal.WithLabelValues(chainID, auction.PaymentDenom, "mekatek").Add(float64(mekatekPayment))
 
+	payment := validatorCoins(auction, acceptedBids)
+
+	if err := s.store.InsertBuildResult(ctx, &store.BuildResult{
+		ChainID:          chainID,
+		Height:           height,
+		ValidatorAddress: validatorAddr,
+		MaxBytes:         maxBytes,
+		MaxGas:           maxGas,
+		TxsHash:          txsHash,
+		Signature:        signature,
+		Txs:              blockTxs,
+		ValidatorPayment: payment,
+	}); err != nil {
+		eztrc.Errorf(ctx, "[Build] store build result: %v", err) // retries will fail, but this block is still good
+	}
+
+	if err := s.store.InsertBuild(ctx, &store.Build{
+		ChainID:          chainID,
+		Height:           height,
+		ValidatorAddress: validatorAddr,
+		MaxBytes:         maxBytes,
+		MaxGas:           maxGas,
+		UsedBytes:        usedBytes,
+		UsedGas:          usedGas,
+		PaymentDenom:     auction.PaymentDenom,
+		ValidatorPayment: validatorPayment,
+		MekatekPayment:   mekatekPayment,
+		Txs:              buildTxs,
+	}); err != nil {
+		eztrc.Errorf(ctx, "[Build] store build record: %v", err) // audit only, this block is still good
+	}
+
 	tr.Tracef("success")
 
-	payment := fmt.Sprintf("%d%s", validatorPayment, auction.PaymentDenom)
 	return blockTxs, payment, nil
 }
 
@@ -540,65 +1104,25 @@ func (s *CoreService) BuildV1(
 	eztrc.Tracef(ctx, "signature %dB", len(signature))
 
 	// Verify we operate on the chain, and capture payment metadata.
-	var mekatekPaymentAddress string
-	var paymentDenom string
+	var ch *store.Chain
 	{
 		c, err := s.store.SelectChain(ctx, chainID)
 		if err != nil {
 			return nil, "", fmt.Errorf("query for chain: %w", err)
 		}
 
-		mekatekPaymentAddress = c.MekatekPaymentAddress
-		paymentDenom = c.PaymentDenom
+		ch = c
 	}
 
 	// Get a (valid) valset for the height, and make sure the caller can build it.
-	var latestHeightValset *chain.ValidatorSet
-	var buildHeightProposer *chain.Validator // latestHeight + 1
-	{
-		latestHeight, err := s.chain.LatestHeight(ctx)
-		if err != nil {
-			return nil, "", fmt.Errorf("get latest height: %w", err)
-		}
-
-		// TODO: should we enforce build height == latestHeight + 1?
-		vs, err := s.chain.ValidatorSet(ctx, latestHeight)
-		if err != nil {
-			return nil, "", fmt.Errorf("get validator set: %w", err)
-		}
-		if vs.Height != latestHeight {
-			return nil, "", fmt.Errorf("mismatch: latest height %d, validator set height %d", latestHeight, vs.Height)
-		}
-
-		minHeight := latestHeight
-		maxHeight := latestHeight + 2
-
-		eztrc.Tracef(ctx, "heights: latest %d, build %d, max %d", latestHeight, buildHeight, maxHeight)
-
-		if buildHeight < minHeight {
-			return nil, "", fmt.Errorf("%s/%d: %w", chainID, buildHeight, ErrAuctionTooOld)
-		}
-
-		if buildHeight > maxHeight {
-			return nil, "", fmt.Errorf("%s/%d: %w", chainID, buildHeight, ErrAuctionTooNew)
-		}
-
-		p, err := s.chain.PredictProposer(ctx, vs, buildHeight)
-		if err != nil {
-			return nil, "", fmt.Errorf("predict proposer for build height %d: %w", buildHeight, err)
-		}
-
-		if p.Address != validatorAddr {
-			return nil, "", fmt.Errorf("wrong proposer %q for height %d, want %q", validatorAddr, buildHeight, p.Address)
-		}
-
-		latestHeightValset = vs
-		buildHeightProposer = p
+	latestHeightValset, buildHeightProposer, err := s.verifyProposer(ctx, buildHeight, validatorAddr)
+	if err != nil {
+		return nil, "", err
 	}
 
 	// Verify the build request has been signed by the correct proposer.
+	txsHash := mekabuild.HashTxs(txs...)
 	{
-		txsHash := mekabuild.HashTxs(txs...)
 		msg := mekabuild.BuildBlockRequestSignBytes(chainID, buildHeight, validatorAddr, maxBytes, maxGas, txsHash)
 		if err := s.chain.VerifySignature(ctx, buildHeightProposer.PubKeyType, buildHeightProposer.PubKeyBytes, msg, signature); err != nil {
 			return nil, "", err
@@ -621,45 +1145,23 @@ func (s *CoreService) BuildV1(
 		}
 	}
 
-	// Claim the auction, and get any submitted bids.
+	// Claim the auction, and get any submitted bids. If the auction has
+	// already been claimed, the validator may be retrying, and we look for the
+	// previous result instead.
 	var auction *store.Auction
 	var bids []*store.Bid
+	var claimed bool
 	if err := s.store.Transact(ctx, func(tx store.Store) error {
 		a, err := tx.SelectAuction(ctx, chainID, buildHeight)
-		switch {
-		case err == nil:
-			eztrc.Tracef(ctx, "auction found")
-
-		case errors.Is(err, store.ErrNotFound):
-			eztrc.Tracef(ctx, "auction not found, calculating allocation and creating")
-
-			registered, err := tx.ListValidators(ctx, chainID)
-			if err != nil {
-				return fmt.Errorf("fetch registered validators: %w", err)
-			}
-
-			var registeredPower int64
-			for _, v := range registered {
-				if v, ok := latestHeightValset.Set[v.Address]; ok {
-					eztrc.Tracef(ctx, "registered validator %s has voting power %d", v.Address, v.VotingPower)
-					registeredPower += v.VotingPower
-				}
-			}
-
-			const allocation = FixedAllocation
+		switch {
+		case err == nil:
+			eztrc.Tracef(ctx, "auction found")
 
-			eztrc.Tracef(ctx, "power: registered %d, total %d, allocation %.3f", registeredPower, latestHeightValset.TotalPower, allocation)
+		case errors.Is(err, store.ErrNotFound):
+			eztrc.Tracef(ctx, "auction not found, calculating allocation and creating")
 
-			a = &store.Auction{
-				ChainID:                 chainID,
-				Height:                  buildHeight,
-				ValidatorAddress:        buildHeightProposer.Address,
-				ValidatorAllocation:     allocation,
-				ValidatorPaymentAddress: buildHeightProposer.PaymentAddress,
-				MekatekPaymentAddress:   mekatekPaymentAddress,
-				PaymentDenom:            paymentDenom,
-				RegisteredPower:         registeredPower,
-				TotalPower:              latestHeightValset.TotalPower,
+			if a, err = s.newAuction(ctx, tx, ch, latestHeightValset, buildHeightProposer, buildHeight); err != nil {
+				return err
 			}
 
 			if err := tx.UpsertAuction(ctx, a); err != nil {
@@ -673,7 +1175,9 @@ func (s *CoreService) BuildV1(
 
 		if !a.FinishedAt.IsZero() {
 			eztrc.Tracef(ctx, "auction was finished at %s", traceTime(a.FinishedAt))
-			return ErrAuctionFinished
+			auction = a
+			claimed = true
+			return nil
 		}
 
 		if want, have := buildHeightProposer.Address, a.ValidatorAddress; want != have {
@@ -699,6 +1203,23 @@ func (s *CoreService) BuildV1(
 		return nil, "", fmt.Errorf("claim failed: %w", err)
 	}
 
+	// A retried request gets the same block as the original request.
+	if claimed {
+		previous, err := s.awaitBuildResult(ctx, auction, validatorAddr, maxBytes, maxGas, txsHash, signature)
+		if err != nil {
+			return nil, "", fmt.Errorf("claim failed: %w", err)
+		}
+		eztrc.Tracef(ctx, "returning previous build result, tx count %d, validator payment %s", len(previous.Txs), previous.ValidatorPayment)
+		metrics.BuildResultsReusedTotal.WithLabelValues(chainID).Inc()
+		return previous.Txs, previous.ValidatorPayment, nil
+	}
+
+	// The auction is claimed, so the build has to finish and store its result
+	// even if the request is canceled, e.g. by a client timeout, so that the
+	// validator's retry gets the same block.
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, claimedBuildTimeout)
+	defer cancel()
+
 	// Trace some information about the bids.
 	{
 		eztrc.Tracef(ctx, "total bid count %d", len(bids))
@@ -717,23 +1238,37 @@ func (s *CoreService) BuildV1(
 	}
 
 	// Select the tx bundles that will form the block.
-	var txBundles []*txBundle
+	var (
+		txBundles    []*txBundle
+		acceptedBids []*Bid
+		usedBytes    int64
+		usedGas      int64
+	)
 	{
+		// Bids placed for earlier auctions may still be eligible for this one.
+		bids = evaluateRangeBids(ctx, s.chain, auction, bids)
+
 		// Compute a priority order of valid bids, then add any remaining
 		// mempool transactions. This will be the block.
-		winningBids, losingBids, remainingTxs, err := computeOrder(ctx, s.chain, auction, bids, txs)
+		winningBids, losingBids, remainingTxs, err := computeOrder(ctx, s.chain, auction, bids, txs, newBidSelector(store.ParseBidSelection(string(ch.BidSelection))), maxBytes, maxGas)
 		if err != nil {
 			return nil, "", fmt.Errorf("compute block order: %w", err)
 		}
 
 		// Make sure the block respects capacity limits (e.g. bytes and gas) and set bid states.
-		bs, acceptedBids, rejectedBids, usedBytes, usedGas := selectTransactions(ctx, s.chain, winningBids, remainingTxs, maxBytes, maxGas)
+		bs, ab, rejectedBids, ub, ug := selectTransactions(ctx, s.chain, winningBids, remainingTxs, maxBytes, maxGas)
+		acceptedBids = ab
 
 		eztrc.Tracef(ctx, "winning bid count %d, losing bid count %d", len(winningBids), len(losingBids))
 		eztrc.Tracef(ctx, "remaining tx count %d", len(remainingTxs))
 		eztrc.Tracef(ctx, "accepted winning bid count %d, rejected winning bid count %d", len(acceptedBids), len(rejectedBids))
 		eztrc.Tracef(ctx, "ultimate block tx count %d", len(bs))
-		eztrc.Tracef(ctx, "%d/%d bytes, %d/%d gas", usedBytes, maxBytes, usedGas, maxGas)
+		eztrc.Tracef(ctx, "%d/%d bytes, %d/%d gas", ub, maxBytes, ug, maxGas)
+
+		// Accepted bids are consumed by this auction.
+		for _, bid := range acceptedBids {
+			bid.Height = auction.Height
+		}
 
 		// Both computeOrder and selectTransactions mutate each bid.State as they partition into winning, losing,
 		// accepted and rejected groups for tracing.
@@ -741,19 +1276,21 @@ func (s *CoreService) BuildV1(
 			return nil, "", fmt.Errorf("update bids state: %w", err)
 		}
 
-		txBundles = bs
+		txBundles, usedBytes, usedGas = bs, ub, ug
 	}
 
 	// Flatten the tx bundles and compute the payments.
 	var blockTxs [][]byte
+	var buildTxs []store.BuildTx
 	var validatorPayment int64
 	var mekatekPayment int64
 	{
 		var sources []string
 		for _, b := range txBundles {
 			blockTxs = append(blockTxs, b.txs...)
-			for range b.txs {
+			for _, tx := range b.txs {
 				sources = append(sources, b.source)
+				buildTxs = append(buildTxs, newBuildTx(tx, b))
 			}
 			validatorPayment += b.validatorPayment
 			mekatekPayment += b.mekatekPayment
@@ -775,12 +1312,233 @@ func (s *CoreService) BuildV1(
 		metrics.PaymentsTotal.WithLabelValues(chainID, auction.PaymentDenom, "mekatek").Add(float64(mekatekPayment))
 	}
 
+	payment := validatorCoins(auction, acceptedBids)
+
+	// Persist the result, so retries of this request get the same block.
+	{
+		r := &store.BuildResult{
+			ChainID:          chainID,
+			Height:           buildHeight,
+			ValidatorAddress: validatorAddr,
+			MaxBytes:         maxBytes,
+			MaxGas:           maxGas,
+			TxsHash:          txsHash,
+			Signature:        signature,
+			Txs:              blockTxs,
+			ValidatorPayment: payment,
+		}
+		if err := s.store.InsertBuildResult(ctx, r); err != nil {
+			eztrc.Errorf(ctx, "store build result: %v", err) // retries will fail, but this block is still good
+		}
+	}
+
+	// Persist the audit record of the build.
+	{
+		b := &store.Build{
+			ChainID:          chainID,
+			Height:           buildHeight,
+			ValidatorAddress: validatorAddr,
+			MaxBytes:         maxBytes,
+			MaxGas:           maxGas,
+			UsedBytes:        usedBytes,
+			UsedGas:          usedGas,
+			PaymentDenom:     auction.PaymentDenom,
+			ValidatorPayment: validatorPayment,
+			MekatekPayment:   mekatekPayment,
+			Txs:              buildTxs,
+		}
+		if err := s.store.InsertBuild(ctx, b); err != nil {
+			eztrc.Errorf(ctx, "store build record: %v", err) // audit only, this block is still good
+		}
+	}
+
 	eztrc.Tracef(ctx, "success")
 
-	payment := fmt.Sprintf("%d%s", validatorPayment, auction.PaymentDenom)
 	return blockTxs, payment, nil
 }
 
+// verifyProposer checks that validatorAddr is the predicted proposer for
+// height, which can be at most a couple of blocks past the latest height. It
+// returns the validator set at the latest height, and the proposer.
+func (s *CoreService) verifyProposer(ctx context.Context, height int64, validatorAddr string) (*chain.ValidatorSet, *chain.Validator, error) {
+	chainID := s.chain.ID()
+
+	latestHeight, err := s.chain.LatestHeight(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get latest height: %w", err)
+	}
+
+	// TODO: should we enforce build height == latestHeight + 1?
+	vs, err := s.chain.ValidatorSet(ctx, latestHeight)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get validator set: %w", err)
+	}
+	if vs.Height != latestHeight {
+		return nil, nil, fmt.Errorf("mismatch: latest height %d, validator set height %d", latestHeight, vs.Height)
+	}
+
+	minHeight := latestHeight
+	maxHeight := latestHeight + 2
+
+	eztrc.Tracef(ctx, "heights: latest %d, build %d, max %d", latestHeight, height, maxHeight)
+
+	if height < minHeight {
+		return nil, nil, fmt.Errorf("%s/%d: %w", chainID, height, ErrAuctionTooOld)
+	}
+
+	if height > maxHeight {
+		return nil, nil, fmt.Errorf("%s/%d: %w", chainID, height, ErrAuctionTooNew)
+	}
+
+	p, err := s.chain.PredictProposer(ctx, vs, height)
+	if err != nil {
+		return nil, nil, fmt.Errorf("predict proposer for build height %d: %w", height, err)
+	}
+
+	if p.Address != validatorAddr {
+		return nil, nil, fmt.Errorf("wrong proposer %q for height %d, want %q", validatorAddr, height, p.Address)
+	}
+
+	return vs, p, nil
+}
+
+// newAuction returns a new auction for height, with the proposer's allocation
+// based on the voting power of the registered validators. It isn't stored.
+func (s *CoreService) newAuction(ctx context.Context, tx store.Store, ch *store.Chain, vs *chain.ValidatorSet, proposer *chain.Validator, height int64) (*store.Auction, error) {
+	registered, err := tx.ListValidators(ctx, ch.ID)
+	if err != nil {
+		return nil, fmt.Errorf("fetch registered validators: %w", err)
+	}
+
+	var registeredPower int64
+	for _, v := range registered {
+		if v, ok := vs.Set[v.Address]; ok {
+			eztrc.Tracef(ctx, "registered validator %s has voting power %d", v.Address, v.VotingPower)
+			registeredPower += v.VotingPower
+		}
+	}
+
+	var (
+		allocation = chainAllocation(ch, proposer.Address, registeredPower, vs.TotalPower)
+		tolerance  = allocationTolerance(ch.AllocationTolerance)
+	)
+
+	eztrc.Tracef(ctx, "power: registered %d, total %d, allocation %.3f (%s), tolerance %.3f", registeredPower, vs.TotalPower, allocation, ch.AllocationPolicy, tolerance)
+
+	return &store.Auction{
+		ChainID:                 ch.ID,
+		Height:                  height,
+		ValidatorAddress:        proposer.Address,
+		ValidatorAllocation:     allocation,
+		AllocationTolerance:     tolerance,
+		ValidatorPaymentAddress: proposer.PaymentAddress,
+		MekatekPaymentAddress:   ch.MekatekPaymentAddress,
+		PaymentDenom:            ch.PaymentDenom,
+		PaymentDenomRates:       paymentDenomRates(ctx, s.prices, ch),
+		RegisteredPower:         registeredPower,
+		TotalPower:              vs.TotalPower,
+	}, nil
+}
+
+const (
+	inclusionBatchSize = 100 // most pending builds checked at once
+	inclusionMaxLag    = 100 // blocks behind the latest height, before a build that can't be checked is given up on
+)
+
+// TrackInclusion compares pending builds up to the latest height, lowest
+// first, against the block that was actually committed at that height. Builds
+// are marked as included, reordered, or dropped, along with the proposer of
+// the committed block, and their accepted bids are moved to included or
+// excluded. Builds whose block still can't be fetched once they're more than
+// inclusionMaxLag blocks old, e.g. because it was pruned, are marked unknown.
+func (s *CoreService) TrackInclusion(ctx context.Context) error {
+	ctx = trc.PrefixContextf(ctx, "[TrackInclusion]")
+
+	latestHeight, err := s.chain.LatestHeight(ctx)
+	if err != nil {
+		return fmt.Errorf("get latest height: %w", err)
+	}
+
+	builds, err := s.store.ListPendingBuilds(ctx, s.chain.ID(), latestHeight, inclusionBatchSize)
+	if err != nil {
+		return fmt.Errorf("list pending builds: %w", err)
+	}
+
+	eztrc.Tracef(ctx, "latest height %d, pending build count %d", latestHeight, len(builds))
+
+	var failed int
+	for _, b := range builds {
+		ctx := trc.PrefixContextf(ctx, "%d:", b.Height)
+
+		committed, err := s.chain.Block(ctx, b.Height)
+		if err != nil && latestHeight-b.Height > inclusionMaxLag {
+			eztrc.Errorf(ctx, "get block: %v, giving up", err)
+			b.InclusionState = store.BuildInclusionUnknown
+			if err := s.store.UpdateBuildInclusion(ctx, b); err != nil {
+				return fmt.Errorf("update build at %d: %w", b.Height, err)
+			}
+			metrics.BuildInclusionTotal.WithLabelValues(b.ChainID, "", string(b.InclusionState)).Inc()
+			continue
+		}
+		if err != nil {
+			eztrc.Errorf(ctx, "get block: %v", err) // e.g. pruned, don't let it block later heights
+			failed++
+			continue
+		}
+
+		buildState, bidStates := checkInclusion(b, committed.Txs)
+
+		eztrc.Tracef(ctx, "built for %s, proposed by %s: %s", b.ValidatorAddress, committed.ProposerAddress, buildState)
+
+		if err := s.store.Transact(ctx, func(tx store.Store) error {
+			bids, err := tx.ListBids(ctx, b.ChainID, b.Height)
+			if err != nil {
+				return fmt.Errorf("list bids: %w", err)
+			}
+
+			var updated []*Bid
+			for _, bid := range bids {
+				state, ok := bidStates[bid.ID]
+				if !ok || bid.State != store.BidStateAccepted {
+					continue
+				}
+				eztrc.Tracef(ctx, "bid %s: %s", bid.ID, state)
+				bid.State = state
+				if state == store.BidStateExcluded {
+					bid.RejectionReason = fmt.Sprintf("not committed as built by proposer %s", committed.ProposerAddress)
+				}
+				updated = append(updated, bid)
+			}
+
+			if err := tx.UpdateBids(ctx, updated...); err != nil {
+				return fmt.Errorf("update bids: %w", err)
+			}
+
+			b.InclusionState = buildState
+			b.ProposerAddress = committed.ProposerAddress
+			if err := tx.UpdateBuildInclusion(ctx, b); err != nil {
+				return fmt.Errorf("update build: %w", err)
+			}
+
+			for _, bid := range updated {
+				metrics.BidInclusionTotal.WithLabelValues(b.ChainID, string(bid.State)).Inc()
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("track inclusion at %d: %w", b.Height, err)
+		}
+
+		metrics.BuildInclusionTotal.WithLabelValues(b.ChainID, committed.ProposerAddress, string(buildState)).Inc()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to get %d/%d committed blocks", failed, len(builds))
+	}
+
+	return nil
+}
+
 //
 //
 //
@@ -814,11 +1572,13 @@ func evaluateBid(
 	}()
 
 	// Each bid will pay some amount of denom to us, and some to the validator.
-	// Those payments will come from a one or more addresses.
+	// Those payments will come from a one or more addresses, and may be in any
+	// of the accepted denoms, which are converted to the auction's denom.
 	var (
 		validatorPayment = int64(0)
 		mekatekPayment   = int64(0)
 		payments         []Payment
+		denoms           = paymentDenoms(auction)
 	)
 
 	// A bid contains N transactions.
@@ -850,29 +1610,37 @@ func evaluateBid(
 			ctx := trc.PrefixContextf(ctx, "msg %d/%d:", j+1, len(msgs))
 
 			// Each message may contain payments.
-			src, dst, amount, err := c.GetPayment(ctx, msg, auction.PaymentDenom)
+			msgPayments, err := c.GetPayments(ctx, msg, denoms)
 			if err != nil {
 				eztrc.Tracef(ctx, "ignoring %T: %v", msg, err)
 				continue
 			}
 
-			// We only care about specific payments.
-			var (
-				toValidator = sameAddr(dst, auction.ValidatorPaymentAddress)
-				toMekatek   = sameAddr(dst, auction.MekatekPaymentAddress)
-			)
+			for _, p := range msgPayments {
+				// We only care about specific payments.
+				var (
+					toValidator = sameAddr(p.To, auction.ValidatorPaymentAddress)
+					toMekatek   = sameAddr(p.To, auction.MekatekPaymentAddress)
+				)
+
+				value, ok := paymentValue(auction, p.Denom, p.Amount)
+				if !ok {
+					eztrc.Tracef(ctx, "%s send %d %s to %s: unaccepted denom, ignoring", p.From, p.Amount, p.Denom, p.To)
+					continue
+				}
 
-			switch {
-			case toValidator:
-				eztrc.Tracef(ctx, "%s send %d to %s (validator)", src, amount, dst)
-				payments = append(payments, Payment{From: src, To: dst, Amount: amount})
-				validatorPayment += amount
-			case toMekatek:
-				eztrc.Tracef(ctx, "%s send %d to %s (mekatek)", src, amount, dst)
-				payments = append(payments, Payment{From: src, To: dst, Amount: amount})
-				mekatekPayment += amount
-			default:
-				eztrc.Tracef(ctx, "%s send %d to %s (someone): ignoring", src, amount, dst)
+				switch {
+				case toValidator:
+					eztrc.Tracef(ctx, "%s send %d %s (%d) to %s (validator)", p.From, p.Amount, p.Denom, value, p.To)
+					payments = append(payments, Payment{From: p.From, To: p.To, Denom: p.Denom, Amount: p.Amount})
+					validatorPayment += value
+				case toMekatek:
+					eztrc.Tracef(ctx, "%s send %d %s (%d) to %s (mekatek)", p.From, p.Amount, p.Denom, value, p.To)
+					payments = append(payments, Payment{From: p.From, To: p.To, Denom: p.Denom, Amount: p.Amount})
+					mekatekPayment += value
+				default:
+					eztrc.Tracef(ctx, "%s send %d %s to %s (someone): ignoring", p.From, p.Amount, p.Denom, p.To)
+				}
 			}
 		}
 	}
@@ -882,7 +1650,7 @@ func evaluateBid(
 	eztrc.Tracef(ctx, "validator (%s) +%d %s", auction.ValidatorPaymentAddress, validatorPayment, auction.PaymentDenom)
 	eztrc.Tracef(ctx, "mekatek (%s) +%d %s", auction.MekatekPaymentAddress, mekatekPayment, auction.PaymentDenom)
 	for _, p := range payments {
-		eztrc.Tracef(ctx, "searcher (%s) -%d %s", p.From, p.Amount, auction.PaymentDenom)
+		eztrc.Tracef(ctx, "searcher (%s) -%d %s", p.From, p.Amount, p.Denom)
 	}
 
 	// Ensure the bid has the correct overall payment allocation(s).
@@ -897,10 +1665,10 @@ func evaluateBid(
 			wantMekatekAllocation   = 1 - auction.ValidatorAllocation
 			haveValidatorAllocation = float64(validatorPayment) / float64(totalPayment)
 			haveMekatekAllocation   = float64(mekatekPayment) / float64(totalPayment)
-			allocationTolerance     = 0.01 // TODO
+			tolerance               = auction.AllocationTolerance + 1e-9 // so that 0 allows rounding errors, but nothing else
 			validatorAllocationDiff = math.Abs(wantValidatorAllocation - haveValidatorAllocation)
 			mekatekAllocationDiff   = math.Abs(wantMekatekAllocation - haveMekatekAllocation)
-			isCorrectAllocation     = validatorAllocationDiff < allocationTolerance && mekatekAllocationDiff < allocationTolerance
+			isCorrectAllocation     = validatorAllocationDiff <= tolerance && mekatekAllocationDiff <= tolerance
 		)
 		if !isCorrectAllocation {
 			return fmt.Errorf("payment allocation %.3f/%.3f doesn't satisfy %.3f/%.3f", haveValidatorAllocation, haveMekatekAllocation, wantValidatorAllocation, wantMekatekAllocation)
@@ -926,6 +1694,7 @@ func evaluateBid(
 func verifyAuction(
 	ctx context.Context,
 	c chain.Chain,
+	prices PriceSource,
 	height int64,
 	maxHeightOffset int64,
 	tx store.Store,
@@ -1022,18 +1791,23 @@ func verifyAuction(
 				}
 			}
 
-			const allocation = FixedAllocation
+			var (
+				allocation = chainAllocation(ch, auctionProposer.Address, registeredPower, currentValidatorSet.TotalPower)
+				tolerance  = allocationTolerance(ch.AllocationTolerance)
+			)
 
-			eztrc.Tracef(ctx, "power: registered %d, total %d, allocation %.3f", registeredPower, currentValidatorSet.TotalPower, allocation)
+			eztrc.Tracef(ctx, "power: registered %d, total %d, allocation %.3f (%s), tolerance %.3f", registeredPower, currentValidatorSet.TotalPower, allocation, ch.AllocationPolicy, tolerance)
 
 			a = &store.Auction{
 				ChainID:                 chainID,
 				Height:                  height,
 				ValidatorAddress:        auctionProposer.Address,
 				ValidatorAllocation:     allocation,
+				AllocationTolerance:     tolerance,
 				ValidatorPaymentAddress: auctionProposer.PaymentAddress,
 				MekatekPaymentAddress:   ch.MekatekPaymentAddress,
 				PaymentDenom:            ch.PaymentDenom,
+				PaymentDenomRates:       paymentDenomRates(ctx, prices, ch),
 				RegisteredPower:         registeredPower,
 				TotalPower:              currentValidatorSet.TotalPower,
 			}
@@ -1063,15 +1837,191 @@ func verifyAuction(
 	return auction, auctionProposer, nil
 }
 
+const (
+	claimedBuildTimeout   = 30 * time.Second       // to finish a build once its auction is claimed
+	buildResultWait       = 10 * time.Second       // for the result of a build that's still running
+	buildResultPollPeriod = 100 * time.Millisecond // while waiting for it
+)
+
+// awaitBuildResult returns the stored result of a claimed auction, if it was
+// produced for exactly the same signed build request. If there's no result
+// yet, and the original request may still be building, it waits for a while
+// before giving up. Otherwise, it returns ErrAuctionFinished.
+func (s *CoreService) awaitBuildResult(
+	ctx context.Context,
+	auction *store.Auction,
+	validatorAddr string,
+	maxBytes, maxGas int64,
+	txsHash []byte,
+	signature []byte,
+) (*store.BuildResult, error) {
+	var (
+		chainID = auction.ChainID
+		height  = auction.Height
+	)
+
+	// The original build can't run for longer than claimedBuildTimeout, so
+	// there's no point in waiting beyond that.
+	deadline := time.Now().Add(buildResultWait)
+	if buildDeadline := auction.FinishedAt.Add(claimedBuildTimeout); buildDeadline.Before(deadline) {
+		deadline = buildDeadline
+	}
+
+	wait := time.NewTimer(time.Until(deadline))
+	defer wait.Stop()
+
+	ticker := time.NewTicker(buildResultPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		r, err := s.store.SelectBuildResult(ctx, chainID, height)
+		switch {
+		case err == nil:
+			if !matchBuildResult(ctx, r, validatorAddr, maxBytes, maxGas, txsHash, signature) {
+				return nil, ErrAuctionFinished
+			}
+			return r, nil
+
+		case errors.Is(err, store.ErrNotFound):
+			eztrc.Tracef(ctx, "no build result yet")
+
+		default:
+			return nil, fmt.Errorf("get build result: %w", err)
+		}
+
+		select {
+		case <-wait.C:
+			eztrc.Tracef(ctx, "gave up waiting for build result")
+			return nil, ErrAuctionFinished
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// matchBuildResult returns true if the result was produced for exactly the
+// same signed build request.
+func matchBuildResult(
+	ctx context.Context,
+	r *store.BuildResult,
+	validatorAddr string,
+	maxBytes, maxGas int64,
+	txsHash []byte,
+	signature []byte,
+) bool {
+	var (
+		sameValidator = r.ValidatorAddress == validatorAddr
+		sameLimits    = r.MaxBytes == maxBytes && r.MaxGas == maxGas
+		sameTxs       = bytes.Equal(r.TxsHash, txsHash)
+		sameSignature = bytes.Equal(r.Signature, signature)
+	)
+	if !sameValidator || !sameLimits || !sameTxs || !sameSignature {
+		eztrc.Tracef(ctx, "previous build result doesn't match: validator %v, limits %v, txs %v, signature %v", sameValidator, sameLimits, sameTxs, sameSignature)
+		return false
+	}
+
+	eztrc.Tracef(ctx, "previous build result matches request, created at %s", traceTime(r.CreatedAt))
+	return true
+}
+
 //
 //
 //
 
 type txBundle struct {
 	source           string
+	bidID            *uuid.UUID // nil for mempool txs
 	txs              [][]byte
-	validatorPayment int64
-	mekatekPayment   int64
+	validatorPayment int64 // converted to the auction's payment denom
+	mekatekPayment   int64 // converted to the auction's payment denom
+}
+
+// validatorCoins returns what the bids pay to the validator, in the denoms
+// they're actually paid in, formatted like sdk.Coins, e.g. "5uatom,100uosmo".
+// If nothing is paid, it's zero of the auction's payment denom.
+func validatorCoins(auction *Auction, bids []*Bid) string {
+	amounts := map[string]int64{}
+	for _, b := range bids {
+		for _, p := range b.Payments {
+			if p.Amount > 0 && sameAddr(p.To, auction.ValidatorPaymentAddress) {
+				amounts[p.Denom] += p.Amount
+			}
+		}
+	}
+
+	if len(amounts) == 0 {
+		return fmt.Sprintf("0%s", auction.PaymentDenom)
+	}
+
+	denoms := make([]string, 0, len(amounts))
+	for denom := range amounts {
+		denoms = append(denoms, denom)
+	}
+	sort.Strings(denoms)
+
+	coins := make([]string, len(denoms))
+	for i, denom := range denoms {
+		coins[i] = fmt.Sprintf("%d%s", amounts[denom], denom)
+	}
+	return strings.Join(coins, ",")
+}
+
+func newBuildTx(tx []byte, b *txBundle) store.BuildTx {
+	if b.bidID == nil {
+		return store.BuildTx{Hash: cryptoutil.HashTx(tx), Source: store.BuildTxSourceMempool}
+	}
+	return store.BuildTx{Hash: cryptoutil.HashTx(tx), Source: store.BuildTxSourceBid, BidID: b.bidID}
+}
+
+// checkInclusion compares a build with the txs of the block that was committed
+// at the same height. A bid counts as included only if all of its txs were
+// committed contiguously and in the order they were built.
+func checkInclusion(b *store.Build, committedTxs [][]byte) (store.BuildInclusionState, map[uuid.UUID]store.BidState) {
+	positions := make(map[string]int, len(committedTxs))
+	for i, tx := range committedTxs {
+		positions[cryptoutil.HashTx(tx)] = i
+	}
+
+	var (
+		exact     = len(b.Txs) == len(committedTxs)
+		complete  = true
+		bidTxPos  = map[uuid.UUID][]int{}
+		bidStates = map[uuid.UUID]store.BidState{}
+	)
+	for i, tx := range b.Txs {
+		pos, ok := positions[tx.Hash]
+		if !ok {
+			complete = false
+			pos = -1
+		}
+		if pos != i {
+			exact = false
+		}
+		if tx.BidID != nil {
+			bidTxPos[*tx.BidID] = append(bidTxPos[*tx.BidID], pos)
+		}
+	}
+
+	for id, ps := range bidTxPos {
+		state := store.BidStateIncluded
+		for i, p := range ps {
+			if p < 0 || (i > 0 && p != ps[i-1]+1) {
+				state = store.BidStateExcluded
+				break
+			}
+		}
+		bidStates[id] = state
+	}
+
+	switch {
+	case exact:
+		return store.BuildInclusionIncluded, bidStates
+	case complete:
+		return store.BuildInclusionReordered, bidStates
+	default:
+		return store.BuildInclusionDropped, bidStates
+	}
 }
 
 func selectTransactions(
@@ -1109,29 +2059,8 @@ func selectTransactions(
 	)
 
 	// Bids, or rather their txs, must be accepted or rejected atomically.
-	for i, eb := range winningBids {
-		var (
-			bidBytes int64
-			bidGas   int64
-		)
-		for j, txb := range eb.Txs {
-			ctx := trc.PrefixContextf(ctx, "bid %s (%d/%d) tx %d/%d", eb.ID, i+1, len(winningBids), j+1, len(eb.Txs))
-
-			txBytes, err := getTxBytes(ctx, c, txb)
-			if err != nil {
-				eztrc.Tracef(ctx, "get bytes: %v", err)
-				continue
-			}
-
-			txGas, err := getTxGas(ctx, c, txb)
-			if err != nil {
-				eztrc.Tracef(ctx, "get gas: %v", err)
-				continue
-			}
-
-			bidBytes += txBytes
-			bidGas += txGas
-		}
+	placeBid := func(ctx context.Context, eb *Bid) {
+		bidBytes, bidGas := getBundleSize(ctx, c, eb.Txs)
 
 		var (
 			tooManyBytes = totalBytes+bidBytes > maxBytes
@@ -1139,13 +2068,14 @@ func selectTransactions(
 			rejectBid    = tooManyBytes || tooMuchGas
 		)
 		if rejectBid {
-			eztrc.Tracef(ctx, "bid %s (%d/%d) rejected, too many bytes (%d) %v, too much gas (%d) %v", eb.ID, i+1, len(winningBids), bidBytes, tooManyBytes, bidGas, tooMuchGas)
+			eztrc.Tracef(ctx, "rejected, too many bytes (%d) %v, too much gas (%d) %v", bidBytes, tooManyBytes, bidGas, tooMuchGas)
 			eb.State = store.BidStateRejected
+			eb.RejectionReason = fmt.Sprintf("exceeds block capacity (%d bytes, %d gas)", bidBytes, bidGas)
 			rejectedBids = append(rejectedBids, eb)
-			continue
+			return
 		}
 
-		eztrc.Tracef(ctx, "bid %s (%d/%d) ACCEPTED, priority %d, bytes %d, gas %d, tx count %d, validator payment %d", eb.ID, i+1, len(winningBids), eb.Priority, bidBytes, bidGas, len(eb.Txs), eb.ValidatorPayment)
+		eztrc.Tracef(ctx, "ACCEPTED, priority %d, bytes %d, gas %d, tx count %d, validator payment %d", eb.Priority, bidBytes, bidGas, len(eb.Txs), eb.ValidatorPayment)
 		eb.State = store.BidStateAccepted
 		acceptedBids = append(acceptedBids, eb)
 		totalBytes += bidBytes
@@ -1153,15 +2083,32 @@ func selectTransactions(
 
 		bundles = append(bundles, &txBundle{
 			source:           fmt.Sprintf("bid %s", eb.ID),
+			bidID:            &eb.ID,
 			txs:              eb.Txs,
 			validatorPayment: eb.ValidatorPayment,
 			mekatekPayment:   eb.MekatekPayment,
 		})
 	}
 
+	// Backrun bids are placed right after their target tx, so they wait for
+	// the mempool txs.
+	backruns := map[string]*Bid{}
+	for i, eb := range winningBids {
+		ctx := trc.PrefixContextf(ctx, "bid %s (%d/%d)", eb.ID, i+1, len(winningBids))
+
+		if eb.Kind == store.BidKindBackrun {
+			eztrc.Tracef(ctx, "backrun of %s, deferred", eb.TargetTxHash)
+			backruns[eb.TargetTxHash] = eb
+			continue
+		}
+
+		placeBid(ctx, eb)
+	}
+
 	// Mempool txs come next.
 	for i, tx := range txs {
-		ctx := trc.PrefixContextf(ctx, "mempool tx %s (%d/%d)", cryptoutil.HashTx(tx), i+1, len(txs))
+		txHash := cryptoutil.HashTx(tx)
+		ctx := trc.PrefixContextf(ctx, "mempool tx %s (%d/%d)", txHash, i+1, len(txs))
 
 		txBytes, err := getTxBytes(ctx, c, tx)
 		if err != nil {
@@ -1196,6 +2143,21 @@ func selectTransactions(
 			source: "mempool",
 			txs:    [][]byte{tx},
 		})
+
+		if eb, ok := backruns[txHash]; ok {
+			delete(backruns, txHash)
+			placeBid(trc.PrefixContextf(ctx, "backrun bid %s", eb.ID), eb)
+		}
+	}
+
+	// Backrun bids whose target didn't make it into the block are rejected.
+	for _, eb := range winningBids {
+		if eb.Kind == store.BidKindBackrun && backruns[eb.TargetTxHash] == eb {
+			eztrc.Tracef(ctx, "backrun bid %s rejected, target tx %s not in block", eb.ID, eb.TargetTxHash)
+			eb.State = store.BidStateRejected
+			eb.RejectionReason = "target tx not included in block"
+			rejectedBids = append(rejectedBids, eb)
+		}
 	}
 
 	eztrc.Tracef(ctx, "bids: accepted %d, rejected %d", len(acceptedBids), len(rejectedBids))
@@ -1208,16 +2170,52 @@ func selectTransactions(
 //
 //
 
+// evaluateRangeBids evaluates the bids which were placed for an earlier
+// auction, and are still eligible, against this one, since it may e.g. pay a
+// different validator. Those that aren't valid for it sit it out, unchanged,
+// and remain eligible for later auctions.
+func evaluateRangeBids(ctx context.Context, c chain.Chain, auction *store.Auction, bids []*store.Bid) []*store.Bid {
+	eligible := make([]*store.Bid, 0, len(bids))
+	for _, bid := range bids {
+		if bid.Height != auction.Height {
+			b := copyBid(bid)
+			b.State, b.RejectionReason = store.BidStatePending, ""
+			if err := evaluateBid(ctx, c, auction, b); err != nil {
+				eztrc.Tracef(ctx, "bid %s from %d not eligible: %v", bid.ID, bid.Height, err)
+				continue
+			}
+			bid = b
+		}
+		eligible = append(eligible, bid)
+	}
+	return eligible
+}
+
+// checkBid asks the chain to pre-validate every tx in the bid, returning an
+// error wrapping chain.ErrInvalidTx for the first tx the chain would reject.
+// The check has no side effects, so bids are checked when they're placed, and
+// again when they're built.
+func checkBid(ctx context.Context, c chain.Chain, bid *store.Bid) error {
+	for i, txb := range bid.Txs {
+		if err := c.CheckTransaction(ctx, txb); err != nil {
+			return fmt.Errorf("bid tx %d/%d (%s): %w", i+1, len(bid.Txs), cryptoutil.HashTx(txb), err)
+		}
+	}
+	return nil
+}
+
 // computeOrder selects winning and losing bids, and appends remaining mempool
-// txs, for a block. This process is constrained by the bytes, gas, etc. limits
-// for the block as specified in the auction. It mutates the state field of the
-// provided bids.
+// txs, for a block. The winners are picked by the selector, which may take the
+// bytes and gas limits of the block into account. It mutates the state field
+// of the provided bids.
 func computeOrder(
 	ctx context.Context,
 	c chain.Chain,
 	auction *store.Auction,
 	bids []*store.Bid,
 	mempoolTxs [][]byte,
+	selector bidSelector,
+	maxBytes, maxGas int64,
 ) ([]*Bid, []*Bid, [][]byte, error) {
 	ctx = trc.PrefixContextf(ctx, "[compute order]")
 
@@ -1231,29 +2229,32 @@ func computeOrder(
 				return nil, nil, nil, fmt.Errorf("evaluate un-evaluated bid: %w", err)
 			}
 		}
+		for i := range bid.Payments { // from before payments had a denom
+			if bid.Payments[i].Denom == "" {
+				bid.Payments[i].Denom = auction.PaymentDenom
+			}
+		}
 		evaluatedBids = append(evaluatedBids, bid)
 	}
 
+	// Find the bids which the chain would no longer accept, e.g. because one
+	// of their txs' sequences has been used since they were placed.
+	invalidBids := checkBids(ctx, c, evaluatedBids)
+
 	// Capture the current balances of all relevant payment addresses.
-	senderBalances := map[string]int64{}
+	var senderBalances map[balanceKey]int64
 	{
-		// Get all the addrs we care about.
-		queryBalances := map[string]struct{}{}
+		// Get all the addrs and denoms we care about.
+		queryBalances := map[balanceKey]struct{}{}
 		for _, eb := range evaluatedBids {
 			for _, p := range eb.Payments {
-				queryBalances[p.From] = struct{}{}
+				queryBalances[balanceKey{p.From, p.Denom}] = struct{}{}
 			}
 		}
 
-		// Get the balance for each of those addrs.
-		for addr := range queryBalances {
-			balance, err := c.AccountBalance(ctx, auction.Height-1, addr, auction.PaymentDenom)
-			if err != nil {
-				eztrc.Errorf(ctx, "get account balance for sender %s: %v", addr, err)
-				continue
-			}
-			senderBalances[addr] = balance
-		}
+		// Get the balance for each of those. Most should be cached already, from
+		// when the bids were placed.
+		senderBalances = fetchBalances(ctx, c, auction.Height-1, queryBalances)
 	}
 
 	// Sort the slice so the highest-payment bids are at the top.
@@ -1270,76 +2271,63 @@ func computeOrder(
 		return bytes.Compare(b1.ID.Bytes(), b2.ID.Bytes()) < 0
 	})
 
-	// Walk the now-sorted bids, and select the winners.
+	// Walk the now-sorted bids, and reject those which can't win on their own.
 	var (
-		winningBids  []*Bid
-		rejectedBids []*Bid
-		claimedTxs   = map[string]bool{}
+		candidateBids []*Bid
+		rejectedBids  []*Bid
+		mempoolHashes = map[string]bool{}
 	)
+	for _, tx := range mempoolTxs {
+		mempoolHashes[cryptoutil.HashTx(tx)] = true
+	}
 	for i, eb := range evaluatedBids {
 		ctx := trc.PrefixContextf(ctx, "bid %s (%d/%d) [%d]", eb.ID, i+1, len(evaluatedBids), eb.Priority)
 
-		// Bids that need to be top-of-block are rejected if there is already a top-of-block bid.
-		if eb.Kind == store.BidKindTop && len(winningBids) > 0 {
-			eb.State = store.BidStateRejected
+		if err, ok := invalidBids[eb.ID]; ok {
+			rejectBid(ctx, auction.ChainID, eb, "check tx failed", err.Error())
 			rejectedBids = append(rejectedBids, eb)
-			eztrc.Tracef(ctx, "rejected, block already has a top-of-block")
-			metrics.BidsEvaluatedTotal.WithLabelValues(auction.ChainID, "top of block").Inc()
 			continue
 		}
 
-		// Bids with transactions that have already been "claimed" by other bids are rejected.
-		var hasClaimedTransactions bool
-		for _, tx := range eb.Txs {
-			if _, ok := claimedTxs[cryptoutil.HashTx(tx)]; ok {
-				hasClaimedTransactions = true
-				break
-			}
-		}
-		if hasClaimedTransactions {
-			eb.State = store.BidStateRejected
+		// Backrun bids are rejected if their target tx isn't in the mempool.
+		if eb.Kind == store.BidKindBackrun && !mempoolHashes[eb.TargetTxHash] {
+			rejectBid(ctx, auction.ChainID, eb, "backrun target", "target tx not in mempool")
 			rejectedBids = append(rejectedBids, eb)
-			eztrc.Tracef(ctx, "rejected, has claimed txs")
-			metrics.BidsEvaluatedTotal.WithLabelValues(auction.ChainID, "claimed txs").Inc()
 			continue
 		}
 
-		// Make a copy of the balances state.
-		senderBalancesCopy := make(map[string]int64, len(senderBalances))
-		for k, v := range senderBalances {
-			senderBalancesCopy[k] = v
-		}
+		candidateBids = append(candidateBids, eb)
+	}
 
-		// Bids with unsatisfiable payments are rejected.
-		var insufficientFunds bool
-		for _, p := range eb.Payments {
-			balance := senderBalancesCopy[p.From]
-			result := balance - p.Amount
-			if result < 0 {
-				eztrc.Tracef(ctx, "payment addr %s: %v - %v = %v -- insufficient funds", p.From, balance, p.Amount, result)
-				insufficientFunds = true
-				break
+	// Resolve the conflicts between the remaining bids to select the winners.
+	var winningBids []*Bid
+	{
+		in := &selectionInput{
+			chainID:  auction.ChainID,
+			bids:     candidateBids,
+			sizes:    make([]bundleSize, len(candidateBids)),
+			txHashes: make([][]string, len(candidateBids)),
+			balances: senderBalances,
+			maxBytes: maxBytes,
+			maxGas:   maxGas,
+		}
+		for i, eb := range candidateBids {
+			in.sizes[i].bytes, in.sizes[i].gas = getBundleSize(ctx, c, eb.Txs)
+			for _, tx := range eb.Txs {
+				in.txHashes[i] = append(in.txHashes[i], cryptoutil.HashTx(tx))
 			}
-			senderBalancesCopy[p.From] = result
 		}
 
-		if insufficientFunds {
-			eb.State = store.BidStateRejected
-			rejectedBids = append(rejectedBids, eb)
-			eztrc.Tracef(ctx, "rejected, insufficient funds")
-			metrics.BidsEvaluatedTotal.WithLabelValues(auction.ChainID, "insufficient funds").Inc()
-			continue
-		}
+		winners, losers := selector.selectBids(ctx, in)
+		winningBids = winners
+		rejectedBids = append(rejectedBids, losers...)
+	}
 
-		// Otherwise, the bid is a winning bid.
-		winningBids = append(winningBids, eb)
-		senderBalances = senderBalancesCopy // update payment addr balances
-		for _, tx := range eb.Txs {         // claim txs in bid
+	claimedTxs := map[string]bool{}
+	for _, eb := range winningBids {
+		for _, tx := range eb.Txs {
 			claimedTxs[cryptoutil.HashTx(tx)] = true
 		}
-
-		eztrc.Tracef(ctx, "accepted")
-		metrics.BidsEvaluatedTotal.WithLabelValues(auction.ChainID, "won").Inc()
 	}
 
 	// Append any remaining mempool transactions which aren't already in the block.
@@ -1,16 +1,23 @@
 package block_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"zenith/block"
 	"zenith/chain"
+	"zenith/cryptoutil"
 	"zenith/store"
 	"zenith/store/memstore"
 	"zenith/store/pgstore"
@@ -190,6 +197,70 @@ func TestServiceAuction(t *testing.T) {
 			t.Fatalf("want %v, have %v", want, have)
 		}
 	})
+
+	t.Run("allocation policy", func(t *testing.T) {
+		registeredPower := foo.VotingPower + bar.VotingPower
+
+		for _, testcase := range []struct {
+			name           string
+			policy         store.AllocationPolicy
+			allocation     *float64
+			tolerance      *float64
+			overrides      map[string]float64
+			wantAllocation float64
+			wantTolerance  float64
+		}{
+			{name: "fixed default", policy: store.AllocationPolicyFixed, wantAllocation: block.FixedAllocation, wantTolerance: block.DefaultAllocationTolerance},
+			{name: "fixed", policy: store.AllocationPolicyFixed, allocation: store.Float64(0.8), tolerance: store.Float64(0.05), wantAllocation: 0.8, wantTolerance: 0.05},
+			{name: "fixed zero", policy: store.AllocationPolicyFixed, allocation: store.Float64(0), tolerance: store.Float64(0), wantAllocation: 0, wantTolerance: 0},
+			{name: "power linear", policy: store.AllocationPolicyPowerLinear, tolerance: store.Float64(0.05), wantAllocation: block.Allocation(registeredPower, valset.TotalPower), wantTolerance: 0.05},
+			{name: "per validator", policy: store.AllocationPolicyPerValidator, allocation: store.Float64(0.8), overrides: map[string]float64{bar.Address: 0.6}, wantAllocation: 0.6, wantTolerance: block.DefaultAllocationTolerance},
+			{name: "per validator zero", policy: store.AllocationPolicyPerValidator, allocation: store.Float64(0.8), overrides: map[string]float64{bar.Address: 0}, wantAllocation: 0, wantTolerance: block.DefaultAllocationTolerance},
+			{name: "per validator fallback", policy: store.AllocationPolicyPerValidator, allocation: store.Float64(0.8), overrides: map[string]float64{foo.Address: 0.6}, wantAllocation: 0.8, wantTolerance: block.DefaultAllocationTolerance},
+		} {
+			t.Run(testcase.name, func(t *testing.T) {
+				var (
+					testStore  = newStore(t, ctx)
+					storeChain = storetest.NewChain(t, testStore)
+					mockChain  = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+					service    = block.NewCoreService(mockChain, testStore)
+				)
+
+				storeChain.AllocationPolicy = testcase.policy
+				storeChain.Allocation = testcase.allocation
+				storeChain.ValidatorAllocations = testcase.overrides
+				storeChain.AllocationTolerance = testcase.tolerance
+				if err := testStore.UpsertChain(ctx, storeChain); err != nil {
+					t.Fatalf("upsert chain: %v", err)
+				}
+
+				for _, v := range []*chain.Validator{foo.Validator, bar.Validator} {
+					if err := testStore.UpsertValidator(ctx, &block.Validator{
+						ChainID:        storeChain.ID,
+						Address:        v.Address,
+						PubKeyBytes:    v.PubKeyBytes,
+						PubKeyType:     v.PubKeyType,
+						PaymentAddress: v.Address,
+					}); err != nil {
+						t.Fatalf("register val: %v", err)
+					}
+				}
+
+				auction, err := service.Auction(ctx, height+1)
+				if err != nil {
+					t.Fatalf("auction: %v", err)
+				}
+
+				if want, have := testcase.wantAllocation, auction.ValidatorAllocation; math.Abs(want-have) > 1e-9 {
+					t.Errorf("allocation: want %.3f, have %.3f", want, have)
+				}
+
+				if want, have := testcase.wantTolerance, auction.AllocationTolerance; want != have {
+					t.Errorf("tolerance: want %.3f, have %.3f", want, have)
+				}
+			})
+		}
+	})
 }
 
 func TestServiceBid(t *testing.T) {
@@ -220,7 +291,7 @@ func TestServiceBid(t *testing.T) {
 			service    = block.NewCoreService(mockChain, testStore)
 		)
 
-		_, err := service.Bid(ctx, height-3, string(store.BidKindTop), [][]byte{{0, 1, 2}})
+		_, err := service.Bid(ctx, height-3, 0, string(store.BidKindTop), "", [][]byte{{0, 1, 2}}, "", nil)
 		if want, have := block.ErrAuctionTooOld, err; !errors.Is(have, want) {
 			t.Fatalf("want %v, have %v", want, have)
 		}
@@ -234,7 +305,7 @@ func TestServiceBid(t *testing.T) {
 			service    = block.NewCoreService(mockChain, testStore)
 		)
 
-		_, err := service.Bid(ctx, height+25, string(store.BidKindTop), [][]byte{{0, 1, 2}})
+		_, err := service.Bid(ctx, height+25, 0, string(store.BidKindTop), "", [][]byte{{0, 1, 2}}, "", nil)
 		if want, have := block.ErrAuctionTooNew, err; !errors.Is(have, want) {
 			t.Fatalf("want %v, have %v", want, have)
 		}
@@ -248,7 +319,7 @@ func TestServiceBid(t *testing.T) {
 			service    = block.NewCoreService(mockChain, testStore)
 		)
 
-		_, err := service.Bid(ctx, height+1, string(store.BidKindTop), [][]byte{{0, 1, 2}})
+		_, err := service.Bid(ctx, height+1, 0, string(store.BidKindTop), "", [][]byte{{0, 1, 2}}, "", nil)
 		if want, have := block.ErrAuctionUnavailable, err; !errors.Is(have, want) {
 			t.Fatalf("want %v, have %v", want, have)
 		}
@@ -285,15 +356,1732 @@ func TestServiceBid(t *testing.T) {
 			t.Fatalf("finish auction: %v", err)
 		}
 
-		_, err = service.Bid(ctx, height+1, string(store.BidKindTop), [][]byte{{0, 1, 2}})
+		_, err = service.Bid(ctx, height+1, 0, string(store.BidKindTop), "", [][]byte{{0, 1, 2}}, "", nil)
 		if want, have := block.ErrAuctionFinished, err; !errors.Is(have, want) {
 			t.Fatalf("want %v, have %v", want, have)
 		}
 	})
 }
 
-func TestServiceBuild(t *testing.T) {
-	t.Skip("TODO")
+func TestServiceBidPaymentDenoms(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		searcher = storetest.GenBech32Addr(t, storetest.Network)
+	)
+
+	priceFile := filepath.Join(t.TempDir(), "prices.json")
+	if err := os.WriteFile(priceFile, []byte(fmt.Sprintf(`{%q: {%q: 20}}`, storetest.Denom, storetest.OtherDenom)), 0o600); err != nil {
+		t.Fatalf("write price file: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		name         string
+		options      []block.CoreServiceOption
+		rate         float64
+		wantPriority int64
+	}{
+		{
+			name:         "static rate",
+			rate:         10,
+			wantPriority: 323 + 10,
+		},
+		{
+			name:         "price source",
+			options:      []block.CoreServiceOption{block.WithPriceSource(block.NewFilePriceSource(priceFile))},
+			rate:         20,
+			wantPriority: 646 + 20,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			var (
+				testStore  = newStore(t, ctx)
+				storeChain = storetest.NewChain(t, testStore)
+				mockChain  = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+				service    = block.NewCoreService(mockChain, testStore, testcase.options...)
+			)
+
+			for _, v := range mockChain.Validators.Set {
+				if err := testStore.UpsertValidator(ctx, &block.Validator{
+					ChainID:        storeChain.ID,
+					Address:        v.Address,
+					PubKeyBytes:    v.PubKeyBytes,
+					PubKeyType:     v.PubKeyType,
+					PaymentAddress: v.Address,
+				}); err != nil {
+					t.Fatalf("register val: %v", err)
+				}
+			}
+
+			auction, err := service.Auction(ctx, height+1)
+			if err != nil {
+				t.Fatalf("auction: %v", err)
+			}
+
+			if want, have := testcase.rate, auction.PaymentDenomRates[storetest.OtherDenom]; want != have {
+				t.Fatalf("rate: want %v, have %v", want, have)
+			}
+
+			// The validator is paid in the base denom, and the 3% for Mekatek
+			// in the other denom, which only works out at the right rate.
+			validatorAmount := testcase.wantPriority - int64(testcase.rate)
+			mockChain.Payments = map[string][]chain.Payment{
+				"pay": {
+					{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: validatorAmount},
+					{From: searcher, To: auction.MekatekPaymentAddress, Denom: storetest.OtherDenom, Amount: 1},
+					{From: searcher, To: auction.MekatekPaymentAddress, Denom: "unaccepted", Amount: 1000},
+				},
+			}
+
+			bid, err := service.Bid(ctx, height+1, 0, string(store.BidKindBlock), "", [][]byte{[]byte("pay")}, "", nil)
+			if err != nil {
+				t.Fatalf("bid: %v", err)
+			}
+
+			if want, have := testcase.wantPriority, bid.Priority; want != have {
+				t.Errorf("priority: want %d, have %d", want, have)
+			}
+
+			wantPayments := fmt.Sprint([]block.Payment{
+				{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: validatorAmount},
+				{From: searcher, To: auction.MekatekPaymentAddress, Denom: storetest.OtherDenom, Amount: 1},
+			})
+			if want, have := wantPayments, fmt.Sprint(bid.Payments); want != have {
+				t.Errorf("payments: want %s, have %s", want, have)
+			}
+		})
+	}
+}
+
+func TestServiceBuildV1PaymentDenoms(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service     = block.NewCoreService(mockChain, testStore)
+		buildHeight = height + 1
+		searcher    = storetest.GenBech32Addr(t, storetest.Network)
+	)
+
+	for _, v := range mockChain.Validators.Set {
+		if err := testStore.UpsertValidator(ctx, &block.Validator{
+			ChainID:        storeChain.ID,
+			Address:        v.Address,
+			PubKeyBytes:    v.PubKeyBytes,
+			PubKeyType:     v.PubKeyType,
+			PaymentAddress: v.Address,
+		}); err != nil {
+			t.Fatalf("register val: %v", err)
+		}
+	}
+
+	auction, err := service.Auction(ctx, buildHeight)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	// The validator is paid in both denoms, worth 50 + 4*10 of the base denom.
+	mockChain.Payments = map[string][]chain.Payment{
+		"pay": {
+			{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 50},
+			{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.OtherDenom, Amount: 4},
+			{From: searcher, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+		},
+	}
+
+	if _, err := service.Bid(ctx, buildHeight, 0, string(store.BidKindBlock), "", [][]byte{[]byte("pay")}, "", nil); err != nil {
+		t.Fatalf("bid: %v", err)
+	}
+
+	_, payment, err := service.BuildV1(ctx, buildHeight, bar.Address, -1, -1, nil, []byte("signature"))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	if want, have := fmt.Sprintf("4%s,50%s", storetest.OtherDenom, storetest.Denom), payment; want != have {
+		t.Errorf("payment: want %s, have %s", want, have)
+	}
+
+	result, err := testStore.SelectBuildResult(ctx, storeChain.ID, buildHeight)
+	if err != nil {
+		t.Fatalf("select build result: %v", err)
+	}
+
+	if want, have := payment, result.ValidatorPayment; want != have {
+		t.Errorf("stored payment: want %s, have %s", want, have)
+	}
+}
+
+func TestServiceBidRange(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		searcher   = storetest.GenBech32Addr(t, storetest.Network)
+		testStore  = newStore(t, ctx)
+		storeChain = storetest.NewChain(t, testStore)
+		mockChain  = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service    = block.NewCoreService(mockChain, testStore)
+		bidTx      = []byte("pay")
+	)
+
+	// BuildV1 registers the proposer with its payment address from the chain,
+	// which needs to match for the bid to remain valid.
+	mockChain.PredictedProposer.PaymentAddress = bar.Address
+
+	for _, v := range mockChain.Validators.Set {
+		if err := testStore.UpsertValidator(ctx, &block.Validator{
+			ChainID:        storeChain.ID,
+			Address:        v.Address,
+			PubKeyBytes:    v.PubKeyBytes,
+			PubKeyType:     v.PubKeyType,
+			PaymentAddress: v.Address,
+		}); err != nil {
+			t.Fatalf("register val: %v", err)
+		}
+	}
+
+	auction, err := service.Auction(ctx, height+1)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	mockChain.Payments = map[string][]chain.Payment{
+		string(bidTx): {
+			{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+			{From: searcher, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+		},
+	}
+
+	t.Run("invalid range", func(t *testing.T) {
+		for _, maxHeight := range []int64{height, height + 2 + block.MaxBidHeightRange} {
+			_, err := service.Bid(ctx, height+1, maxHeight, string(store.BidKindBlock), "", [][]byte{bidTx}, "", nil)
+			if want, have := block.ErrInvalidRequest, err; !errors.Is(have, want) {
+				t.Errorf("max height %d: want %v, have %v", maxHeight, want, have)
+			}
+		}
+	})
+
+	t.Run("invalid tx", func(t *testing.T) {
+		var (
+			checking = &sequenceChain{TestChain: mockChain, sequences: map[string]uint64{searcher: 2}}
+			service  = block.NewCoreService(checking, testStore)
+			staleTx  = []byte(searcher + "/1/stale")
+		)
+
+		mockChain.Payments[string(staleTx)] = mockChain.Payments[string(bidTx)]
+
+		// The txs are the same for every auction, so they're checked once.
+		_, err := service.Bid(ctx, height+1, height+3, string(store.BidKindBlock), "", [][]byte{staleTx}, "", nil)
+		if want, have := block.ErrInvalidRequest, err; !errors.Is(have, want) {
+			t.Errorf("want %v, have %v", want, have)
+		}
+		if want, have := 1, checking.checks; want != have {
+			t.Errorf("checks: want %d, have %d", want, have)
+		}
+	})
+
+	bid, err := service.Bid(ctx, height+1, height+2, string(store.BidKindBlock), "", [][]byte{bidTx}, "", nil)
+	if err != nil {
+		t.Fatalf("bid: %v", err)
+	}
+
+	if want, have := height+1, bid.Height; want != have {
+		t.Fatalf("bid height: want %d, have %d", want, have)
+	}
+
+	// The block at height+1 has no room for the bid, so it isn't accepted.
+	if _, _, err := service.BuildV1(ctx, height+1, bar.Address, 1, -1, nil, []byte("signature")); err != nil {
+		t.Fatalf("build %d: %v", height+1, err)
+	}
+
+	mockChain.Height, mockChain.Validators.Height = height+1, height+1
+
+	blockTxs, _, err := service.BuildV1(ctx, height+2, bar.Address, -1, -1, nil, []byte("signature"))
+	if err != nil {
+		t.Fatalf("build %d: %v", height+2, err)
+	}
+
+	if want, have := fmt.Sprint([][]byte{bidTx}), fmt.Sprint(blockTxs); want != have {
+		t.Fatalf("block txs: want %s, have %s", want, have)
+	}
+
+	have, err := testStore.SelectBid(ctx, bid.ID.String())
+	if err != nil {
+		t.Fatalf("select bid: %v", err)
+	}
+
+	if want, have := store.BidStateAccepted, have.State; want != have {
+		t.Errorf("bid state: want %s, have %s", want, have)
+	}
+
+	if want, have := height+2, have.Height; want != have {
+		t.Errorf("bid height: want %d, have %d", want, have)
+	}
+
+	for _, h := range []int64{height + 1, height + 3} {
+		bids, err := testStore.ListBids(ctx, storeChain.ID, h)
+		if err != nil {
+			t.Fatalf("list bids at %d: %v", h, err)
+		}
+		if len(bids) != 0 {
+			t.Errorf("bids at %d: want none, have %d", h, len(bids))
+		}
+	}
+}
+
+func TestServiceBidPrefetchBalances(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height:     height,
+			Set:        map[string]*chain.Validator{foo.Address: foo.Validator},
+			TotalPower: foo.VotingPower,
+		}
+		searcher   = storetest.GenBech32Addr(t, storetest.Network)
+		testStore  = newStore(t, ctx)
+		storeChain = storetest.NewChain(t, testStore)
+		mockChain  = &slowBalanceChain{
+			TestChain: &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *foo.Validator},
+			release:   make(chan struct{}),
+		}
+		service = block.NewCoreService(mockChain, testStore)
+	)
+
+	if err := testStore.UpsertValidator(ctx, &block.Validator{
+		ChainID:        storeChain.ID,
+		Address:        foo.Address,
+		PubKeyBytes:    foo.PubKeyBytes,
+		PubKeyType:     foo.PubKeyType,
+		PaymentAddress: foo.Address,
+	}); err != nil {
+		t.Fatalf("register val: %v", err)
+	}
+
+	auction, err := service.Auction(ctx, height+1)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	mockChain.Payments = map[string][]chain.Payment{}
+	bid := func(i int) {
+		t.Helper()
+		tx := fmt.Sprintf("pay %d", i)
+		mockChain.Payments[tx] = []chain.Payment{
+			{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+			{From: searcher, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+		}
+		if _, err := service.Bid(ctx, height+1, 0, string(store.BidKindTop), "", [][]byte{[]byte(tx)}, "", nil); err != nil {
+			t.Fatalf("bid %d: %v", i, err)
+		}
+	}
+
+	awaitQueries := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for mockChain.queryCount() < want && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond) // for any extra queries
+		if have := mockChain.queryCount(); want != have {
+			t.Fatalf("balance queries: want %d, have %d", want, have)
+		}
+	}
+
+	// While the chain is slow, prefetches beyond the limit are dropped.
+	for i := 0; i < block.MaxBalancePrefetches+4; i++ {
+		bid(i)
+	}
+	awaitQueries(block.MaxBalancePrefetches)
+
+	// Once they finish, bids are prefetched again.
+	close(mockChain.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for i := block.MaxBalancePrefetches + 4; mockChain.queryCount() == block.MaxBalancePrefetches; i++ {
+		if time.Now().After(deadline) {
+			t.Fatalf("no balance queries after the chain was released")
+		}
+		bid(i)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// slowBalanceChain answers balance queries once it's released.
+type slowBalanceChain struct {
+	*chain.TestChain
+	release chan struct{}
+
+	mtx     sync.Mutex
+	queries int
+}
+
+func (c *slowBalanceChain) AccountBalance(ctx context.Context, height int64, addr, denom string) (int64, error) {
+	c.mtx.Lock()
+	c.queries++
+	c.mtx.Unlock()
+
+	select {
+	case <-c.release:
+		return c.TestChain.AccountBalance(ctx, height, addr, denom)
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (c *slowBalanceChain) queryCount() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.queries
+}
+
+func TestServiceCancelBid(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx         = context.Background()
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		validator   = storetest.NewValidator(t, testStore, storeChain)
+		auction     = storetest.NewAuction(t, testStore, storeChain, 1, validator)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: 1}
+		service     = block.NewCoreService(mockChain, testStore)
+		payerKey    = []byte("payer")
+		strangerKey = []byte("stranger")
+	)
+
+	newBid := func() *block.Bid {
+		t.Helper()
+		bid := &block.Bid{
+			ChainID:  storeChain.ID,
+			Height:   auction.Height,
+			Kind:     store.BidKindTop,
+			Txs:      [][]byte{[]byte("tx")},
+			Priority: 100,
+			State:    store.BidStatePending,
+			Payments: []block.Payment{{From: hex.EncodeToString(payerKey), To: auction.ValidatorPaymentAddress, Amount: 100}},
+		}
+		if err := testStore.InsertBid(ctx, bid); err != nil {
+			t.Fatalf("insert bid: %v", err)
+		}
+		return bid
+	}
+
+	t.Run("not a payer", func(t *testing.T) {
+		bid := newBid()
+		err := service.CancelBid(ctx, bid.ID.String(), "test", strangerKey, []byte("signature"))
+		if want, have := chain.ErrBadSignature, err; !errors.Is(have, want) {
+			t.Fatalf("want %v, have %v", want, have)
+		}
+	})
+
+	t.Run("payer", func(t *testing.T) {
+		bid := newBid()
+		if err := service.CancelBid(ctx, bid.ID.String(), "test", payerKey, []byte("signature")); err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+		if _, err := testStore.SelectBid(ctx, bid.ID.String()); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("select canceled bid: want %v, have %v", store.ErrNotFound, err)
+		}
+	})
+
+	t.Run("auction finished", func(t *testing.T) {
+		bid := newBid()
+
+		finished := *auction
+		finished.FinishedAt = time.Now().UTC()
+		if err := testStore.UpsertAuction(ctx, &finished); err != nil {
+			t.Fatalf("finish auction: %v", err)
+		}
+
+		err := service.CancelBid(ctx, bid.ID.String(), "test", payerKey, []byte("signature"))
+		if want, have := block.ErrAuctionFinished, err; !errors.Is(have, want) {
+			t.Fatalf("want %v, have %v", want, have)
+		}
+	})
+}
+
+func TestServiceReplaceBidSequence(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height:     height,
+			Set:        map[string]*chain.Validator{foo.Address: foo.Validator},
+			TotalPower: foo.VotingPower,
+		}
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		payerKey    = []byte("payer")
+		strangerKey = []byte("stranger")
+		payer       = hex.EncodeToString(payerKey)
+		mockChain   = &sequenceChain{
+			TestChain: &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *foo.Validator},
+			sequences: map[string]uint64{payer: 5},
+		}
+		service  = block.NewCoreService(mockChain, testStore)
+		bidTx    = []byte(payer + "/5/original")
+		sameSeq  = []byte(payer + "/5/replacement")
+		usedSeq  = []byte(payer + "/4/replacement")
+		bidTxs   = [][]byte{bidTx}
+		newTxs   = [][]byte{sameSeq}
+		usedTxs  = [][]byte{usedSeq}
+		stranger = [][]byte{[]byte(payer + "/5/stranger")}
+	)
+
+	if err := testStore.UpsertValidator(ctx, &block.Validator{
+		ChainID:        storeChain.ID,
+		Address:        foo.Address,
+		PubKeyBytes:    foo.PubKeyBytes,
+		PubKeyType:     foo.PubKeyType,
+		PaymentAddress: foo.Address,
+	}); err != nil {
+		t.Fatalf("register val: %v", err)
+	}
+
+	auction, err := service.Auction(ctx, height+1)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	payments := []chain.Payment{
+		{From: payer, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+		{From: payer, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+	}
+	mockChain.Payments = map[string][]chain.Payment{}
+	for _, txs := range [][][]byte{bidTxs, newTxs, usedTxs, stranger} {
+		mockChain.Payments[string(txs[0])] = payments
+	}
+
+	bid, err := service.Bid(ctx, height+1, 0, string(store.BidKindTop), "", bidTxs, "", nil)
+	if err != nil {
+		t.Fatalf("bid: %v", err)
+	}
+
+	// Unauthorized replacements are refused before their txs are checked.
+	checks := mockChain.checks
+	if _, err := service.ReplaceBid(ctx, bid.ID.String(), stranger, "test", strangerKey, []byte("signature")); !errors.Is(err, chain.ErrBadSignature) {
+		t.Fatalf("replace by stranger: want %v, have %v", chain.ErrBadSignature, err)
+	}
+	if want, have := checks, mockChain.checks; want != have {
+		t.Errorf("checks after unauthorized replace: want %d, have %d", want, have)
+	}
+
+	if _, err := service.ReplaceBid(ctx, bid.ID.String(), usedTxs, "test", payerKey, []byte("signature")); !errors.Is(err, block.ErrInvalidRequest) {
+		t.Fatalf("replace with used sequence: want %v, have %v", block.ErrInvalidRequest, err)
+	}
+
+	// The original tx was never committed, so its replacement has the same
+	// sequence.
+	replaced, err := service.ReplaceBid(ctx, bid.ID.String(), newTxs, "test", payerKey, []byte("signature"))
+	if err != nil {
+		t.Fatalf("replace with same sequence: %v", err)
+	}
+	if want, have := fmt.Sprint(newTxs), fmt.Sprint(replaced.Txs); want != have {
+		t.Errorf("replaced txs: want %s, have %s", want, have)
+	}
+}
+
+// sequenceChain models account sequences. A tx, which is "account/sequence/
+// label", is valid if it has the next sequence of its account, and checking it
+// doesn't change that, like a simulation.
+type sequenceChain struct {
+	*chain.TestChain
+	sequences map[string]uint64 // next sequence by account
+
+	mtx    sync.Mutex
+	checks int
+}
+
+func (c *sequenceChain) CheckTransaction(ctx context.Context, txb []byte) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.checks++
+
+	fields := strings.SplitN(string(txb), "/", 3)
+	if len(fields) != 3 {
+		return fmt.Errorf("%w: malformed tx %q", chain.ErrInvalidTx, txb)
+	}
+	if want, have := strconv.FormatUint(c.sequences[fields[0]], 10), fields[1]; want != have {
+		return fmt.Errorf("%w: account sequence mismatch, expected %s, got %s", chain.ErrInvalidTx, want, have)
+	}
+	return nil
+}
+
+func TestServiceSearcher(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		payer       = storetest.GenBech32Addr(t, storetest.Network)
+		searcherKey = []byte("searcher")
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service     = block.NewCoreService(mockChain, testStore)
+		txs         = [][]byte{[]byte("pay")}
+	)
+
+	for _, v := range mockChain.Validators.Set {
+		if err := testStore.UpsertValidator(ctx, &block.Validator{
+			ChainID:        storeChain.ID,
+			Address:        v.Address,
+			PubKeyBytes:    v.PubKeyBytes,
+			PubKeyType:     v.PubKeyType,
+			PaymentAddress: v.Address,
+		}); err != nil {
+			t.Fatalf("register val: %v", err)
+		}
+	}
+
+	auction, err := service.Auction(ctx, height+1)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	mockChain.Payments = map[string][]chain.Payment{
+		"pay": {
+			{From: payer, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+			{From: payer, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+		},
+	}
+
+	challenge, err := service.ApplySearcher(ctx, "test", searcherKey)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	searcher, err := service.RegisterSearcher(ctx, challenge.ID.String(), []byte("signature"))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if want, have := hex.EncodeToString(searcherKey), searcher.Address; want != have {
+		t.Errorf("searcher address: want %s, have %s", want, have)
+	}
+
+	if _, err := service.RegisterSearcher(ctx, challenge.ID.String(), []byte("signature")); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("register with used challenge: want %v, have %v", store.ErrNotFound, err)
+	}
+
+	t.Run("re-register", func(t *testing.T) {
+		challenge, err := service.ApplySearcher(ctx, "test", searcherKey)
+		if err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+		again, err := service.RegisterSearcher(ctx, challenge.ID.String(), []byte("signature"))
+		if err != nil {
+			t.Fatalf("register: %v", err)
+		}
+		if want, have := searcher.ID, again.ID; want != have {
+			t.Errorf("searcher ID: want %s, have %s", want, have)
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		bid, err := service.Bid(ctx, height+1, 0, string(store.BidKindTop), "", txs, "", nil)
+		if err != nil {
+			t.Fatalf("bid: %v", err)
+		}
+		if bid.SearcherID != nil {
+			t.Errorf("searcher ID: want none, have %s", bid.SearcherID)
+		}
+	})
+
+	t.Run("signature without searcher", func(t *testing.T) {
+		_, err := service.Bid(ctx, height+1, 0, string(store.BidKindTop), "", txs, "", []byte("signature"))
+		if want, have := block.ErrInvalidRequest, err; !errors.Is(have, want) {
+			t.Fatalf("want %v, have %v", want, have)
+		}
+	})
+
+	t.Run("unknown searcher", func(t *testing.T) {
+		_, err := service.Bid(ctx, height+1, 0, string(store.BidKindTop), "", txs, "00000000-0000-0000-0000-000000000000", []byte("signature"))
+		if want, have := chain.ErrBadSignature, err; !errors.Is(have, want) {
+			t.Fatalf("want %v, have %v", want, have)
+		}
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		bid, err := service.Bid(ctx, height+1, 0, string(store.BidKindTop), "", txs, searcher.ID.String(), []byte("signature"))
+		if err != nil {
+			t.Fatalf("bid: %v", err)
+		}
+
+		stored, err := testStore.SelectBid(ctx, bid.ID.String())
+		if err != nil {
+			t.Fatalf("select bid: %v", err)
+		}
+		if stored.SearcherID == nil || *stored.SearcherID != searcher.ID {
+			t.Fatalf("searcher ID: want %s, have %v", searcher.ID, stored.SearcherID)
+		}
+
+		// The searcher isn't a payer, but signed the bid, so it can replace it.
+		replaced, err := service.ReplaceBid(ctx, bid.ID.String(), txs, "test", searcherKey, []byte("signature"))
+		if err != nil {
+			t.Fatalf("replace: %v", err)
+		}
+		if replaced.SearcherID == nil || *replaced.SearcherID != searcher.ID {
+			t.Fatalf("replaced searcher ID: want %s, have %v", searcher.ID, replaced.SearcherID)
+		}
+
+		err = service.CancelBid(ctx, replaced.ID.String(), "test", []byte("stranger"), []byte("signature"))
+		if want, have := chain.ErrBadSignature, err; !errors.Is(have, want) {
+			t.Fatalf("cancel by stranger: want %v, have %v", want, have)
+		}
+
+		if err := service.CancelBid(ctx, replaced.ID.String(), "test", searcherKey, []byte("signature")); err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+	})
+}
+
+func TestServiceBuild(t *testing.T) {
+	t.Skip("TODO")
+}
+
+func TestServiceBuildV1Retry(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service     = block.NewCoreService(mockChain, testStore)
+		buildHeight = height + 1
+		txs         = [][]byte{[]byte("tx1"), []byte("tx2")}
+		signature   = []byte("signature")
+	)
+
+	txs1, payment1, err := service.BuildV1(ctx, buildHeight, bar.Address, -1, -1, txs, signature)
+	if err != nil {
+		t.Fatalf("first build: %v", err)
+	}
+
+	txs2, payment2, err := service.BuildV1(ctx, buildHeight, bar.Address, -1, -1, txs, signature)
+	if err != nil {
+		t.Fatalf("retried build: %v", err)
+	}
+
+	if want, have := fmt.Sprint(txs1), fmt.Sprint(txs2); want != have {
+		t.Errorf("retried build txs: want %s, have %s", want, have)
+	}
+
+	if want, have := payment1, payment2; want != have {
+		t.Errorf("retried build payment: want %s, have %s", want, have)
+	}
+
+	_, _, err = service.BuildV1(ctx, buildHeight, bar.Address, 1024, -1, txs, []byte("other signature"))
+	if want, have := block.ErrAuctionFinished, err; !errors.Is(have, want) {
+		t.Fatalf("different build request: want %v, have %v", want, have)
+	}
+
+	// A retry that arrives while the original build is still running waits
+	// for its result.
+	{
+		nextHeight := buildHeight + 1
+
+		auction, err := service.Auction(ctx, nextHeight)
+		if err != nil {
+			t.Fatalf("auction: %v", err)
+		}
+
+		auction.FinishedAt = time.Now().UTC()
+		if err := testStore.UpsertAuction(ctx, auction); err != nil {
+			t.Fatalf("claim auction: %v", err)
+		}
+
+		type result struct {
+			txs [][]byte
+			err error
+		}
+		resultc := make(chan result, 1)
+		go func() {
+			txs, _, err := service.BuildV1(ctx, nextHeight, bar.Address, -1, -1, txs, signature)
+			resultc <- result{txs, err}
+		}()
+
+		select {
+		case r := <-resultc:
+			t.Fatalf("retry returned before the build finished: %v", r.err)
+		case <-time.After(300 * time.Millisecond):
+		}
+
+		built := [][]byte{[]byte("built")}
+		if err := testStore.InsertBuildResult(ctx, &store.BuildResult{
+			ChainID:          storeChain.ID,
+			Height:           nextHeight,
+			ValidatorAddress: bar.Address,
+			MaxBytes:         -1,
+			MaxGas:           -1,
+			TxsHash:          mekabuild.HashTxs(txs...),
+			Signature:        signature,
+			Txs:              built,
+			ValidatorPayment: "0" + storeChain.PaymentDenom,
+		}); err != nil {
+			t.Fatalf("insert build result: %v", err)
+		}
+
+		select {
+		case r := <-resultc:
+			if r.err != nil {
+				t.Fatalf("waiting retry: %v", r.err)
+			}
+			if want, have := fmt.Sprint(built), fmt.Sprint(r.txs); want != have {
+				t.Errorf("waiting retry txs: want %s, have %s", want, have)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for retry")
+		}
+	}
+}
+
+func TestServiceBuildRetry(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		testStore  = newStore(t, ctx)
+		storeChain = storetest.NewChain(t, testStore)
+		signature  = []byte("signature")
+		mockChain  = &signatureChain{
+			TestChain: &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator},
+			signature: signature,
+		}
+		service     = block.NewCoreService(mockChain, testStore)
+		buildHeight = height + 1
+		txs         = [][]byte{[]byte("tx1"), []byte("tx2")}
+	)
+
+	for _, v := range valset.Set {
+		if err := testStore.UpsertValidator(ctx, &block.Validator{
+			ChainID:        storeChain.ID,
+			Address:        v.Address,
+			PubKeyBytes:    v.PubKeyBytes,
+			PubKeyType:     v.PubKeyType,
+			PaymentAddress: v.Address,
+		}); err != nil {
+			t.Fatalf("register val: %v", err)
+		}
+	}
+
+	txs1, payment1, err := service.Build(ctx, buildHeight, bar.Address, -1, -1, txs, signature)
+	if err != nil {
+		t.Fatalf("first build: %v", err)
+	}
+
+	txs2, payment2, err := service.Build(ctx, buildHeight, bar.Address, -1, -1, txs, signature)
+	if err != nil {
+		t.Fatalf("retried build: %v", err)
+	}
+	if want, have := fmt.Sprint(txs1), fmt.Sprint(txs2); want != have {
+		t.Errorf("retried build txs: want %s, have %s", want, have)
+	}
+	if want, have := payment1, payment2; want != have {
+		t.Errorf("retried build payment: want %s, have %s", want, have)
+	}
+
+	// Retries are authenticated before anything else.
+	if _, _, err := service.Build(ctx, buildHeight, bar.Address, -1, -1, txs, []byte("forged")); !errors.Is(err, chain.ErrBadSignature) {
+		t.Errorf("unauthenticated retry: want %v, have %v", chain.ErrBadSignature, err)
+	}
+
+	// If the build of a claimed auction must be over, and there's no result,
+	// retries fail right away.
+	{
+		nextHeight := buildHeight + 1
+
+		auction, err := service.Auction(ctx, nextHeight)
+		if err != nil {
+			t.Fatalf("auction: %v", err)
+		}
+
+		auction.FinishedAt = time.Now().UTC().Add(-time.Minute)
+		if err := testStore.UpsertAuction(ctx, auction); err != nil {
+			t.Fatalf("claim auction: %v", err)
+		}
+
+		begin := time.Now()
+		if _, _, err := service.Build(ctx, nextHeight, auction.ValidatorAddress, -1, -1, txs, signature); !errors.Is(err, block.ErrAuctionFinished) {
+			t.Errorf("retry of failed build: want %v, have %v", block.ErrAuctionFinished, err)
+		}
+		if took := time.Since(begin); took > time.Second {
+			t.Errorf("retry of failed build: took %s", took)
+		}
+	}
+}
+
+// signatureChain only accepts a single signature.
+type signatureChain struct {
+	*chain.TestChain
+	signature []byte
+}
+
+func (c *signatureChain) VerifySignature(ctx context.Context, pubKeyType string, pubKeyBytes []byte, msg []byte, sig []byte) error {
+	if !bytes.Equal(c.signature, sig) {
+		return chain.ErrBadSignature
+	}
+	return nil
+}
+
+func TestServiceBidCheckTx(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service     = block.NewCoreService(mockChain, testStore)
+		buildHeight = height + 1
+		searcher    = storetest.GenBech32Addr(t, storetest.Network)
+		other       = storetest.GenBech32Addr(t, storetest.Network)
+		invalidTx   = []byte("invalid")
+		validTx     = []byte("valid")
+		staleTx     = []byte("stale")
+	)
+
+	for _, v := range mockChain.Validators.Set {
+		if err := testStore.UpsertValidator(ctx, &block.Validator{
+			ChainID:        storeChain.ID,
+			Address:        v.Address,
+			PubKeyBytes:    v.PubKeyBytes,
+			PubKeyType:     v.PubKeyType,
+			PaymentAddress: v.Address,
+		}); err != nil {
+			t.Fatalf("register val: %v", err)
+		}
+	}
+
+	auction, err := service.Auction(ctx, buildHeight)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	payments := []chain.Payment{
+		{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+		{From: searcher, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+	}
+	otherPayments := []chain.Payment{
+		{From: other, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+		{From: other, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+	}
+	mockChain.Payments = map[string][]chain.Payment{string(invalidTx): payments, string(validTx): payments, string(staleTx): otherPayments}
+	mockChain.InvalidTxs = map[string]bool{string(invalidTx): true}
+
+	if _, err := service.Bid(ctx, buildHeight, 0, string(store.BidKindBlock), "", [][]byte{invalidTx}, "", nil); !errors.Is(err, block.ErrInvalidRequest) {
+		t.Fatalf("invalid bid: want %v, have %v", block.ErrInvalidRequest, err)
+	}
+
+	validBid, err := service.Bid(ctx, buildHeight, 0, string(store.BidKindBlock), "", [][]byte{validTx}, "", nil)
+	if err != nil {
+		t.Fatalf("valid bid: %v", err)
+	}
+
+	staleBid, err := service.Bid(ctx, buildHeight, 0, string(store.BidKindBlock), "", [][]byte{staleTx}, "", nil)
+	if err != nil {
+		t.Fatalf("stale bid: %v", err)
+	}
+
+	// The check has no side effects, so the build checks the bids again, and
+	// rejects those which have become invalid since they were placed.
+	mockChain.InvalidTxs[string(staleTx)] = true
+
+	blockTxs, _, err := service.BuildV1(ctx, buildHeight, bar.Address, -1, -1, nil, []byte("signature"))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	if want, have := fmt.Sprint([][]byte{validTx}), fmt.Sprint(blockTxs); want != have {
+		t.Errorf("block txs: want %s, have %s", want, have)
+	}
+
+	bids, err := testStore.ListBids(ctx, storeChain.ID, buildHeight)
+	if err != nil {
+		t.Fatalf("list bids: %v", err)
+	}
+
+	if want, have := 2, len(bids); want != have {
+		t.Fatalf("bids: want %d, have %d", want, have)
+	}
+
+	for _, b := range bids {
+		switch b.ID {
+		case validBid.ID:
+			if want, have := store.BidStateAccepted, b.State; want != have {
+				t.Errorf("valid bid state: want %s, have %s", want, have)
+			}
+		case staleBid.ID:
+			if want, have := store.BidStateRejected, b.State; want != have {
+				t.Errorf("stale bid state: want %s, have %s", want, have)
+			}
+			if !strings.Contains(b.RejectionReason, chain.ErrInvalidTx.Error()) {
+				t.Errorf("stale bid rejection reason: want %q, have %q", chain.ErrInvalidTx, b.RejectionReason)
+			}
+		default:
+			t.Errorf("unexpected bid %s", b.ID)
+		}
+	}
+}
+
+func TestServiceBuildV1Backrun(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service     = block.NewCoreService(mockChain, testStore)
+		buildHeight = height + 1
+		targetTx    = []byte("target")
+		otherTx     = []byte("other")
+		backrunTx   = []byte("backrun")
+		orphanTx    = []byte("orphan")
+	)
+
+	for _, v := range mockChain.Validators.Set {
+		if err := testStore.UpsertValidator(ctx, &block.Validator{
+			ChainID:        storeChain.ID,
+			Address:        v.Address,
+			PubKeyBytes:    v.PubKeyBytes,
+			PubKeyType:     v.PubKeyType,
+			PaymentAddress: v.Address,
+		}); err != nil {
+			t.Fatalf("register val: %v", err)
+		}
+	}
+
+	auction, err := service.Auction(ctx, buildHeight)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	newBackrun := func(tx []byte, target string, priority int64) *block.Bid {
+		t.Helper()
+		addr := storetest.GenBech32Addr(t, storetest.Network)
+		bid := &block.Bid{
+			ChainID:          storeChain.ID,
+			Height:           buildHeight,
+			Kind:             store.BidKindBackrun,
+			TargetTxHash:     target,
+			Txs:              [][]byte{tx},
+			MekatekPayment:   priority / 10,
+			ValidatorPayment: priority - priority/10,
+			Priority:         priority,
+			State:            store.BidStatePending,
+			Payments: []block.Payment{
+				{From: addr, To: auction.MekatekPaymentAddress, Amount: priority / 10},
+				{From: addr, To: auction.ValidatorPaymentAddress, Amount: priority - priority/10},
+			},
+		}
+		if err := testStore.InsertBid(ctx, bid); err != nil {
+			t.Fatalf("insert bid: %v", err)
+		}
+		return bid
+	}
+
+	var (
+		backrunBid = newBackrun(backrunTx, cryptoutil.HashTx(targetTx), 100)
+		orphanBid  = newBackrun(orphanTx, cryptoutil.HashTx([]byte("missing")), 50)
+	)
+
+	blockTxs, _, err := service.BuildV1(ctx, buildHeight, bar.Address, -1, -1, [][]byte{targetTx, otherTx}, []byte("signature"))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	if want, have := fmt.Sprint([][]byte{targetTx, backrunTx, otherTx}), fmt.Sprint(blockTxs); want != have {
+		t.Errorf("block txs: want %s, have %s", want, have)
+	}
+
+	bids, err := testStore.ListBids(ctx, storeChain.ID, buildHeight)
+	if err != nil {
+		t.Fatalf("list bids: %v", err)
+	}
+
+	states := map[string]store.BidState{}
+	for _, b := range bids {
+		states[b.ID.String()] = b.State
+	}
+
+	if want, have := store.BidStateAccepted, states[backrunBid.ID.String()]; want != have {
+		t.Errorf("backrun bid state: want %s, have %s", want, have)
+	}
+
+	if want, have := store.BidStateRejected, states[orphanBid.ID.String()]; want != have {
+		t.Errorf("orphan bid state: want %s, have %s", want, have)
+	}
+}
+
+func TestServiceBuildV1Selection(t *testing.T) {
+	t.Parallel()
+
+	var (
+		txA = []byte("a")
+		txB = []byte("b")
+		txC = []byte("c")
+	)
+
+	// One high priority bid shares a tx with each of two slightly lower
+	// priority bids, which together pay more.
+	for _, testcase := range []struct {
+		selection  store.BidSelection
+		wantTxs    [][]byte
+		wantStates []store.BidState
+	}{
+		{
+			selection:  store.BidSelectionGreedy,
+			wantTxs:    [][]byte{txA, txB},
+			wantStates: []store.BidState{store.BidStateAccepted, store.BidStateRejected, store.BidStateRejected},
+		},
+		{
+			selection:  store.BidSelectionMaxRevenue,
+			wantTxs:    [][]byte{txA, txC, txB},
+			wantStates: []store.BidState{store.BidStateRejected, store.BidStateAccepted, store.BidStateAccepted},
+		},
+	} {
+		testcase := testcase
+		t.Run(string(testcase.selection), func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				ctx    = context.Background()
+				foo    = newTestValidator()
+				bar    = newTestValidator()
+				height = int64(123)
+				valset = chain.ValidatorSet{
+					Height: height,
+					Set: map[string]*chain.Validator{
+						foo.Address: foo.Validator,
+						bar.Address: bar.Validator,
+					},
+					TotalPower: foo.VotingPower + bar.VotingPower,
+				}
+				testStore   = newStore(t, ctx)
+				storeChain  = storetest.NewChain(t, testStore)
+				mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+				service     = block.NewCoreService(mockChain, testStore)
+				buildHeight = height + 1
+			)
+
+			storeChain.BidSelection = testcase.selection
+			if err := testStore.UpsertChain(ctx, storeChain); err != nil {
+				t.Fatalf("upsert chain: %v", err)
+			}
+
+			for _, v := range mockChain.Validators.Set {
+				if err := testStore.UpsertValidator(ctx, &block.Validator{
+					ChainID:        storeChain.ID,
+					Address:        v.Address,
+					PubKeyBytes:    v.PubKeyBytes,
+					PubKeyType:     v.PubKeyType,
+					PaymentAddress: v.Address,
+				}); err != nil {
+					t.Fatalf("register val: %v", err)
+				}
+			}
+
+			auction, err := service.Auction(ctx, buildHeight)
+			if err != nil {
+				t.Fatalf("auction: %v", err)
+			}
+
+			newBid := func(txs [][]byte, priority int64) *block.Bid {
+				t.Helper()
+				addr := storetest.GenBech32Addr(t, storetest.Network)
+				bid := &block.Bid{
+					ChainID:          storeChain.ID,
+					Height:           buildHeight,
+					Kind:             store.BidKindBlock,
+					Txs:              txs,
+					MekatekPayment:   priority / 10,
+					ValidatorPayment: priority - priority/10,
+					Priority:         priority,
+					State:            store.BidStatePending,
+					Payments: []block.Payment{
+						{From: addr, To: auction.MekatekPaymentAddress, Amount: priority / 10},
+						{From: addr, To: auction.ValidatorPaymentAddress, Amount: priority - priority/10},
+					},
+				}
+				if err := testStore.InsertBid(ctx, bid); err != nil {
+					t.Fatalf("insert bid: %v", err)
+				}
+				return bid
+			}
+
+			placedBids := []*block.Bid{
+				newBid([][]byte{txA, txB}, 100),
+				newBid([][]byte{txA, txC}, 60),
+				newBid([][]byte{txB}, 50),
+			}
+
+			blockTxs, _, err := service.BuildV1(ctx, buildHeight, bar.Address, -1, -1, nil, []byte("signature"))
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+
+			if want, have := fmt.Sprint(testcase.wantTxs), fmt.Sprint(blockTxs); want != have {
+				t.Errorf("block txs: want %s, have %s", want, have)
+			}
+
+			bids, err := testStore.ListBids(ctx, storeChain.ID, buildHeight)
+			if err != nil {
+				t.Fatalf("list bids: %v", err)
+			}
+
+			states := map[string]store.BidState{}
+			for _, b := range bids {
+				states[b.ID.String()] = b.State
+			}
+
+			for i, b := range placedBids {
+				if want, have := testcase.wantStates[i], states[b.ID.String()]; want != have {
+					t.Errorf("bid %d state: want %s, have %s", i+1, want, have)
+				}
+			}
+		})
+	}
+}
+
+func TestServicePreview(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		searcher    = storetest.GenBech32Addr(t, storetest.Network)
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service     = block.NewCoreService(mockChain, testStore)
+		buildHeight = height + 1
+		bidTx       = []byte("pay")
+		mempoolTx   = []byte("mempool")
+	)
+
+	mockChain.PredictedProposer.PaymentAddress = bar.Address
+
+	if _, err := service.Preview(ctx, buildHeight, bar.Address, -1, -1, nil, []byte("signature")); !errors.Is(err, block.ErrAuctionUnavailable) {
+		t.Fatalf("unregistered: want %v, have %v", block.ErrAuctionUnavailable, err)
+	}
+
+	for _, v := range mockChain.Validators.Set {
+		if err := testStore.UpsertValidator(ctx, &block.Validator{
+			ChainID:        storeChain.ID,
+			Address:        v.Address,
+			PubKeyBytes:    v.PubKeyBytes,
+			PubKeyType:     v.PubKeyType,
+			PaymentAddress: v.Address,
+		}); err != nil {
+			t.Fatalf("register val: %v", err)
+		}
+	}
+
+	auction, err := service.Auction(ctx, buildHeight)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	mockChain.Payments = map[string][]chain.Payment{
+		string(bidTx): {
+			{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+			{From: searcher, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+		},
+	}
+
+	bid, err := service.Bid(ctx, buildHeight, 0, string(store.BidKindTop), "", [][]byte{bidTx}, "", nil)
+	if err != nil {
+		t.Fatalf("bid: %v", err)
+	}
+
+	preview, err := service.Preview(ctx, buildHeight, bar.Address, -1, -1, [][]byte{mempoolTx}, []byte("signature"))
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+
+	wantTxs := []store.BuildTx{
+		{Hash: cryptoutil.HashTx(bidTx), Source: store.BuildTxSourceBid, BidID: &bid.ID},
+		{Hash: cryptoutil.HashTx(mempoolTx), Source: store.BuildTxSourceMempool},
+	}
+	if want, have := fmt.Sprint(wantTxs), fmt.Sprint(preview.Txs); want != have {
+		t.Errorf("preview txs: want %s, have %s", want, have)
+	}
+
+	if want, have := int64(97), preview.ValidatorPayment; want != have {
+		t.Errorf("validator payment: want %d, have %d", want, have)
+	}
+
+	if want, have := "97"+storeChain.PaymentDenom, preview.ValidatorCoins; want != have {
+		t.Errorf("validator coins: want %s, have %s", want, have)
+	}
+
+	if want, have := bar.Address, preview.ValidatorPaymentAddress; want != have {
+		t.Errorf("validator payment address: want %s, have %s", want, have)
+	}
+
+	// Nothing is claimed or changed by the preview.
+	{
+		a, err := testStore.SelectAuction(ctx, storeChain.ID, buildHeight)
+		if err != nil {
+			t.Fatalf("select auction: %v", err)
+		}
+		if !a.FinishedAt.IsZero() {
+			t.Errorf("auction finished at %s", a.FinishedAt)
+		}
+
+		b, err := testStore.SelectBid(ctx, bid.ID.String())
+		if err != nil {
+			t.Fatalf("select bid: %v", err)
+		}
+		if want, have := store.BidStatePending, b.State; want != have {
+			t.Errorf("bid state: want %s, have %s", want, have)
+		}
+	}
+
+	blockTxs, _, err := service.BuildV1(ctx, buildHeight, bar.Address, -1, -1, [][]byte{mempoolTx}, []byte("signature"))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	if want, have := fmt.Sprint([][]byte{bidTx, mempoolTx}), fmt.Sprint(blockTxs); want != have {
+		t.Errorf("block txs: want %s, have %s", want, have)
+	}
+
+	if _, err := service.Preview(ctx, buildHeight, bar.Address, -1, -1, nil, []byte("signature")); !errors.Is(err, block.ErrAuctionFinished) {
+		t.Errorf("after build: want %v, have %v", block.ErrAuctionFinished, err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		searcher    = storetest.GenBech32Addr(t, storetest.Network)
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service     = block.NewCoreService(mockChain, testStore)
+		buildHeight = height + 1
+		bidTx       = []byte("pay")
+		mempoolTxs  = [][]byte{[]byte("tx1"), []byte("tx2")}
+	)
+
+	for _, v := range mockChain.Validators.Set {
+		if err := testStore.UpsertValidator(ctx, &block.Validator{
+			ChainID:        storeChain.ID,
+			Address:        v.Address,
+			PubKeyBytes:    v.PubKeyBytes,
+			PubKeyType:     v.PubKeyType,
+			PaymentAddress: v.Address,
+		}); err != nil {
+			t.Fatalf("register val: %v", err)
+		}
+	}
+
+	auction, err := service.Auction(ctx, buildHeight)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	mockChain.Payments = map[string][]chain.Payment{
+		string(bidTx): {
+			{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+			{From: searcher, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+		},
+	}
+
+	if _, err := service.Bid(ctx, buildHeight, 0, string(store.BidKindTop), "", [][]byte{bidTx}, "", nil); err != nil {
+		t.Fatalf("bid: %v", err)
+	}
+
+	if _, _, err := service.BuildV1(ctx, buildHeight, bar.Address, -1, -1, mempoolTxs, []byte("signature")); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	recorded, err := testStore.SelectBuild(ctx, storeChain.ID, buildHeight)
+	if err != nil {
+		t.Fatalf("select build: %v", err)
+	}
+
+	auction, err = testStore.SelectAuction(ctx, storeChain.ID, buildHeight)
+	if err != nil {
+		t.Fatalf("select auction: %v", err)
+	}
+
+	bids, err := testStore.ListBids(ctx, storeChain.ID, buildHeight)
+	if err != nil {
+		t.Fatalf("list bids: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		name      string
+		balance   int64
+		wantState store.BidState
+		wantTxs   int
+	}{
+		{name: "same balances", balance: 100, wantState: store.BidStateAccepted, wantTxs: 3},
+		{name: "insufficient balance", balance: 10, wantState: store.BidStateRejected, wantTxs: 2},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			recordedChain := &chain.RecordedChain{
+				ChainID:  storeChain.ID,
+				Height:   height,
+				Balances: map[string]map[string]int64{searcher: {storetest.Denom: testcase.balance}},
+			}
+
+			build, replayed, err := block.Replay(ctx, recordedChain, auction, storeChain.BidSelection, bids, mempoolTxs, -1, -1)
+			if err != nil {
+				t.Fatalf("replay: %v", err)
+			}
+
+			if want, have := testcase.wantState, replayed[0].State; want != have {
+				t.Errorf("bid state: want %s, have %s", want, have)
+			}
+
+			if want, have := store.BidStateAccepted, bids[0].State; want != have {
+				t.Errorf("stored bid state: want %s, have %s", want, have)
+			}
+
+			if want, have := testcase.wantTxs, len(build.Txs); want != have {
+				t.Fatalf("tx count: want %d, have %d", want, have)
+			}
+
+			if testcase.wantTxs == len(recorded.Txs) {
+				if want, have := fmt.Sprint(recorded.Txs), fmt.Sprint(build.Txs); want != have {
+					t.Errorf("txs: want %s, have %s", want, have)
+				}
+				if want, have := recorded.ValidatorPayment, build.ValidatorPayment; want != have {
+					t.Errorf("validator payment: want %d, have %d", want, have)
+				}
+			}
+		})
+	}
+
+	// Replays evaluate copies of the bids, so normalizing the txs of a bid
+	// placed for an earlier auction doesn't change the original.
+	{
+		normalizing := &normalizingChain{RecordedChain: &chain.RecordedChain{
+			ChainID:  storeChain.ID,
+			Height:   height,
+			Balances: map[string]map[string]int64{searcher: {storetest.Denom: 100}},
+		}}
+
+		rangeBid := *bids[0]
+		rangeBid.Height, rangeBid.MaxHeight = buildHeight-1, buildHeight
+		rangeBid.Txs = [][]byte{bidTx}
+
+		if _, _, err := block.Replay(ctx, normalizing, auction, storeChain.BidSelection, []*block.Bid{&rangeBid}, mempoolTxs, -1, -1); err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+
+		if want, have := string(bidTx), string(rangeBid.Txs[0]); want != have {
+			t.Errorf("range bid tx: want %q, have %q", want, have)
+		}
+	}
+}
+
+// normalizingChain re-encodes every tx differently than it was submitted.
+type normalizingChain struct {
+	*chain.RecordedChain
+}
+
+func (c *normalizingChain) EncodeTransaction(ctx context.Context, tx chain.Transaction) ([]byte, error) {
+	txb, err := c.RecordedChain.EncodeTransaction(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("normalized "), txb...), nil
+}
+
+func TestServiceBuildV1Record(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service     = block.NewCoreService(mockChain, testStore)
+		buildHeight = height + 1
+		txs         = [][]byte{[]byte("tx1"), []byte("tx2")}
+	)
+
+	blockTxs, _, err := service.BuildV1(ctx, buildHeight, bar.Address, 1024, -1, txs, []byte("signature"))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	build, err := testStore.SelectBuild(ctx, storeChain.ID, buildHeight)
+	if err != nil {
+		t.Fatalf("select build: %v", err)
+	}
+
+	if want, have := bar.Address, build.ValidatorAddress; want != have {
+		t.Errorf("validator address: want %s, have %s", want, have)
+	}
+
+	if want, have := int64(1024), build.MaxBytes; want != have {
+		t.Errorf("max bytes: want %d, have %d", want, have)
+	}
+
+	if want, have := len(blockTxs), len(build.Txs); want != have {
+		t.Fatalf("tx count: want %d, have %d", want, have)
+	}
+
+	for i, tx := range build.Txs {
+		if want, have := cryptoutil.HashTx(blockTxs[i]), tx.Hash; want != have {
+			t.Errorf("tx %d hash: want %s, have %s", i, want, have)
+		}
+		if want, have := store.BuildTxSourceMempool, tx.Source; want != have {
+			t.Errorf("tx %d source: want %s, have %s", i, want, have)
+		}
+	}
+}
+
+func TestServiceTrackInclusion(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx    = context.Background()
+		foo    = newTestValidator()
+		bar    = newTestValidator()
+		height = int64(123)
+		valset = chain.ValidatorSet{
+			Height: height,
+			Set: map[string]*chain.Validator{
+				foo.Address: foo.Validator,
+				bar.Address: bar.Validator,
+			},
+			TotalPower: foo.VotingPower + bar.VotingPower,
+		}
+		testStore   = newStore(t, ctx)
+		storeChain  = storetest.NewChain(t, testStore)
+		mockChain   = &chain.TestChain{ChainID: storeChain.ID, Height: height, Validators: valset, PredictedProposer: *bar.Validator}
+		service     = block.NewCoreService(mockChain, testStore)
+		buildHeight = height + 1
+		txs         = [][]byte{[]byte("tx1"), []byte("tx2")}
+	)
+
+	blockTxs, _, err := service.BuildV1(ctx, buildHeight, bar.Address, -1, -1, txs, []byte("signature"))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	// The build height isn't committed yet, so nothing should be checked.
+	if err := service.TrackInclusion(ctx); err != nil {
+		t.Fatalf("track inclusion before commit: %v", err)
+	}
+
+	build, err := testStore.SelectBuild(ctx, storeChain.ID, buildHeight)
+	if err != nil {
+		t.Fatalf("select build: %v", err)
+	}
+
+	if want, have := store.BuildInclusionPending, build.InclusionState; want != have {
+		t.Fatalf("inclusion state before commit: want %s, have %s", want, have)
+	}
+
+	// Commit the block in reverse order.
+	var committedTxs [][]byte
+	for i := len(blockTxs) - 1; i >= 0; i-- {
+		committedTxs = append(committedTxs, blockTxs[i])
+	}
+	mockChain.Height = buildHeight
+	mockChain.Blocks = map[int64]*chain.Block{
+		buildHeight: {Height: buildHeight, ProposerAddress: foo.Address, Txs: committedTxs},
+	}
+
+	if err := service.TrackInclusion(ctx); err != nil {
+		t.Fatalf("track inclusion: %v", err)
+	}
+
+	build, err = testStore.SelectBuild(ctx, storeChain.ID, buildHeight)
+	if err != nil {
+		t.Fatalf("select build: %v", err)
+	}
+
+	if want, have := store.BuildInclusionReordered, build.InclusionState; want != have {
+		t.Errorf("inclusion state: want %s, have %s", want, have)
+	}
+
+	if want, have := foo.Address, build.ProposerAddress; want != have {
+		t.Errorf("proposer address: want %s, have %s", want, have)
+	}
+
+	// A build whose block can't be fetched stays pending for a while, and is
+	// given up on once it's far enough behind the latest height.
+	{
+		prunedHeight := buildHeight + 1
+		if err := testStore.InsertBuild(ctx, &store.Build{
+			ChainID:          storeChain.ID,
+			Height:           prunedHeight,
+			ValidatorAddress: bar.Address,
+			PaymentDenom:     storeChain.PaymentDenom,
+		}); err != nil {
+			t.Fatalf("insert build: %v", err)
+		}
+
+		mockChain.Height = prunedHeight + 1
+		if err := service.TrackInclusion(ctx); err == nil {
+			t.Fatalf("track inclusion of recent missing block: want error, have none")
+		}
+
+		build, err := testStore.SelectBuild(ctx, storeChain.ID, prunedHeight)
+		if err != nil {
+			t.Fatalf("select build: %v", err)
+		}
+
+		if want, have := store.BuildInclusionPending, build.InclusionState; want != have {
+			t.Fatalf("recent missing block: want %s, have %s", want, have)
+		}
+
+		mockChain.Height = prunedHeight + 1000
+		if err := service.TrackInclusion(ctx); err != nil {
+			t.Fatalf("track inclusion of old missing block: %v", err)
+		}
+
+		build, err = testStore.SelectBuild(ctx, storeChain.ID, prunedHeight)
+		if err != nil {
+			t.Fatalf("select build: %v", err)
+		}
+
+		if want, have := store.BuildInclusionUnknown, build.InclusionState; want != have {
+			t.Fatalf("old missing block: want %s, have %s", want, have)
+		}
+	}
 }
 
 func TestAllocation(t *testing.T) {
@@ -2,11 +2,23 @@ package block
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"mekapi/trc"
+	"mekapi/trc/eztrc"
 	"strings"
+	"sync"
 	"time"
 
 	"zenith/chain"
+	"zenith/cryptoutil"
+	"zenith/store"
+
+	"github.com/gofrs/uuid"
+	"github.com/meka-dev/mekatek-go/mekabuild"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
@@ -20,8 +32,14 @@ var (
 // FixedAllocation is a constant representing the portion of bid payment that
 // validators receive. Historically, we started with a dynamic Allocation
 // function below, but received pushback from validators, so we have this now.
+// It's the default for chains which don't configure their own allocation.
 const FixedAllocation = 0.97
 
+// DefaultAllocationTolerance is how far the allocation of a bid's payments may
+// be from the auction's allocation, for chains which don't configure their own
+// tolerance.
+const DefaultAllocationTolerance = 0.01
+
 // Allocation is a linear function that computes the validator payment
 // allocation for a given auction. It is a function of the total
 // participation ratio of validator voting power in the block builder API,
@@ -34,6 +52,83 @@ func Allocation(registeredPower, totalPower int64) float64 {
 	return powerShare*(max-min) + min
 }
 
+// chainAllocation returns the validator allocation for a new auction on the
+// chain with the given proposer, according to the chain's allocation policy.
+func chainAllocation(ch *store.Chain, validatorAddr string, registeredPower, totalPower int64) float64 {
+	switch store.ParseAllocationPolicy(string(ch.AllocationPolicy)) {
+	case store.AllocationPolicyPowerLinear:
+		return Allocation(registeredPower, totalPower)
+	case store.AllocationPolicyPerValidator:
+		if allocation, ok := ch.ValidatorAllocations[validatorAddr]; ok {
+			return allocation
+		}
+	}
+
+	if ch.Allocation == nil {
+		return FixedAllocation
+	}
+	return *ch.Allocation
+}
+
+// allocationTolerance returns the tolerance, or the default if it's unset.
+func allocationTolerance(tolerance *float64) float64 {
+	if tolerance == nil {
+		return DefaultAllocationTolerance
+	}
+	return *tolerance
+}
+
+// SearcherChallengeSignBytes returns the bytes a searcher signs to prove it
+// holds the private key of the pub key it's registering.
+func SearcherChallengeSignBytes(chainID string, challenge []byte) []byte {
+	return []byte(fmt.Sprintf("zenith register searcher %s %X", chainID, challenge))
+}
+
+// BidSignBytes returns the bytes a registered searcher signs to submit a bid.
+func BidSignBytes(chainID string, height, maxHeight int64, kind, targetTxHash string, txs [][]byte) []byte {
+	return []byte(fmt.Sprintf("zenith bid %s %d %d %s %q %X", chainID, height, maxHeight, kind, targetTxHash, mekabuild.HashTxs(txs...)))
+}
+
+// CancelBidSignBytes returns the bytes a searcher signs to cancel a bid.
+func CancelBidSignBytes(chainID, bidID string) []byte {
+	return []byte(fmt.Sprintf("zenith cancel bid %s %s", chainID, bidID))
+}
+
+// ReplaceBidSignBytes returns the bytes a searcher signs to replace a bid with
+// a new bid made of txs.
+func ReplaceBidSignBytes(chainID, bidID string, txs [][]byte) []byte {
+	return []byte(fmt.Sprintf("zenith replace bid %s %s %X", chainID, bidID, mekabuild.HashTxs(txs...)))
+}
+
+// validateBidTarget checks that backrun bids, and only backrun bids, have a
+// well-formed target tx hash, and normalizes it to the form of HashTx.
+func validateBidTarget(bid *store.Bid) error {
+	if bid.Kind != store.BidKindBackrun {
+		if bid.TargetTxHash != "" {
+			return fmt.Errorf("target tx hash is only valid for %s bids", store.BidKindBackrun)
+		}
+		return nil
+	}
+
+	if bid.TargetTxHash == "" {
+		return fmt.Errorf("%s bid requires a target tx hash", store.BidKindBackrun)
+	}
+
+	if b, err := hex.DecodeString(bid.TargetTxHash); err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("target tx hash %q invalid", bid.TargetTxHash)
+	}
+
+	bid.TargetTxHash = strings.ToUpper(bid.TargetTxHash)
+
+	for _, tx := range bid.Txs {
+		if cryptoutil.HashTx(tx) == bid.TargetTxHash {
+			return fmt.Errorf("%s bid includes its own target tx", store.BidKindBackrun)
+		}
+	}
+
+	return nil
+}
+
 func boolString(b bool, ifTrue, ifFalse string) string {
 	if b {
 		return ifTrue
@@ -69,6 +164,154 @@ func getTxGas(ctx context.Context, c chain.Chain, tx []byte) (int64, error) {
 	return gas, nil
 }
 
+// getBundleSize sums the bytes and gas of the txs. Txs which can't be decoded
+// are traced and count as zero.
+func getBundleSize(ctx context.Context, c chain.Chain, txs [][]byte) (bundleBytes, bundleGas int64) {
+	for i, txb := range txs {
+		ctx := trc.PrefixContextf(ctx, "tx %d/%d", i+1, len(txs))
+
+		txBytes, err := getTxBytes(ctx, c, txb)
+		if err != nil {
+			eztrc.Tracef(ctx, "get bytes: %v", err)
+			continue
+		}
+
+		txGas, err := getTxGas(ctx, c, txb)
+		if err != nil {
+			eztrc.Tracef(ctx, "get gas: %v", err)
+			continue
+		}
+
+		bundleBytes += txBytes
+		bundleGas += txGas
+	}
+	return bundleBytes, bundleGas
+}
+
+// detachedContext keeps the values of its parent, e.g. the trace, but not its
+// deadline or cancelation, for work that has to finish regardless.
+type detachedContext struct{ context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// SearcherAdmission decides whether a bid from a searcher, whose signature has
+// been verified, may be placed. It returns an error to reject the bid.
+type SearcherAdmission func(searcherID string) error
+
+type searcherAdmissionKey struct{}
+
+// WithSearcherAdmission returns a context that makes Bid check each verified
+// searcher with f, e.g. to rate limit searchers by an ID they can't spoof.
+func WithSearcherAdmission(ctx context.Context, f SearcherAdmission) context.Context {
+	return context.WithValue(ctx, searcherAdmissionKey{}, f)
+}
+
+func admitSearcher(ctx context.Context, searcherID string) error {
+	if f, _ := ctx.Value(searcherAdmissionKey{}).(SearcherAdmission); f != nil {
+		return f(searcherID)
+	}
+	return nil
+}
+
+// maxBidChecks is the most bids checked by the chain at once.
+const maxBidChecks = 8
+
+// checkBids checks every bid with checkBid, a few at a time, and returns the
+// error of each bid that the chain would reject. Bids that can't be checked,
+// e.g. because the nodes are unavailable, are traced and kept.
+func checkBids(ctx context.Context, c chain.Chain, bids []*store.Bid) map[uuid.UUID]error {
+	var (
+		mtx     sync.Mutex
+		invalid = map[uuid.UUID]error{}
+	)
+
+	var g errgroup.Group
+	g.SetLimit(maxBidChecks)
+	for _, bid := range bids {
+		bid := bid
+		g.Go(func() error {
+			err := checkBid(ctx, c, bid)
+			switch {
+			case err == nil:
+			case errors.Is(err, chain.ErrInvalidTx):
+				mtx.Lock()
+				invalid[bid.ID] = err
+				mtx.Unlock()
+			default:
+				eztrc.Errorf(ctx, "check bid %s: %v", bid.ID, err)
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	return invalid
+}
+
+// maxBalanceQueries is the most account balances fetched from the chain at
+// once.
+const maxBalanceQueries = 8
+
+// fetchBalances gets the balance of each key as of the height, a few at a time.
+// Balances which can't be fetched are traced and missing from the result.
+func fetchBalances(ctx context.Context, c chain.Chain, height int64, keys map[balanceKey]struct{}) map[balanceKey]int64 {
+	var (
+		mtx      sync.Mutex
+		balances = make(map[balanceKey]int64, len(keys))
+		errs     = map[balanceKey]error{}
+	)
+
+	var g errgroup.Group
+	g.SetLimit(maxBalanceQueries)
+	for k := range keys {
+		k := k
+		g.Go(func() error {
+			balance, err := c.AccountBalance(ctx, height, k.addr, k.denom)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				errs[k] = err
+			} else {
+				balances[k] = balance
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	for k, err := range errs {
+		eztrc.Errorf(ctx, "get account balance for sender %s in %s: %v", k.addr, k.denom, err)
+	}
+
+	return balances
+}
+
+// bidMaxHeight returns the last height the bid is eligible for.
+func bidMaxHeight(b *store.Bid) int64 {
+	if b.MaxHeight > b.Height {
+		return b.MaxHeight
+	}
+	return b.Height
+}
+
+// copyBid returns a copy of the bid that can be evaluated without changing
+// the original, e.g. when txs are normalized.
+func copyBid(b *store.Bid) *store.Bid {
+	c := *b
+	c.Txs = append([][]byte(nil), b.Txs...)
+	c.Payments = append([]store.Payment(nil), b.Payments...)
+	return &c
+}
+
+// bidEligible returns true if the bid can still be accepted by an auction
+// after its Height.
+func bidEligible(b *store.Bid) bool {
+	return b.MaxHeight > b.Height && (b.State == store.BidStatePending || b.State == store.BidStateRejected)
+}
+
 func traceTime(t time.Time) string {
 	switch {
 	case t.IsZero():
@@ -7,14 +7,27 @@ import (
 type CachedChain struct {
 	Chain
 
-	cache abstractCache[int64, *ValidatorSet]
+	cache    abstractCache[int64, *ValidatorSet]
+	balances abstractCache[balanceCacheKey, int64]
 }
 
+// balanceCacheKey identifies the balance of one denom in an account, as of a
+// specific height, so it never changes.
+type balanceCacheKey struct {
+	height int64
+	addr   string
+	denom  string
+}
+
+// balanceCacheCapacity is enough for the senders of a few auctions.
+const balanceCacheCapacity = 1000
+
 func WithCondCache(chain Chain) Chain {
 	return &CachedChain{
 		Chain: chain,
 
-		cache: newCondCache[int64, *ValidatorSet](100),
+		cache:    newCondCache[int64, *ValidatorSet](100),
+		balances: newCondCache[balanceCacheKey, int64](balanceCacheCapacity),
 	}
 }
 
@@ -22,7 +35,8 @@ func WithRingCache(chain Chain) Chain {
 	return &CachedChain{
 		Chain: chain,
 
-		cache: newRingCache[int64, *ValidatorSet](100),
+		cache:    newRingCache[int64, *ValidatorSet](100),
+		balances: newRingCache[balanceCacheKey, int64](balanceCacheCapacity),
 	}
 }
 
@@ -30,6 +44,18 @@ func (c *CachedChain) ValidatorSet(ctx context.Context, targetHeight int64) (_ *
 	return c.cache.Get(ctx, targetHeight, c.Chain.ValidatorSet)
 }
 
+// AccountBalance caches balances at specific heights. A height of 0 or less
+// means the latest, which changes, so it isn't cached.
+func (c *CachedChain) AccountBalance(ctx context.Context, height int64, addr, denom string) (int64, error) {
+	if height <= 0 {
+		return c.Chain.AccountBalance(ctx, height, addr, denom)
+	}
+
+	return c.balances.Get(ctx, balanceCacheKey{height, addr, denom}, func(ctx context.Context, k balanceCacheKey) (int64, error) {
+		return c.Chain.AccountBalance(ctx, k.height, k.addr, k.denom)
+	})
+}
+
 type abstractCache[K comparable, V any] interface {
 	Len(ctx context.Context) (int, error)
 	Get(ctx context.Context, key K, fill func(context.Context, K) (V, error)) (V, error)
@@ -2,7 +2,9 @@ package chain
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"testing"
 
@@ -133,3 +135,77 @@ func TestCacheGauntlet(t *testing.T) {
 		})
 	}
 }
+
+type balanceCountingChain struct {
+	TestChain
+
+	mtx     sync.Mutex
+	queries map[string]int
+	fail    bool
+}
+
+func (c *balanceCountingChain) AccountBalance(ctx context.Context, height int64, addr, denom string) (int64, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.queries[fmt.Sprintf("%d/%s/%s", height, addr, denom)]++
+	if c.fail {
+		return 0, errors.New("node unavailable")
+	}
+	return height * 10, nil
+}
+
+func TestCachedChainAccountBalance(t *testing.T) {
+	ctx := context.Background()
+
+	for _, testcase := range []struct {
+		name string
+		cons func(Chain) Chain
+	}{
+		{"ring", WithRingCache},
+		{"cond", WithCondCache},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			counting := &balanceCountingChain{queries: map[string]int{}}
+			c := testcase.cons(counting)
+
+			balance := func(height int64, addr, denom string) int64 {
+				t.Helper()
+				b, err := c.AccountBalance(ctx, height, addr, denom)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return b
+			}
+
+			if want, have := int64(50), balance(5, "addr1", "uosmo"); want != have {
+				t.Fatalf("balance: want %d, have %d", want, have)
+			}
+			balance(5, "addr1", "uosmo")
+			balance(5, "addr1", "uatom")
+			balance(6, "addr1", "uosmo")
+			balance(0, "addr1", "uosmo") // latest, never cached
+			balance(0, "addr1", "uosmo")
+
+			for key, want := range map[string]int{
+				"5/addr1/uosmo": 1,
+				"5/addr1/uatom": 1,
+				"6/addr1/uosmo": 1,
+				"0/addr1/uosmo": 2,
+			} {
+				if have := counting.queries[key]; want != have {
+					t.Errorf("%s: queried %d times, want %d", key, have, want)
+				}
+			}
+
+			// Errors aren't cached.
+			counting.fail = true
+			if _, err := c.AccountBalance(ctx, 7, "addr1", "uosmo"); err == nil {
+				t.Fatalf("want error")
+			}
+			counting.fail = false
+			if want, have := int64(70), balance(7, "addr1", "uosmo"); want != have {
+				t.Fatalf("balance after error: want %d, have %d", want, have)
+			}
+		})
+	}
+}
@@ -9,19 +9,23 @@ var (
 	ErrInvalidKey   = errors.New("invalid key")
 	ErrBadSignature = errors.New("bad signature")
 	ErrNoPayment    = errors.New("no payment")
+	ErrInvalidTx    = errors.New("invalid transaction")
 )
 
 type Chain interface {
 	ID() string
 	ValidatePaymentAddress(ctx context.Context, addr string) error
 	VerifySignature(ctx context.Context, pubKeyType string, pubKeyBytes []byte, msg []byte, sig []byte) error
+	AccountAddress(ctx context.Context, pubKeyType string, pubKeyBytes []byte) (string, error)
 	LatestHeight(ctx context.Context) (int64, error)
 	DecodeTransaction(ctx context.Context, txb []byte) (Transaction, error)
 	EncodeTransaction(ctx context.Context, tx Transaction) ([]byte, error)
+	CheckTransaction(ctx context.Context, txb []byte) error // ErrInvalidTx if the chain would reject the tx, without side effects
 	AccountBalance(ctx context.Context, height int64, addr, denom string) (int64, error)
 	ValidatorSet(ctx context.Context, height int64) (*ValidatorSet, error)
 	PredictProposer(ctx context.Context, valset *ValidatorSet, height int64) (*Validator, error)
-	GetPayment(ctx context.Context, msg Message, denom string) (src, dst string, amount int64, err error)
+	GetPayments(ctx context.Context, msg Message, denoms []string) ([]Payment, error) // ErrNoPayment if there are none
+	Block(ctx context.Context, height int64) (*Block, error)
 }
 
 type Transaction interface {
@@ -50,3 +54,18 @@ type Validator struct {
 	VotingPower      int64
 	ProposerPriority int64 // <-- at the original height
 }
+
+// Payment is an amount of some denom sent from one address to another.
+type Payment struct {
+	From   string
+	To     string
+	Denom  string
+	Amount int64
+}
+
+// Block is a committed block, as seen by the chain.
+type Block struct {
+	Height          int64
+	ProposerAddress string
+	Txs             [][]byte
+}
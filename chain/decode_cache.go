@@ -0,0 +1,120 @@
+package chain
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"zenith/metrics"
+)
+
+// DecodeCachedChain memoizes DecodeTransaction by tx hash, because a build
+// decodes the same txs many times, e.g. to evaluate bids, and again to get the
+// size and gas of each tx it selects.
+//
+// Decoded txs are shared by every caller, so they must not be modified.
+type DecodeCachedChain struct {
+	Chain
+
+	cache *lruCache[[sha256.Size]byte, Transaction]
+}
+
+// WithDecodeCache wraps the chain so that it keeps the most recently decoded
+// txs, up to capacity.
+func WithDecodeCache(chain Chain, capacity int) Chain {
+	return &DecodeCachedChain{
+		Chain: chain,
+
+		cache: newLRUCache[[sha256.Size]byte, Transaction](capacity),
+	}
+}
+
+// DecodeTransaction only caches txs that decode, so that errors, e.g. from a
+// canceled context, aren't kept.
+func (c *DecodeCachedChain) DecodeTransaction(ctx context.Context, txb []byte) (Transaction, error) {
+	key := sha256.Sum256(txb)
+
+	if tx, ok := c.cache.Get(key); ok {
+		metrics.DecodeCacheRequestsTotal.WithLabelValues(c.Chain.ID(), "hit").Inc()
+		return tx, nil
+	}
+
+	metrics.DecodeCacheRequestsTotal.WithLabelValues(c.Chain.ID(), "miss").Inc()
+
+	tx, err := c.Chain.DecodeTransaction(ctx, txb)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Add(key, tx)
+
+	return tx, nil
+}
+
+//
+//
+//
+
+// lruCache keeps up to capacity values, and evicts the least recently used.
+type lruCache[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	index    map[K]*list.Element
+	order    *list.List // of *lruItem, most recently used first
+}
+
+type lruItem[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newLRUCache[K comparable, V any](capacity int) *lruCache[K, V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &lruCache[K, V]{
+		capacity: capacity,
+		index:    make(map[K]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *lruCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *lruCache[K, V]) Get(k K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.index[k]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	c.order.MoveToFront(e)
+	return e.Value.(*lruItem[K, V]).value, true
+}
+
+func (c *lruCache[K, V]) Add(k K, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.index[k]; ok {
+		e.Value.(*lruItem[K, V]).value = v
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.index[k] = c.order.PushFront(&lruItem[K, V]{key: k, value: v})
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.index, oldest.Value.(*lruItem[K, V]).key)
+	}
+}
@@ -0,0 +1,66 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type decodeCountingChain struct {
+	TestChain
+	decodes map[string]int
+}
+
+func (c *decodeCountingChain) DecodeTransaction(ctx context.Context, txb []byte) (Transaction, error) {
+	c.decodes[string(txb)]++
+	if string(txb) == "bad" {
+		return nil, errors.New("bad tx")
+	}
+	return c.TestChain.DecodeTransaction(ctx, txb)
+}
+
+func TestDecodeCache(t *testing.T) {
+	ctx := context.Background()
+
+	counting := &decodeCountingChain{TestChain: TestChain{ChainID: "chain-1"}, decodes: map[string]int{}}
+	c := WithDecodeCache(counting, 2)
+
+	decode := func(s string) {
+		t.Helper()
+		tx, err := c.DecodeTransaction(ctx, []byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := tx.(*TestTransaction).s; have != s {
+			t.Fatalf("decoded %q, want %q", have, s)
+		}
+	}
+
+	decode("a")
+	decode("a")
+	decode("b")
+	decode("a") // a is the most recently used
+	decode("c") // evicts b
+	decode("a")
+	decode("b")
+
+	for tx, want := range map[string]int{"a": 1, "b": 2, "c": 1} {
+		if have := counting.decodes[tx]; want != have {
+			t.Errorf("%s: decoded %d times, want %d", tx, have, want)
+		}
+	}
+
+	// Errors aren't cached.
+	for i := 0; i < 2; i++ {
+		if _, err := c.DecodeTransaction(ctx, []byte("bad")); err == nil {
+			t.Fatalf("want error")
+		}
+	}
+	if want, have := 2, counting.decodes["bad"]; want != have {
+		t.Errorf("bad: decoded %d times, want %d", have, want)
+	}
+
+	if want, have := 2, c.(*DecodeCachedChain).cache.Len(); want != have {
+		t.Errorf("cache len %d, want %d", have, want)
+	}
+}
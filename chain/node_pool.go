@@ -0,0 +1,468 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mekapi/trc/eztrc"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"zenith/metrics"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrNodeStalled is returned by node requests that find the node is behind the
+// chain, e.g. catching up, or not seeing new blocks. It opens the circuit of the
+// node immediately, because its answers are stale.
+var ErrNodeStalled = errors.New("node stalled")
+
+const (
+	nodeFailureThreshold = 3                // consecutive failures that open a circuit
+	nodeMinCooldown      = 5 * time.Second  // before the first probe of an open circuit
+	nodeMaxCooldown      = 2 * time.Minute  // between probes of a node that stays down
+	nodeProbeTimeout     = 10 * time.Second // of each probe
+	nodeLatencyWeight    = 0.2              // of each request in the moving average
+)
+
+// NodeHealth tracks the latency, errors, and stall status of the full nodes of
+// every chain. Chains are re-created whenever services are refreshed, so it's
+// shared between them, and keyed by chain ID and node address.
+type NodeHealth struct {
+	now func() time.Time
+
+	mtx    sync.Mutex
+	chains map[string][]*nodeState // chain ID to nodes, in configured order
+}
+
+func NewNodeHealth() *NodeHealth {
+	return &NodeHealth{
+		now:    time.Now,
+		chains: map[string][]*nodeState{},
+	}
+}
+
+// ProbeFunc checks if the node at index i of a pool is healthy again.
+type ProbeFunc func(ctx context.Context, i int) error
+
+// Pool returns a pool of the nodes of the chain, which keeps the health of any
+// nodes that were in a previous pool for the chain. The probe is used to check
+// nodes with an open circuit, and can be nil, in which case they're only
+// checked by requests that have no healthy node left to try.
+func (h *NodeHealth) Pool(chainID string, addrs []string, probe ProbeFunc) *NodePool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	prev := map[string]*nodeState{}
+	for _, n := range h.chains[chainID] {
+		prev[n.addr] = n
+	}
+
+	nodes := make([]*nodeState, len(addrs))
+	for i, addr := range addrs {
+		n, ok := prev[addr]
+		if !ok {
+			n = &nodeState{chainID: chainID, addr: addr, label: RedactNodeAddr(addr)}
+		}
+		delete(prev, addr)
+		nodes[i] = n
+	}
+
+	for _, n := range prev {
+		metrics.NodeCircuitOpen.DeleteLabelValues(n.chainID, n.label)
+	}
+
+	h.chains[chainID] = nodes
+
+	return &NodePool{health: h, chainID: chainID, nodes: nodes, probe: probe}
+}
+
+// ServeHTTP lists the health of every node, for the debug server.
+func (h *NodeHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mtx.Lock()
+	chainIDs := make([]string, 0, len(h.chains))
+	for chainID := range h.chains {
+		chainIDs = append(chainIDs, chainID)
+	}
+	sort.Strings(chainIDs)
+	var nodes []*nodeState
+	for _, chainID := range chainIDs {
+		nodes = append(nodes, h.chains[chainID]...)
+	}
+	h.mtx.Unlock()
+
+	now := h.now()
+	since := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return now.Sub(t).Truncate(time.Second).String() + " ago"
+	}
+
+	w.Header().Set("content-type", "text/plain; charset=utf-8")
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "CHAIN\tNODE\tSTATE\tLATENCY\tREQUESTS\tFAILURES\tLAST OK\tLAST ERROR\n")
+	for _, n := range nodes {
+		n.mtx.Lock()
+		lastErr := "-"
+		if n.lastErr != "" {
+			lastErr = fmt.Sprintf("%s: %s", since(n.lastErrAt), n.lastErr)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", n.chainID, n.label, n.state(), n.latency.Truncate(time.Millisecond), n.requests, n.failures, since(n.lastOKAt), lastErr)
+		n.mtx.Unlock()
+	}
+	tw.Flush()
+}
+
+//
+//
+//
+
+// NodePool makes requests to the nodes of a chain, healthiest first. A node
+// that fails too many requests in a row, or stalls, has its circuit opened,
+// and isn't used until a probe finds it healthy again.
+type NodePool struct {
+	health  *NodeHealth
+	chainID string
+	nodes   []*nodeState
+	probe   ProbeFunc
+}
+
+// Do calls f with the index of each node in order of health, until one call
+// succeeds. It returns the errors of every call if none do.
+func (p *NodePool) Do(ctx context.Context, f func(i int) error) error {
+	merr := newNodeErrors()
+
+	if len(p.nodes) == 0 {
+		return fmt.Errorf("no nodes")
+	}
+
+	for _, i := range p.order() {
+		n := p.nodes[i]
+
+		begin := p.health.now()
+		err := f(i)
+		took := p.health.now().Sub(begin)
+
+		if err != nil && ctx.Err() != nil {
+			return err // not the fault of the node
+		}
+
+		n.observe(p.health.now(), took, err)
+
+		if err == nil {
+			return nil
+		}
+
+		eztrc.Tracef(ctx, "node %s: %v (took %s)", n.label, err, took)
+		merr = multierror.Append(merr, err)
+	}
+
+	return merr.ErrorOrNil()
+}
+
+// DoHedged is like Do, except that if a call hasn't returned after the delay,
+// it calls f with the next node as well, without waiting. The first call to
+// succeed wins, and the context of the others is canceled. So f may be called
+// concurrently, and must only be used for reads. A delay of zero is the same
+// as Do.
+func (p *NodePool) DoHedged(ctx context.Context, delay time.Duration, f func(ctx context.Context, i int) error) error {
+	if delay <= 0 {
+		return p.Do(ctx, func(i int) error { return f(ctx, i) })
+	}
+
+	if len(p.nodes) == 0 {
+		return fmt.Errorf("no nodes")
+	}
+
+	merr := newNodeErrors()
+
+	type result struct {
+		i   int
+		err error
+	}
+
+	var (
+		order   = p.order()
+		results = make(chan result, len(order)) // so losers never block
+		began   = map[int]time.Time{}           // of pending calls
+		started = 0
+		timer   = time.NewTimer(delay)
+	)
+	defer timer.Stop()
+
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	next := func() {
+		i := order[started]
+		started++
+		began[i] = p.health.now()
+		go func() { results <- result{i, f(hedgeCtx, i)} }()
+	}
+
+	next()
+	for len(began) > 0 {
+		select {
+		case r := <-results:
+			n, took := p.nodes[r.i], p.health.now().Sub(began[r.i])
+			delete(began, r.i)
+
+			if r.err != nil && ctx.Err() != nil {
+				return r.err // not the fault of the node
+			}
+
+			n.observe(p.health.now(), took, r.err)
+
+			if r.err == nil {
+				// The others were slower than this, at least.
+				for i, t := range began {
+					p.nodes[i].observeLatency(p.health.now().Sub(t))
+				}
+				return nil
+			}
+
+			eztrc.Tracef(ctx, "node %s: %v (took %s)", n.label, r.err, took)
+			merr = multierror.Append(merr, r.err)
+
+			if started < len(order) {
+				next() // don't wait for the delay
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(delay)
+			}
+
+		case <-timer.C:
+			if started < len(order) {
+				eztrc.Tracef(ctx, "no answer after %s, hedging", delay)
+				metrics.NodeHedgesTotal.WithLabelValues(p.chainID).Inc()
+				next()
+				timer.Reset(delay)
+			}
+		}
+	}
+
+	return merr.ErrorOrNil()
+}
+
+func newNodeErrors() *multierror.Error {
+	return &multierror.Error{ErrorFormat: func(errs []error) string {
+		strs := make([]string, len(errs))
+		for i := range errs {
+			strs[i] = errs[i].Error()
+		}
+		return strings.Join(strs, "; ")
+	}}
+}
+
+// order returns the indexes of the nodes to try. Closed circuits come first,
+// by consecutive failures and then latency, and then half-open circuits whose
+// cooldown is over. Other open circuits are only tried if nothing else is
+// left. It also starts probes of half-open circuits.
+func (p *NodePool) order() []int {
+	type candidate struct {
+		i           int
+		consecutive int
+		latency     time.Duration
+		openUntil   time.Time
+	}
+
+	var (
+		now                    = p.health.now()
+		closed, halfOpen, open []candidate
+	)
+	for i, n := range p.nodes {
+		n.mtx.Lock()
+		c := candidate{i: i, consecutive: n.consecutive, latency: n.latency, openUntil: n.openUntil}
+		switch {
+		case n.openUntil.IsZero():
+			closed = append(closed, c)
+		case !now.Before(n.openUntil):
+			halfOpen = append(halfOpen, c)
+			if p.probe != nil && !n.probing {
+				n.probing = true
+				go p.probeNode(i)
+			}
+		default:
+			open = append(open, c)
+		}
+		n.mtx.Unlock()
+	}
+
+	sort.SliceStable(closed, func(i, j int) bool {
+		if closed[i].consecutive != closed[j].consecutive {
+			return closed[i].consecutive < closed[j].consecutive
+		}
+		return closed[i].latency < closed[j].latency
+	})
+
+	byOpenUntil := func(cs []candidate) func(i, j int) bool {
+		return func(i, j int) bool { return cs[i].openUntil.Before(cs[j].openUntil) }
+	}
+	sort.SliceStable(halfOpen, byOpenUntil(halfOpen))
+	sort.SliceStable(open, byOpenUntil(open))
+
+	candidates := append(closed, halfOpen...)
+	if len(candidates) == 0 {
+		candidates = open // better than nothing
+	}
+
+	order := make([]int, len(candidates))
+	for i, c := range candidates {
+		order[i] = c.i
+	}
+	return order
+}
+
+func (p *NodePool) probeNode(i int) {
+	n := p.nodes[i]
+
+	ctx, cancel := context.WithTimeout(context.Background(), nodeProbeTimeout)
+	defer cancel()
+
+	err := p.probe(ctx, i)
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.probing = false
+
+	if err != nil {
+		metrics.NodeProbesTotal.WithLabelValues(n.chainID, n.label, "error").Inc()
+		n.lastErr, n.lastErrAt = err.Error(), p.health.now()
+		n.stalled = errors.Is(err, ErrNodeStalled)
+		n.open(p.health.now())
+		return
+	}
+
+	metrics.NodeProbesTotal.WithLabelValues(n.chainID, n.label, "ok").Inc()
+	n.close()
+}
+
+//
+//
+//
+
+type nodeState struct {
+	chainID string
+	addr    string
+	label   string // addr without credentials
+
+	mtx         sync.Mutex
+	latency     time.Duration // moving average
+	requests    uint64
+	failures    uint64
+	consecutive int // failures
+	lastOKAt    time.Time
+	lastErr     string
+	lastErrAt   time.Time
+	stalled     bool
+	opens       int       // consecutive, for backoff
+	openUntil   time.Time // zero if the circuit is closed
+	probing     bool
+}
+
+func (n *nodeState) observe(now time.Time, took time.Duration, err error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	metrics.NodeRequestSeconds.WithLabelValues(n.chainID, n.label).Observe(took.Seconds())
+
+	n.requests++
+	n.updateLatency(took)
+
+	if err == nil {
+		metrics.NodeRequestsTotal.WithLabelValues(n.chainID, n.label, "ok").Inc()
+		n.lastOKAt = now
+		n.close()
+		return
+	}
+
+	n.failures++
+	n.consecutive++
+	n.lastErr, n.lastErrAt = err.Error(), now
+	n.stalled = errors.Is(err, ErrNodeStalled)
+
+	result := "error"
+	if n.stalled {
+		result = "stalled"
+	}
+	metrics.NodeRequestsTotal.WithLabelValues(n.chainID, n.label, result).Inc()
+
+	if n.stalled || n.consecutive >= nodeFailureThreshold {
+		n.open(now)
+	}
+}
+
+// observeLatency records a lower bound on the latency of a request that was
+// canceled, because another node answered first.
+func (n *nodeState) observeLatency(took time.Duration) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if took > n.latency {
+		n.updateLatency(took)
+	}
+}
+
+func (n *nodeState) updateLatency(took time.Duration) {
+	if n.latency == 0 {
+		n.latency = took
+	} else {
+		n.latency += time.Duration(nodeLatencyWeight * float64(took-n.latency))
+	}
+}
+
+// open opens the circuit, or keeps it open, for a cooldown that doubles each
+// time in a row that it's opened.
+func (n *nodeState) open(now time.Time) {
+	cooldown := nodeMaxCooldown
+	if n.opens < 16 {
+		if d := nodeMinCooldown << n.opens; d < cooldown {
+			cooldown = d
+		}
+	}
+	n.opens++
+	n.openUntil = now.Add(cooldown)
+	metrics.NodeCircuitOpen.WithLabelValues(n.chainID, n.label).Set(1)
+}
+
+func (n *nodeState) close() {
+	n.consecutive = 0
+	n.stalled = false
+	n.opens = 0
+	n.openUntil = time.Time{}
+	metrics.NodeCircuitOpen.WithLabelValues(n.chainID, n.label).Set(0)
+}
+
+func (n *nodeState) state() string {
+	switch {
+	case n.openUntil.IsZero() && n.consecutive > 0:
+		return "degraded"
+	case n.openUntil.IsZero():
+		return "ok"
+	case n.probing:
+		return "probing"
+	case n.stalled:
+		return "stalled"
+	default:
+		return "open"
+	}
+}
+
+// RedactNodeAddr removes any credentials from the addr, so it can be shown.
+func RedactNodeAddr(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return addr
+	}
+	return u.Redacted()
+}
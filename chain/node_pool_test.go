@@ -0,0 +1,247 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNodePool(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		mtx  sync.Mutex
+		now  = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		tick = func(d time.Duration) { mtx.Lock(); now = now.Add(d); mtx.Unlock() }
+	)
+	health := NewNodeHealth()
+	health.now = func() time.Time { mtx.Lock(); defer mtx.Unlock(); return now }
+
+	var (
+		addrs   = []string{"http://a:26657", "http://user:secret@b:26657", "http://c:26657"}
+		down    = map[int]error{}
+		latency = map[int]time.Duration{0: 300 * time.Millisecond, 1: 100 * time.Millisecond, 2: 200 * time.Millisecond}
+		probes  = make(chan int, 10)
+		probeOK = map[int]bool{}
+	)
+	probe := func(ctx context.Context, i int) error {
+		mtx.Lock()
+		ok := probeOK[i]
+		mtx.Unlock()
+		defer func() { probes <- i }()
+		if !ok {
+			return fmt.Errorf("still down")
+		}
+		return nil
+	}
+	pool := health.Pool("chain-1", addrs, probe)
+
+	// attempts returns the nodes that f was called with, until one succeeded.
+	attempts := func() ([]int, error) {
+		var tried []int
+		err := pool.Do(ctx, func(i int) error {
+			tried = append(tried, i)
+			tick(latency[i])
+			mtx.Lock()
+			defer mtx.Unlock()
+			return down[i]
+		})
+		return tried, err
+	}
+
+	check := func(want ...int) {
+		t.Helper()
+		tried, _ := attempts()
+		if fmt.Sprint(tried) != fmt.Sprint(want) {
+			t.Fatalf("tried %v, want %v", tried, want)
+		}
+	}
+
+	check(0) // configured order, until latency is known
+	check(1)
+	check(2)
+	check(1) // fastest
+
+	mtx.Lock()
+	down[1] = fmt.Errorf("connection refused")
+	mtx.Unlock()
+
+	check(1, 2) // failed once, so it's tried after the others
+	check(2)
+
+	// Circuit opens after consecutive failures.
+	for i := 0; i < nodeFailureThreshold; i++ {
+		if err := pool.Do(ctx, func(i int) error { return fmt.Errorf("boom") }); err == nil {
+			t.Fatalf("want error")
+		}
+	}
+	if n := pool.nodes[0]; n.openUntil.IsZero() {
+		t.Fatalf("want node 0 open after %d failures", nodeFailureThreshold)
+	}
+
+	// A stall opens the circuit immediately.
+	if err := pool.Do(ctx, func(i int) error {
+		return fmt.Errorf("%w: last block 10m ago", ErrNodeStalled)
+	}); !errors.Is(err, ErrNodeStalled) {
+		t.Fatalf("want ErrNodeStalled, have %v", err)
+	}
+
+	// Every node is open now, so they're tried anyway.
+	for i, n := range pool.nodes {
+		if n.openUntil.IsZero() {
+			t.Fatalf("node %d: want open circuit", i)
+		}
+	}
+	mtx.Lock()
+	for i := range addrs {
+		down[i] = fmt.Errorf("connection refused")
+	}
+	mtx.Unlock()
+	if tried, _ := attempts(); len(tried) != len(addrs) {
+		t.Fatalf("tried %v, want all nodes as a last resort", tried)
+	}
+
+	// After the cooldown, probes check the nodes. Only node 2 recovers.
+	mtx.Lock()
+	delete(down, 2)
+	probeOK[2] = true
+	mtx.Unlock()
+
+	tick(nodeMaxCooldown)
+	pool.order()
+	for i := 0; i < len(addrs); i++ {
+		select {
+		case <-probes:
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for probes")
+		}
+	}
+	waitFor(t, func() bool {
+		for _, n := range pool.nodes {
+			n.mtx.Lock()
+			probing := n.probing
+			n.mtx.Unlock()
+			if probing {
+				return false
+			}
+		}
+		return true
+	})
+
+	check(2)
+
+	// Health survives re-creating the pool, e.g. on refresh. Dropped nodes are
+	// dropped from the health.
+	pool = health.Pool("chain-1", addrs[1:], probe)
+	if n := pool.nodes[1]; !n.openUntil.IsZero() || n.requests == 0 {
+		t.Fatalf("node c: want closed circuit with requests, have %+v", n)
+	}
+	if want, have := 2, len(health.chains["chain-1"]); want != have {
+		t.Fatalf("want %d nodes, have %d", want, have)
+	}
+
+	// Canceled requests aren't the fault of the node.
+	{
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		before := pool.nodes[1].failures
+		pool.Do(ctx, func(i int) error { return ctx.Err() })
+		if after := pool.nodes[1].failures; after != before {
+			t.Fatalf("canceled request counted as a failure")
+		}
+	}
+
+	// The debug page lists nodes without credentials.
+	rec := httptest.NewRecorder()
+	health.ServeHTTP(rec, httptest.NewRequest("GET", "/nodes", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"chain-1", "http://user:xxxxx@b:26657", "http://c:26657", "ok"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("debug page missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "secret") {
+		t.Errorf("debug page shows credentials:\n%s", body)
+	}
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNodePoolHedged(t *testing.T) {
+	ctx := context.Background()
+
+	pool := NewNodeHealth().Pool("chain-1", []string{"http://a:26657", "http://b:26657", "http://c:26657"}, nil)
+
+	// A slow first node is hedged after the delay, and canceled when the next
+	// node answers.
+	{
+		canceled := make(chan struct{})
+		err := pool.DoHedged(ctx, 10*time.Millisecond, func(ctx context.Context, i int) error {
+			switch i {
+			case 0:
+				<-ctx.Done()
+				close(canceled)
+				return ctx.Err()
+			default:
+				return nil
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatalf("slow node wasn't canceled")
+		}
+
+		if n := pool.nodes[0]; n.latency < 10*time.Millisecond || n.failures != 0 {
+			t.Fatalf("slow node: want latency of at least the delay and no failures, have %s and %d", n.latency, n.failures)
+		}
+	}
+
+	// The slow node is last now. A failure moves on to the next node without
+	// waiting for the delay.
+	{
+		var (
+			mtx   sync.Mutex
+			tried []int
+			begin = time.Now()
+		)
+		err := pool.DoHedged(ctx, time.Hour, func(ctx context.Context, i int) error {
+			mtx.Lock()
+			tried = append(tried, i)
+			mtx.Unlock()
+			return fmt.Errorf("node %d failed", i)
+		})
+		if err == nil {
+			t.Fatalf("want error")
+		}
+		if time.Since(begin) > time.Second {
+			t.Fatalf("waited for the delay after a failure")
+		}
+		if len(tried) != 3 || tried[2] != 0 {
+			t.Fatalf("tried %v, want every node, slow node last", tried)
+		}
+		for _, want := range []string{"node 0 failed", "node 1 failed", "node 2 failed"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q missing %q", err, want)
+			}
+		}
+	}
+}
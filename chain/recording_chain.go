@@ -0,0 +1,159 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"zenith/cryptoutil"
+)
+
+// Recording is the chain state read while serving a single request, in a form
+// that can be encoded, and served again by a RecordedChain.
+type Recording struct {
+	mtx sync.Mutex
+
+	LatestHeight int64                       `json:"latest_height"`
+	Validators   *ValidatorSet               `json:"validators,omitempty"`
+	Proposer     *Validator                  `json:"proposer,omitempty"`
+	Balances     map[string]map[string]int64 `json:"balances,omitempty"`    // by addr, then denom
+	InvalidTxs   []string                    `json:"invalid_txs,omitempty"` // by hash, rejected by CheckTransaction
+}
+
+type recordingKey struct{}
+
+// NewRecordingContext returns a context that makes a RecordingChain record
+// the chain state it reads into the returned recording.
+func NewRecordingContext(ctx context.Context) (context.Context, *Recording) {
+	rec := &Recording{}
+	return context.WithValue(ctx, recordingKey{}, rec), rec
+}
+
+func recordingFromContext(ctx context.Context) *Recording {
+	rec, _ := ctx.Value(recordingKey{}).(*Recording)
+	return rec
+}
+
+// Chain returns a RecordedChain that serves the recorded state, and uses the
+// codec, if given, to decode txs and find their payments.
+func (r *Recording) Chain(chainID string, codec Chain) *RecordedChain {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	c := &RecordedChain{
+		ChainID:    chainID,
+		Codec:      codec,
+		Height:     r.LatestHeight,
+		Balances:   make(map[string]map[string]int64, len(r.Balances)),
+		InvalidTxs: make(map[string]bool, len(r.InvalidTxs)),
+	}
+	if r.Validators != nil {
+		c.Validators = *r.Validators
+	}
+	if r.Proposer != nil {
+		c.Proposer = *r.Proposer
+	}
+	for addr, denoms := range r.Balances {
+		c.Balances[addr] = make(map[string]int64, len(denoms))
+		for denom, balance := range denoms {
+			c.Balances[addr][denom] = balance
+		}
+	}
+	for _, hash := range r.InvalidTxs {
+		c.InvalidTxs[hash] = true
+	}
+	return c
+}
+
+func (r *Recording) setLatestHeight(height int64) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.LatestHeight = height
+}
+
+func (r *Recording) setValidators(vs *ValidatorSet) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.Validators = vs
+}
+
+func (r *Recording) setProposer(p *Validator) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.Proposer = p
+}
+
+func (r *Recording) setBalance(addr, denom string, balance int64) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.Balances == nil {
+		r.Balances = map[string]map[string]int64{}
+	}
+	if r.Balances[addr] == nil {
+		r.Balances[addr] = map[string]int64{}
+	}
+	r.Balances[addr][denom] = balance
+}
+
+func (r *Recording) addInvalidTx(hash string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, h := range r.InvalidTxs {
+		if h == hash {
+			return
+		}
+	}
+	r.InvalidTxs = append(r.InvalidTxs, hash)
+}
+
+// RecordingChain records the state read from the wrapped chain into the
+// recording in the context, if there is one.
+type RecordingChain struct {
+	Chain
+}
+
+// WithRecording wraps the chain so that requests with a recording context
+// record the chain state they read.
+func WithRecording(chain Chain) Chain {
+	return &RecordingChain{Chain: chain}
+}
+
+func (c *RecordingChain) LatestHeight(ctx context.Context) (int64, error) {
+	height, err := c.Chain.LatestHeight(ctx)
+	if rec := recordingFromContext(ctx); rec != nil && err == nil {
+		rec.setLatestHeight(height)
+	}
+	return height, err
+}
+
+func (c *RecordingChain) ValidatorSet(ctx context.Context, height int64) (*ValidatorSet, error) {
+	vs, err := c.Chain.ValidatorSet(ctx, height)
+	if rec := recordingFromContext(ctx); rec != nil && err == nil {
+		rec.setValidators(vs)
+	}
+	return vs, err
+}
+
+func (c *RecordingChain) PredictProposer(ctx context.Context, valset *ValidatorSet, height int64) (*Validator, error) {
+	p, err := c.Chain.PredictProposer(ctx, valset, height)
+	if rec := recordingFromContext(ctx); rec != nil && err == nil {
+		rec.setProposer(p)
+	}
+	return p, err
+}
+
+func (c *RecordingChain) AccountBalance(ctx context.Context, height int64, addr, denom string) (int64, error) {
+	balance, err := c.Chain.AccountBalance(ctx, height, addr, denom)
+	if rec := recordingFromContext(ctx); rec != nil && err == nil {
+		rec.setBalance(addr, denom, balance)
+	}
+	return balance, err
+}
+
+func (c *RecordingChain) CheckTransaction(ctx context.Context, txb []byte) error {
+	err := c.Chain.CheckTransaction(ctx, txb)
+	if rec := recordingFromContext(ctx); rec != nil && errors.Is(err, ErrInvalidTx) {
+		rec.addInvalidTx(cryptoutil.HashTx(txb))
+	}
+	return err
+}
@@ -2,6 +2,8 @@ package chain
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
 )
 
 type TestChain struct {
@@ -9,6 +11,9 @@ type TestChain struct {
 	Height            int64
 	Validators        ValidatorSet
 	PredictedProposer Validator
+	Blocks            map[int64]*Block
+	InvalidTxs        map[string]bool
+	Payments          map[string][]Payment // by tx, which is also its only message
 }
 
 var _ Chain = (*TestChain)(nil)
@@ -21,6 +26,11 @@ func (c *TestChain) VerifySignature(ctx context.Context, pubKeyType string, pubK
 	return nil
 }
 
+// AccountAddress returns the hex-encoded pub key bytes.
+func (c *TestChain) AccountAddress(ctx context.Context, pubKeyType string, pubKeyBytes []byte) (string, error) {
+	return hex.EncodeToString(pubKeyBytes), nil
+}
+
 func (c *TestChain) ValidatePaymentAddress(ctx context.Context, addr string) error {
 	return nil
 }
@@ -33,6 +43,13 @@ func (c *TestChain) EncodeTransaction(ctx context.Context, tx Transaction) ([]by
 	return []byte(tx.(*TestTransaction).s), nil
 }
 
+func (c *TestChain) CheckTransaction(ctx context.Context, txb []byte) error {
+	if c.InvalidTxs[string(txb)] {
+		return ErrInvalidTx
+	}
+	return nil
+}
+
 func (c *TestChain) AccountBalance(ctx context.Context, height int64, addr, denom string) (int64, error) {
 	return 100, nil
 }
@@ -49,8 +66,31 @@ func (c *TestChain) PredictProposer(ctx context.Context, valset *ValidatorSet, h
 	return &c.PredictedProposer, nil
 }
 
-func (c *TestChain) GetPayment(ctx context.Context, msg Message, denom string) (src, dst string, amount int64, err error) {
-	return "", "", 0, ErrNoPayment
+func (c *TestChain) GetPayments(ctx context.Context, msg Message, denoms []string) ([]Payment, error) {
+	s, _ := msg.(string)
+
+	var payments []Payment
+	for _, p := range c.Payments[s] {
+		for _, denom := range denoms {
+			if p.Denom == denom {
+				payments = append(payments, p)
+			}
+		}
+	}
+
+	if len(payments) <= 0 {
+		return nil, ErrNoPayment
+	}
+
+	return payments, nil
+}
+
+func (c *TestChain) Block(ctx context.Context, height int64) (*Block, error) {
+	b, ok := c.Blocks[height]
+	if !ok {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	return b, nil
 }
 
 type TestTransaction struct {
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"zenith/store"
+	"zenith/store/pgstore"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// This program makes common changes to the store, like adding a chain or
+// resetting a localnet, so that operators don't have to write SQL by hand.
+// Chains are validated before they're saved.
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case err == nil:
+		os.Exit(0)
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	default:
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+const usage = `USAGE
+  zenith-admin [flags] <command> [args]
+
+COMMANDS
+  chains list
+  chains add [-network N -payment-denom D -mekatek-payment-address A ...] <chain>
+  chains set-nodes <chain> <uri> [<uri>...]
+  chains set-timeout <chain> <duration>
+  chains set-hedge-delay <chain> <duration|none>
+  chains set-retention <chain> <duration|none>
+  validators list <chain>
+  validators remove <chain> <address>
+  auctions show <chain> <height>
+  localnet reset [-force] <chain>
+
+FLAGS
+`
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("zenith-admin", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		storeConnStr = fs.String("store-conn-str", os.Getenv("ZENITH_STORE_CONN_STR"), "Postgres store connection string (or ZENITH_STORE_CONN_STR)")
+		logLevel     = fs.String("log-level", "warn", "debug, info, warn, error")
+	)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	cmd, ok := commands[fs.Arg(0)+" "+fs.Arg(1)]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", fs.Arg(0)+" "+fs.Arg(1))
+	}
+
+	if !strings.HasPrefix(*storeConnStr, "postgres") {
+		return fmt.Errorf("-store-conn-str must be a Postgres connection string")
+	}
+
+	var logger log.Logger
+	{
+		logger = log.NewLogfmtLogger(stderr)
+		logger = level.NewFilter(logger, level.Allow(level.ParseDefault(*logLevel, level.WarnValue())))
+	}
+
+	st, err := pgstore.NewStore(ctx, *storeConnStr, log.With(logger, "module", "store"))
+	if err != nil {
+		return fmt.Errorf("create Postgres store: %w", err)
+	}
+	defer st.Close()
+
+	return cmd(ctx, st, fs.Args()[2:], stdout, stderr)
+}
+
+type command func(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error
+
+var commands = map[string]command{
+	"chains list":            chainsList,
+	"chains add":             chainsAdd,
+	"chains set-nodes":       chainsSetNodes,
+	"chains set-timeout":     chainsSetTimeout,
+	"chains set-hedge-delay": chainsSetHedgeDelay,
+	"chains set-retention":   chainsSetRetention,
+	"validators list":        validatorsList,
+	"validators remove":      validatorsRemove,
+	"auctions show":          auctionsShow,
+	"localnet reset":         localnetReset,
+}
+
+//
+//
+//
+
+func chainsList(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: chains list")
+	}
+
+	chains, err := st.ListChains(ctx)
+	if err != nil {
+		return fmt.Errorf("list chains: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\tNETWORK\tDENOM\tTIMEOUT\tHEDGE\tRETENTION\tNODES\n")
+	for _, c := range chains {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.ID, c.Network, c.PaymentDenom, c.Timeout, durationString(c.HedgeDelay), durationString(c.RetentionTime), strings.Join(c.NodeURIs, " "))
+	}
+	return tw.Flush()
+}
+
+func chainsAdd(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("chains add", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		network             = fs.String("network", "", "network of the chain e.g. osmosis")
+		paymentDenom        = fs.String("payment-denom", "", "denom of bid payments")
+		mekatekPaymentAddr  = fs.String("mekatek-payment-address", "", "address that receives the Mekatek share of payments")
+		timeout             = fs.Duration("timeout", time.Second, "timeout of requests to the chain's nodes")
+		hedgeDelay          = fs.Duration("hedge-delay", 0, "send reads to the next node too, if the first hasn't answered after this (0 disables)")
+		retentionTime       = fs.Duration("retention", 0, "delete auctions older than this (0 keeps them)")
+		bidSelection        = fs.String("bid-selection", string(store.BidSelectionMaxRevenue), "max-revenue, greedy")
+		allocationPolicy    = fs.String("allocation-policy", string(store.AllocationPolicyFixed), "fixed, power-linear, per-validator")
+		allocation          *float64 // nil for the default
+		allocationTolerance *float64 // nil for the default
+		nodeURIs            = fs.String("nodes", "", "comma-separated node URIs")
+	)
+	fs.Var(optionalFloat{&allocation}, "allocation", "portion of payments to the validator (default of Zenith if not set)")
+	fs.Var(optionalFloat{&allocationTolerance}, "allocation-tolerance", "how far bid payments can be from the allocation (default of Zenith if not set)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: chains add [flags] <chain>")
+	}
+
+	chainID := fs.Arg(0)
+
+	switch _, err := st.SelectChain(ctx, chainID); {
+	case err == nil:
+		return fmt.Errorf("chain %s already exists", chainID)
+	case errors.Is(err, store.ErrNotFound):
+	default:
+		return fmt.Errorf("get chain: %w", err)
+	}
+
+	c := &store.Chain{
+		ID:                    chainID,
+		Network:               *network,
+		PaymentDenom:          *paymentDenom,
+		MekatekPaymentAddress: *mekatekPaymentAddr,
+		Timeout:               *timeout,
+		HedgeDelay:            *hedgeDelay,
+		NodeURIs:              splitList(*nodeURIs),
+		BidSelection:          store.ParseBidSelection(*bidSelection),
+		AllocationPolicy:      store.ParseAllocationPolicy(*allocationPolicy),
+		Allocation:            allocation,
+		AllocationTolerance:   allocationTolerance,
+		RetentionTime:         *retentionTime,
+	}
+
+	if err := saveChain(ctx, st, c); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "added chain %s\n", chainID)
+	return nil
+}
+
+func chainsSetNodes(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: chains set-nodes <chain> <uri> [<uri>...]")
+	}
+
+	return updateChain(ctx, st, args[0], stdout, func(c *store.Chain) error {
+		c.NodeURIs = args[1:]
+		return nil
+	})
+}
+
+func chainsSetTimeout(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: chains set-timeout <chain> <duration>")
+	}
+
+	return updateChain(ctx, st, args[0], stdout, func(c *store.Chain) error {
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return fmt.Errorf("parse timeout: %w", err)
+		}
+		c.Timeout = d
+		return nil
+	})
+}
+
+func chainsSetHedgeDelay(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: chains set-hedge-delay <chain> <duration|none>")
+	}
+
+	return updateChain(ctx, st, args[0], stdout, func(c *store.Chain) error {
+		if args[1] == "none" {
+			c.HedgeDelay = 0
+			return nil
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return fmt.Errorf("parse hedge delay: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("hedge delay must be positive, or none")
+		}
+		c.HedgeDelay = d
+		return nil
+	})
+}
+
+func chainsSetRetention(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: chains set-retention <chain> <duration|none>")
+	}
+
+	return updateChain(ctx, st, args[0], stdout, func(c *store.Chain) error {
+		if args[1] == "none" {
+			c.RetentionTime = 0
+			return nil
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return fmt.Errorf("parse retention time: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("retention time must be positive, or none")
+		}
+		c.RetentionTime = d
+		return nil
+	})
+}
+
+func updateChain(ctx context.Context, st store.Store, chainID string, stdout io.Writer, update func(*store.Chain) error) error {
+	c, err := st.SelectChain(ctx, chainID)
+	if err != nil {
+		return fmt.Errorf("get chain: %w", err)
+	}
+
+	if err := update(c); err != nil {
+		return err
+	}
+
+	if err := saveChain(ctx, st, c); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "updated chain %s\n", chainID)
+	return nil
+}
+
+func saveChain(ctx context.Context, st store.Store, c *store.Chain) error {
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("invalid chain: %w", err)
+	}
+
+	if err := st.UpsertChain(ctx, c); err != nil {
+		return fmt.Errorf("save chain: %w", err)
+	}
+
+	return nil
+}
+
+//
+//
+//
+
+func validatorsList(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: validators list <chain>")
+	}
+
+	validators, err := st.ListValidators(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("list validators: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ADDRESS\tMONIKER\tPAYMENT ADDRESS\tREGISTERED\n")
+	for _, v := range validators {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Address, v.Moniker, v.PaymentAddress, v.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func validatorsRemove(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: validators remove <chain> <address>")
+	}
+
+	if err := st.DeleteValidator(ctx, args[0], args[1]); err != nil {
+		return fmt.Errorf("remove validator: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "removed validator %s from %s\n", args[1], args[0])
+	return nil
+}
+
+//
+//
+//
+
+func auctionsShow(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: auctions show <chain> <height>")
+	}
+
+	height, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || height <= 0 {
+		return fmt.Errorf("invalid height %q", args[1])
+	}
+
+	a, err := st.SelectAuction(ctx, args[0], height)
+	if err != nil {
+		return fmt.Errorf("get auction: %w", err)
+	}
+
+	bids, err := st.ListBids(ctx, args[0], height)
+	if err != nil {
+		return fmt.Errorf("list bids: %w", err)
+	}
+
+	finished := "no"
+	if !a.FinishedAt.IsZero() {
+		finished = a.FinishedAt.Format(time.RFC3339)
+	}
+
+	fmt.Fprintf(stdout, "chain        %s\n", a.ChainID)
+	fmt.Fprintf(stdout, "height       %d\n", a.Height)
+	fmt.Fprintf(stdout, "validator    %s\n", a.ValidatorAddress)
+	fmt.Fprintf(stdout, "allocation   %.4f (tolerance %.4f)\n", a.ValidatorAllocation, a.AllocationTolerance)
+	fmt.Fprintf(stdout, "payment      %s to %s, %s to %s\n", a.PaymentDenom, a.ValidatorPaymentAddress, a.PaymentDenom, a.MekatekPaymentAddress)
+	fmt.Fprintf(stdout, "power        %d of %d registered\n", a.RegisteredPower, a.TotalPower)
+	fmt.Fprintf(stdout, "created      %s\n", a.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(stdout, "finished     %s\n", finished)
+	fmt.Fprintf(stdout, "\n")
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "BID\tHEIGHT\tKIND\tSTATE\tPRIORITY\tVALIDATOR\tMEKATEK\tTXS\tREASON\n")
+	for _, b := range bids {
+		bidHeight := strconv.FormatInt(b.Height, 10)
+		if b.MaxHeight > b.Height {
+			bidHeight += "-" + strconv.FormatInt(b.MaxHeight, 10)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n", b.ID, bidHeight, b.Kind, b.State, b.Priority, b.ValidatorPayment, b.MekatekPayment, len(b.Txs), b.RejectionReason)
+	}
+	return tw.Flush()
+}
+
+func localnetReset(ctx context.Context, st store.Store, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("localnet reset", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	force := fs.Bool("force", false, "reset a chain that doesn't look like a localnet")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: localnet reset [-force] <chain>")
+	}
+
+	chainID := fs.Arg(0)
+
+	c, err := st.SelectChain(ctx, chainID)
+	if err != nil {
+		return fmt.Errorf("get chain: %w", err)
+	}
+
+	if !isLocalnet(c) && !*force {
+		return fmt.Errorf("chain %s (network %s) doesn't look like a localnet, use -force to reset it anyway", chainID, c.Network)
+	}
+
+	n, err := st.DeleteAuctions(ctx, chainID, 0)
+	if err != nil {
+		return fmt.Errorf("delete auctions: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "deleted %d auctions, and their bids, from %s\n", n, chainID)
+	return nil
+}
+
+// isLocalnet guesses if the chain is a localnet from its ID or network, e.g.
+// osmosis-localnet-1 or localosmosis, since that's all the store knows.
+func isLocalnet(c *store.Chain) bool {
+	return strings.Contains(strings.ToLower(c.ID), "local") || strings.Contains(strings.ToLower(c.Network), "local")
+}
+
+//
+//
+//
+
+func durationString(d time.Duration) string {
+	if d == 0 {
+		return "none"
+	}
+	return d.String()
+}
+
+// optionalFloat is a flag that's nil unless it's set, so that the store can
+// tell an explicit value, including 0, from the default.
+type optionalFloat struct{ p **float64 }
+
+func (f optionalFloat) String() string {
+	if f.p == nil || *f.p == nil {
+		return ""
+	}
+	return strconv.FormatFloat(**f.p, 'g', -1, 64)
+}
+
+func (f optionalFloat) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*f.p = &v
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"zenith/store"
+	"zenith/store/memstore"
+	"zenith/store/storetest"
+)
+
+func TestChainsAdd(t *testing.T) {
+	var (
+		ctx = context.Background()
+		st  = memstore.NewStore()
+	)
+
+	add := func(args ...string) *store.Chain {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		args = append([]string{"-network", "osmo", "-payment-denom", "uosmo", "-mekatek-payment-address", "osmo1abc"}, args...)
+		if err := chainsAdd(ctx, st, args, &stdout, &stderr); err != nil {
+			t.Fatalf("chains add %v: %v (%s)", args, err, stderr.String())
+		}
+		c, err := st.SelectChain(ctx, args[len(args)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// Without the flags, the chain uses the defaults of Zenith.
+	if c := add("default-chain"); c.Allocation != nil || c.AllocationTolerance != nil {
+		t.Errorf("default allocation: want unset, have %v, %v", c.Allocation, c.AllocationTolerance)
+	}
+
+	c := add("-allocation", "0", "-allocation-tolerance", "0", "zero-chain")
+	if c.Allocation == nil || *c.Allocation != 0 {
+		t.Errorf("allocation: want 0, have %v", c.Allocation)
+	}
+	if c.AllocationTolerance == nil || *c.AllocationTolerance != 0 {
+		t.Errorf("allocation tolerance: want 0, have %v", c.AllocationTolerance)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := chainsAdd(ctx, st, []string{"-network", "osmo", "-payment-denom", "uosmo", "-mekatek-payment-address", "osmo1abc", "-allocation", "1.5", "bad-chain"}, &stdout, &stderr); err == nil {
+		t.Errorf("allocation out of range: want error, have none")
+	}
+	if err := chainsAdd(ctx, st, []string{"-network", "osmo", "-payment-denom", "uosmo", "-mekatek-payment-address", "osmo1abc", "zero-chain"}, &stdout, &stderr); err == nil {
+		t.Errorf("existing chain: want error, have none")
+	}
+}
+
+func TestLocalnetReset(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		st        = memstore.NewStore()
+		c         = storetest.NewChain(t, st)
+		validator = storetest.NewValidator(t, st, c)
+		auction   = storetest.NewAuction(t, st, c, 10, validator)
+	)
+
+	reset := func(args ...string) (string, error) {
+		var stdout, stderr bytes.Buffer
+		err := localnetReset(ctx, st, args, &stdout, &stderr)
+		return stdout.String(), err
+	}
+
+	if _, err := reset(c.ID); err == nil {
+		t.Fatalf("reset %s: want error, have none", c.ID)
+	}
+	if _, err := st.SelectAuction(ctx, c.ID, auction.Height); err != nil {
+		t.Fatalf("auction after refused reset: %v", err)
+	}
+
+	out, err := reset("-force", c.ID)
+	if err != nil {
+		t.Fatalf("reset -force %s: %v", c.ID, err)
+	}
+	if want, have := "deleted 1 auctions", out; !strings.HasPrefix(have, want) {
+		t.Errorf("output: want %q, have %q", want, have)
+	}
+	if _, err := st.SelectAuction(ctx, c.ID, auction.Height); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("auction after reset: want %v, have %v", store.ErrNotFound, err)
+	}
+
+	local := *c
+	local.ID = "osmosis-localnet-1"
+	if err := st.UpsertChain(ctx, &local); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reset(local.ID); err != nil {
+		t.Errorf("reset %s: %v", local.ID, err)
+	}
+
+	if _, err := reset("-force", "unknown-chain"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("reset unknown chain: want %v, have %v", store.ErrNotFound, err)
+	}
+}
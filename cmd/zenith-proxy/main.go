@@ -47,8 +47,13 @@ func exe(stdout, stderr io.Writer, args []string) error {
 		ctx                  = context.Background()
 		proxyAddr            = fs.String("proxy-addr", ":4411", "public proxy HTTP server address")
 		debugAddr            = fs.String("debug-addr", ":4412", "private debug HTTP server address")
+		debugAuthToken       = fs.String("debug-auth-token", "", "if set, debug requests can authenticate with this bearer token")
+		debugAuthUser        = fs.String("debug-auth-user", "", "if set, debug requests can authenticate with HTTP basic auth, and -debug-auth-password is required")
+		debugAuthPassword    = fs.String("debug-auth-password", "", "HTTP basic auth password for debug requests, required with -debug-auth-user")
+		debugOpenMetrics     = fs.Bool("debug-open-metrics", true, "allow /metrics without debug auth")
 		storeConnStr         = fs.String("store-conn-str", "mem://store", "store connection string")
 		networks             = repeatedString(fs, "network", "<network>:<uri> e.g. 'osmosis:localhost:4412' (repeatable)")
+		rateLimitFile        = fs.String("rate-limit-file", "", "JSON file of rate limits, by chain ID (optional)")
 		chainRefreshInterval = fs.Duration("chain-refresh-interval", 1*time.Minute, "how often to fetch chain IDs from the store")
 		version              = fs.Bool("version", false, "print version information and exit")
 		logLevel             = fs.String("log-level", "info", "debug, info, warn, error")
@@ -67,6 +72,16 @@ func exe(stdout, stderr io.Writer, args []string) error {
 		return nil
 	}
 
+	debugAuth := debug.Auth{
+		BearerToken: *debugAuthToken,
+		Username:    *debugAuthUser,
+		Password:    *debugAuthPassword,
+		OpenMetrics: *debugOpenMetrics,
+	}
+	if err := debugAuth.Validate(); err != nil {
+		return fmt.Errorf("-debug-auth-user, -debug-auth-password: %w", err)
+	}
+
 	var logger log.Logger
 	{
 		logger = log.NewLogfmtLogger(stderr)
@@ -130,9 +145,19 @@ func exe(stdout, stderr io.Writer, args []string) error {
 
 	level.Debug(logger).Log("msg", "constructing proxy handler")
 
+	var proxyOptions []api.ProxyOption
+	if *rateLimitFile != "" {
+		level.Info(logger).Log("rate_limit_file", *rateLimitFile)
+		limits, err := api.ReadRateLimitConfig(*rateLimitFile)
+		if err != nil {
+			return fmt.Errorf("read rate limits: %w", err)
+		}
+		proxyOptions = append(proxyOptions, api.WithProxyRateLimiter(api.NewRateLimiter(*limits)))
+	}
+
 	var proxyHandler http.Handler
 	{
-		p, err := api.NewProxy(manager, logger)
+		p, err := api.NewProxy(manager, logger, proxyOptions...)
 		if err != nil {
 			return fmt.Errorf("create proxy: %w", err)
 		}
@@ -158,10 +183,10 @@ func exe(stdout, stderr io.Writer, args []string) error {
 
 	{
 		logger := log.With(logger, "module", "debug")
-		debugHandler := debug.NewHandler()
+		debugHandler := debug.NewHandler(debug.WithAuth(debugAuth))
 		server := &http.Server{Handler: debugHandler, Addr: *debugAddr}
 		g.Add(func() error {
-			level.Info(logger).Log("debug_addr", *debugAddr)
+			level.Info(logger).Log("debug_addr", *debugAddr, "debug_auth", debugAuth.Enabled())
 			return server.ListenAndServe()
 		}, func(error) {
 			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"zenith/store"
+
+	"github.com/gofrs/uuid"
+)
+
+// exportSource serves auctions from a JSONL export of the store, as written
+// by writeExport, so that they can be replayed without access to the store.
+type exportSource struct {
+	chains   map[string]*store.Chain
+	auctions map[exportKey]*store.Auction
+	builds   map[exportKey]*store.Build
+	bids     []*store.Bid
+}
+
+type exportKey struct {
+	chainID string
+	height  int64
+}
+
+var _ auctionSource = (*exportSource)(nil)
+
+type exportRecord struct {
+	Chain   *store.Chain   `json:"chain,omitempty"`
+	Auction *store.Auction `json:"auction,omitempty"`
+	Bid     *store.Bid     `json:"bid,omitempty"`
+	Build   *store.Build   `json:"build,omitempty"`
+}
+
+// writeExport writes the auction at height to w, along with its chain, the
+// bids that were eligible for it, and its recorded build, if there is one.
+// Exports of several auctions can be concatenated.
+func writeExport(ctx context.Context, src auctionSource, chainID string, height int64, w io.Writer) error {
+	ch, err := src.SelectChain(ctx, chainID)
+	if err != nil {
+		return fmt.Errorf("get chain: %w", err)
+	}
+
+	auction, err := src.SelectAuction(ctx, chainID, height)
+	if err != nil {
+		return fmt.Errorf("get auction: %w", err)
+	}
+
+	bids, err := src.ListEligibleBids(ctx, chainID, height)
+	if err != nil {
+		return fmt.Errorf("get bids: %w", err)
+	}
+
+	build, err := src.SelectBuild(ctx, chainID, height)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("get recorded build: %w", err)
+	}
+
+	records := []exportRecord{{Chain: ch}, {Auction: auction}}
+	for _, b := range bids {
+		records = append(records, exportRecord{Bid: b})
+	}
+	if build != nil {
+		records = append(records, exportRecord{Build: build})
+	}
+
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("write export: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func loadExport(filename string) (*exportSource, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	src := &exportSource{
+		chains:   map[string]*store.Chain{},
+		auctions: map[exportKey]*store.Auction{},
+		builds:   map[exportKey]*store.Build{},
+	}
+
+	bidIndex := map[uuid.UUID]int{}
+
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 0, 64*1024), 64*1024*1024) // bids can be big
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+
+		var r exportRecord
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch {
+		case r.Chain != nil:
+			src.chains[r.Chain.ID] = r.Chain
+		case r.Auction != nil:
+			src.auctions[exportKey{r.Auction.ChainID, r.Auction.Height}] = r.Auction
+		case r.Bid != nil:
+			if i, ok := bidIndex[r.Bid.ID]; ok {
+				src.bids[i] = r.Bid // exported along with several auctions
+				continue
+			}
+			bidIndex[r.Bid.ID] = len(src.bids)
+			src.bids = append(src.bids, r.Bid)
+		case r.Build != nil:
+			src.builds[exportKey{r.Build.ChainID, r.Build.Height}] = r.Build
+		default:
+			return nil, fmt.Errorf("line %d: no chain, auction, bid, or build", line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(src.bids, func(i, j int) bool { return src.bids[i].CreatedAt.Before(src.bids[j].CreatedAt) })
+
+	return src, nil
+}
+
+func (s *exportSource) SelectChain(ctx context.Context, id string) (*store.Chain, error) {
+	c, ok := s.chains[id]
+	if !ok {
+		return nil, fmt.Errorf("chain %s: %w", id, store.ErrNotFound)
+	}
+	return c, nil
+}
+
+func (s *exportSource) SelectAuction(ctx context.Context, chainID string, height int64) (*store.Auction, error) {
+	a, ok := s.auctions[exportKey{chainID, height}]
+	if !ok {
+		return nil, fmt.Errorf("auction %s/%d: %w", chainID, height, store.ErrNotFound)
+	}
+	return a, nil
+}
+
+// ListEligibleBids has the same semantics as store.Store.
+func (s *exportSource) ListEligibleBids(ctx context.Context, chainID string, height int64) ([]*store.Bid, error) {
+	var bids []*store.Bid
+	for _, b := range s.bids {
+		if b.ChainID == chainID && b.EligibleFor(height) {
+			bids = append(bids, b)
+		}
+	}
+	return bids, nil
+}
+
+func (s *exportSource) SelectBuild(ctx context.Context, chainID string, height int64) (*store.Build, error) {
+	b, ok := s.builds[exportKey{chainID, height}]
+	if !ok {
+		return nil, fmt.Errorf("build %s/%d: %w", chainID, height, store.ErrNotFound)
+	}
+	return b, nil
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"zenith/block"
+	"zenith/chain"
+	"zenith/store"
+	"zenith/store/pgstore"
+
+	"github.com/go-kit/log"
+)
+
+// This program runs the auction for a historical height again, from its stored
+// auction and bids, and a capture of the mempool and chain state the block was
+// built from. It prints the resulting block and payments, and can compare them
+// with the build that was recorded at the time.
+//
+// The auction can be read from the store, or from an export of it, which this
+// program also writes, so that auctions can be replayed away from the store.
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case err == nil:
+		os.Exit(0)
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case errors.Is(err, errDiffers):
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+var errDiffers = errors.New("replayed build differs from recorded build")
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("zenith-replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		storeConnStr = fs.String("store-conn-str", "", "Postgres connection string to load the auction from")
+		exportFile   = fs.String("export", "", "JSONL export to load the auction from, instead of a store")
+		chainID      = fs.String("chain-id", "", "chain ID of the auction")
+		height       = fs.Int64("height", 0, "height of the auction")
+		captureFile  = fs.String("capture", "", "JSON capture of the mempool txs, limits, and balances (optional)")
+		maxBytes     = fs.Int64("max-bytes", 0, "block max bytes, overrides the capture (-1 for no limit)")
+		maxGas       = fs.Int64("max-gas", 0, "block max gas, overrides the capture (-1 for no limit)")
+		diff         = fs.Bool("diff", false, "compare the result with the recorded build, exit 2 if it differs")
+		writeFile    = fs.String("write-export", "", "write the auction to a JSONL export, instead of replaying it")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "USAGE\n")
+		fmt.Fprintf(stderr, "  zenith-replay -store-conn-str postgres://... -chain-id X -height N [flags]\n")
+		fmt.Fprintf(stderr, "  zenith-replay -export auctions.jsonl -chain-id X -height N [flags]\n")
+		fmt.Fprintf(stderr, "  zenith-replay -store-conn-str postgres://... -chain-id X -height N -write-export auctions.jsonl\n")
+		fmt.Fprintf(stderr, "\n")
+		fmt.Fprintf(stderr, "EXPORT\n")
+		fmt.Fprintf(stderr, "  Written by -write-export. One JSON object per line, with one of the keys\n")
+		fmt.Fprintf(stderr, "  chain, auction, bid, or build, whose value is the JSON encoding of the\n")
+		fmt.Fprintf(stderr, "  store type. Exports of several auctions can be concatenated.\n")
+		fmt.Fprintf(stderr, "\n")
+		fmt.Fprintf(stderr, "CAPTURE\n")
+		fmt.Fprintf(stderr, "  {\"max_bytes\": N, \"max_gas\": N, \"txs\": [base64...],\n")
+		fmt.Fprintf(stderr, "   \"balances\": {addr: {denom: N}}, \"gas\": {tx hash: N}, \"invalid_txs\": [tx hash...]}\n")
+		fmt.Fprintf(stderr, "\n")
+		fmt.Fprintf(stderr, "FLAGS\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case *chainID == "":
+		return fmt.Errorf("-chain-id is required")
+	case *height <= 0:
+		return fmt.Errorf("-height is required")
+	case (*storeConnStr == "") == (*exportFile == ""):
+		return fmt.Errorf("exactly one of -store-conn-str or -export is required")
+	}
+
+	var src auctionSource
+	{
+		switch {
+		case *storeConnStr != "":
+			s, err := pgstore.NewStore(ctx, *storeConnStr, log.NewLogfmtLogger(stderr))
+			if err != nil {
+				return fmt.Errorf("create store: %w", err)
+			}
+			defer s.Close()
+			src = s
+
+		case *exportFile != "":
+			s, err := loadExport(*exportFile)
+			if err != nil {
+				return fmt.Errorf("load export: %w", err)
+			}
+			src = s
+		}
+	}
+
+	if *writeFile != "" {
+		f, err := os.Create(*writeFile)
+		if err != nil {
+			return fmt.Errorf("create export: %w", err)
+		}
+		if err := writeExport(ctx, src, *chainID, *height, f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	var capture replayCapture
+	if *captureFile != "" {
+		buf, err := os.ReadFile(*captureFile)
+		if err != nil {
+			return fmt.Errorf("read capture: %w", err)
+		}
+		if err := json.Unmarshal(buf, &capture); err != nil {
+			return fmt.Errorf("decode capture: %w", err)
+		}
+	}
+
+	ch, err := src.SelectChain(ctx, *chainID)
+	if err != nil {
+		return fmt.Errorf("get chain: %w", err)
+	}
+
+	auction, err := src.SelectAuction(ctx, *chainID, *height)
+	if err != nil {
+		return fmt.Errorf("get auction: %w", err)
+	}
+
+	// Range bids that a later auction accepted have moved to it since, but
+	// they took part in this one.
+	bids, err := src.ListEligibleBids(ctx, *chainID, *height)
+	if err != nil {
+		return fmt.Errorf("get bids: %w", err)
+	}
+
+	// The captured chain can't decode txs, so bids can't be evaluated again,
+	// and every bid is replayed as if it was placed for this auction, with
+	// the payments it was last evaluated with.
+	for i, b := range bids {
+		if b.Height != *height {
+			b := *b
+			b.Height = *height
+			bids[i] = &b
+		}
+	}
+
+	recorded, err := src.SelectBuild(ctx, *chainID, *height)
+	switch {
+	case err == nil:
+	case errors.Is(err, store.ErrNotFound) && !*diff:
+	default:
+		return fmt.Errorf("get recorded build: %w", err)
+	}
+
+	// Limits come from the flags, then the capture, then the recorded build.
+	limit := func(flagValue, captureValue int64, recordedValue func(*store.Build) int64) int64 {
+		switch {
+		case flagValue != 0:
+			return flagValue
+		case captureValue != 0:
+			return captureValue
+		case recorded != nil:
+			return recordedValue(recorded)
+		default:
+			return -1
+		}
+	}
+
+	var (
+		replayBytes = limit(*maxBytes, capture.MaxBytes, func(b *store.Build) int64 { return b.MaxBytes })
+		replayGas   = limit(*maxGas, capture.MaxGas, func(b *store.Build) int64 { return b.MaxGas })
+		replayChain = capture.recordedChain(*chainID, *height-1)
+	)
+
+	build, replayedBids, err := block.Replay(ctx, replayChain, auction, ch.BidSelection, bids, capture.Txs, replayBytes, replayGas)
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "auction %s/%d, validator %s, allocation %.3f\n", auction.ChainID, auction.Height, auction.ValidatorAddress, auction.ValidatorAllocation)
+	fmt.Fprintf(stdout, "limits %d bytes, %d gas, mempool tx count %d\n", replayBytes, replayGas, len(capture.Txs))
+	fmt.Fprintf(stdout, "\n")
+	printBuild(stdout, build)
+	fmt.Fprintf(stdout, "\n")
+	fmt.Fprintf(stdout, "bids\n")
+	for _, b := range replayedBids {
+		fmt.Fprintf(stdout, "  %s  %-8s  priority %d  %s\n", b.ID, b.State, b.Priority, b.RejectionReason)
+	}
+
+	if !*diff {
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "\n")
+	if lines := diffBuilds(recorded, build); len(lines) > 0 {
+		fmt.Fprintf(stdout, "diff (- recorded, + replayed)\n")
+		for _, line := range lines {
+			fmt.Fprintf(stdout, "  %s\n", line)
+		}
+		return errDiffers
+	}
+
+	fmt.Fprintf(stdout, "replayed build matches recorded build\n")
+	return nil
+}
+
+// auctionSource is the subset of store.Store needed to replay an auction.
+type auctionSource interface {
+	SelectChain(ctx context.Context, id string) (*store.Chain, error)
+	SelectAuction(ctx context.Context, chainID string, height int64) (*store.Auction, error)
+	ListEligibleBids(ctx context.Context, chainID string, height int64) ([]*store.Bid, error)
+	SelectBuild(ctx context.Context, chainID string, height int64) (*store.Build, error)
+}
+
+var _ auctionSource = (store.Store)(nil)
+
+// replayCapture is the mempool and chain state a block was built from.
+type replayCapture struct {
+	MaxBytes   int64                       `json:"max_bytes"`
+	MaxGas     int64                       `json:"max_gas"`
+	Txs        [][]byte                    `json:"txs"`
+	Balances   map[string]map[string]int64 `json:"balances"`
+	Gas        map[string]int64            `json:"gas"`
+	InvalidTxs []string                    `json:"invalid_txs"`
+}
+
+// recordedChain serves the captured state, with txs as opaque bytes, since
+// bids are replayed with the payments they were evaluated with.
+func (c *replayCapture) recordedChain(chainID string, height int64) *chain.RecordedChain {
+	gas := make(map[string]int64, len(c.Gas))
+	for hash, n := range c.Gas {
+		gas[strings.ToUpper(hash)] = n // as cryptoutil.HashTx
+	}
+
+	invalid := make(map[string]bool, len(c.InvalidTxs))
+	for _, hash := range c.InvalidTxs {
+		invalid[strings.ToUpper(hash)] = true
+	}
+
+	return &chain.RecordedChain{
+		ChainID:    chainID,
+		Height:     height,
+		Balances:   c.Balances,
+		Gas:        gas,
+		InvalidTxs: invalid,
+	}
+}
+
+func printBuild(w io.Writer, b *store.Build) {
+	fmt.Fprintf(w, "block tx count %d, used %d bytes, %d gas\n", len(b.Txs), b.UsedBytes, b.UsedGas)
+	for i, tx := range b.Txs {
+		fmt.Fprintf(w, "  %d/%d  %s  %s\n", i+1, len(b.Txs), tx.Hash, txSource(tx))
+	}
+	fmt.Fprintf(w, "payments %d%s to validator, %d%s to mekatek\n", b.ValidatorPayment, b.PaymentDenom, b.MekatekPayment, b.PaymentDenom)
+}
+
+func txSource(tx store.BuildTx) string {
+	if tx.BidID != nil {
+		return fmt.Sprintf("%s %s", tx.Source, tx.BidID)
+	}
+	return string(tx.Source)
+}
+
+// diffBuilds returns a line for every difference between the recorded and
+// replayed builds, or nothing if they're the same.
+func diffBuilds(recorded, replayed *store.Build) []string {
+	var lines []string
+
+	for i := 0; i < len(recorded.Txs) || i < len(replayed.Txs); i++ {
+		var have, want string
+		if i < len(recorded.Txs) {
+			want = fmt.Sprintf("%s  %s", recorded.Txs[i].Hash, txSource(recorded.Txs[i]))
+		}
+		if i < len(replayed.Txs) {
+			have = fmt.Sprintf("%s  %s", replayed.Txs[i].Hash, txSource(replayed.Txs[i]))
+		}
+		if have == want {
+			continue
+		}
+		if want != "" {
+			lines = append(lines, fmt.Sprintf("- tx %d: %s", i+1, want))
+		}
+		if have != "" {
+			lines = append(lines, fmt.Sprintf("+ tx %d: %s", i+1, have))
+		}
+	}
+
+	for _, field := range []struct {
+		name       string
+		want, have int64
+		unit       string
+	}{
+		{name: "validator payment", want: recorded.ValidatorPayment, have: replayed.ValidatorPayment, unit: recorded.PaymentDenom},
+		{name: "mekatek payment", want: recorded.MekatekPayment, have: replayed.MekatekPayment, unit: recorded.PaymentDenom},
+		{name: "used bytes", want: recorded.UsedBytes, have: replayed.UsedBytes},
+		{name: "used gas", want: recorded.UsedGas, have: replayed.UsedGas},
+	} {
+		if field.want != field.have {
+			lines = append(lines, fmt.Sprintf("- %s: %d%s", field.name, field.want, field.unit))
+			lines = append(lines, fmt.Sprintf("+ %s: %d%s", field.name, field.have, field.unit))
+		}
+	}
+
+	return lines
+}
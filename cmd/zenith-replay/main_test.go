@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"zenith/block"
+	"zenith/chain"
+	"zenith/store"
+	"zenith/store/memstore"
+	"zenith/store/storetest"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestDiffBuilds(t *testing.T) {
+	bidID := uuid.Must(uuid.NewV4())
+
+	build := func(validatorPayment int64, txs ...store.BuildTx) *store.Build {
+		return &store.Build{PaymentDenom: "uosmo", ValidatorPayment: validatorPayment, MekatekPayment: 3, UsedBytes: 10, UsedGas: 20, Txs: txs}
+	}
+
+	var (
+		bidTx     = store.BuildTx{Hash: "AA", Source: store.BuildTxSourceBid, BidID: &bidID}
+		mempoolTx = store.BuildTx{Hash: "BB", Source: store.BuildTxSourceMempool}
+	)
+
+	for _, testcase := range []struct {
+		name               string
+		recorded, replayed *store.Build
+		want               []string
+	}{
+		{
+			name:     "same",
+			recorded: build(97, bidTx, mempoolTx),
+			replayed: build(97, bidTx, mempoolTx),
+		},
+		{
+			name:     "reordered",
+			recorded: build(97, bidTx, mempoolTx),
+			replayed: build(97, mempoolTx, bidTx),
+			want: []string{
+				"- tx 1: AA  bid " + bidID.String(),
+				"+ tx 1: BB  mempool",
+				"- tx 2: BB  mempool",
+				"+ tx 2: AA  bid " + bidID.String(),
+			},
+		},
+		{
+			name:     "missing tx",
+			recorded: build(97, bidTx, mempoolTx),
+			replayed: build(97, bidTx),
+			want:     []string{"- tx 2: BB  mempool"},
+		},
+		{
+			name:     "extra tx",
+			recorded: build(97),
+			replayed: build(97, mempoolTx),
+			want:     []string{"+ tx 1: BB  mempool"},
+		},
+		{
+			name:     "payment",
+			recorded: build(97, bidTx),
+			replayed: build(0, bidTx),
+			want:     []string{"- validator payment: 97uosmo", "+ validator payment: 0uosmo"},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			if want, have := fmt.Sprintf("%q", testcase.want), fmt.Sprintf("%q", diffBuilds(testcase.recorded, testcase.replayed)); want != have {
+				t.Errorf("want %s, have %s", want, have)
+			}
+		})
+	}
+}
+
+func TestExportSource(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		st        = memstore.NewStore()
+		c         = storetest.NewChain(t, st)
+		validator = storetest.NewValidator(t, st, c)
+		auction1  = storetest.NewAuction(t, st, c, 1, validator)
+		auction2  = storetest.NewAuction(t, st, c, 2, validator)
+		bid1      = storetest.NewBid(t, st, c, auction1)
+		bid2      = storetest.NewBid(t, st, c, auction2)
+		filename  = filepath.Join(t.TempDir(), "export.jsonl")
+	)
+
+	// A bid across both heights, which the second auction accepted.
+	rangeBid := *storetest.NewBid(t, st, c, auction1)
+	if err := st.DeleteBid(ctx, rangeBid.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	rangeBid.MaxHeight = auction2.Height
+	if err := st.InsertBid(ctx, &rangeBid); err != nil {
+		t.Fatal(err)
+	}
+	rangeBid.Height, rangeBid.State = auction2.Height, store.BidStateAccepted
+	if err := st.UpdateBids(ctx, &rangeBid); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for _, a := range []*store.Auction{auction1, auction2} {
+		if err := writeExport(ctx, st, c.ID, a.Height, &buf); err != nil {
+			t.Fatalf("write export %d: %v", a.Height, err)
+		}
+	}
+	buf.WriteString("\n") // blank lines are skipped
+	if err := os.WriteFile(filename, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := loadExport(filename)
+	if err != nil {
+		t.Fatalf("load export: %v", err)
+	}
+
+	if _, err := src.SelectChain(ctx, c.ID); err != nil {
+		t.Errorf("select chain: %v", err)
+	}
+	if _, err := src.SelectAuction(ctx, c.ID, auction2.Height); err != nil {
+		t.Errorf("select auction: %v", err)
+	}
+	if _, err := src.SelectAuction(ctx, c.ID, 3); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("select missing auction: want %v, have %v", store.ErrNotFound, err)
+	}
+	if _, err := src.SelectBuild(ctx, c.ID, auction1.Height); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("select missing build: want %v, have %v", store.ErrNotFound, err)
+	}
+
+	for _, testcase := range []struct {
+		chainID string
+		height  int64
+		want    []*store.Bid
+	}{
+		{chainID: c.ID, height: auction1.Height, want: []*store.Bid{bid1, &rangeBid}},
+		{chainID: c.ID, height: auction2.Height, want: []*store.Bid{bid2, &rangeBid}},
+		{chainID: c.ID, height: 3},
+		{chainID: "other-chain", height: auction1.Height},
+	} {
+		bids, err := src.ListEligibleBids(ctx, testcase.chainID, testcase.height)
+		if err != nil {
+			t.Fatalf("%s/%d: list bids: %v", testcase.chainID, testcase.height, err)
+		}
+		if want, have := bidIDs(testcase.want), bidIDs(bids); want != have {
+			t.Errorf("%s/%d: bids: want %s, have %s", testcase.chainID, testcase.height, want, have)
+		}
+	}
+
+	if err := os.WriteFile(filename, []byte("{\"chain\":{}}\n{}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadExport(filename); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("load invalid export: want line 2 error, have %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		height    = int64(123)
+		validator = &chain.Validator{Address: "validator", PaymentAddress: "validator", PubKeyType: "test", PubKeyBytes: []byte("validator"), VotingPower: 1}
+		searcher  = storetest.GenBech32Addr(t, storetest.Network)
+		st        = memstore.NewStore()
+		c         = storetest.NewChain(t, st)
+		mockChain = &chain.TestChain{
+			ChainID:           c.ID,
+			Height:            height,
+			Validators:        chain.ValidatorSet{Height: height, Set: map[string]*chain.Validator{validator.Address: validator}, TotalPower: 1},
+			PredictedProposer: *validator,
+		}
+		service  = block.NewCoreService(mockChain, st)
+		bidTx    = []byte("pay")
+		filename = filepath.Join(t.TempDir(), "export.jsonl")
+	)
+
+	if err := st.UpsertValidator(ctx, &store.Validator{
+		ChainID:        c.ID,
+		Address:        validator.Address,
+		PubKeyType:     validator.PubKeyType,
+		PubKeyBytes:    validator.PubKeyBytes,
+		PaymentAddress: validator.PaymentAddress,
+	}); err != nil {
+		t.Fatalf("register validator: %v", err)
+	}
+
+	auction, err := service.Auction(ctx, height+1)
+	if err != nil {
+		t.Fatalf("auction: %v", err)
+	}
+
+	mockChain.Payments = map[string][]chain.Payment{
+		string(bidTx): {
+			{From: searcher, To: auction.ValidatorPaymentAddress, Denom: storetest.Denom, Amount: 97},
+			{From: searcher, To: auction.MekatekPaymentAddress, Denom: storetest.Denom, Amount: 3},
+		},
+	}
+
+	bid, err := service.Bid(ctx, height+1, height+2, string(store.BidKindBlock), "", [][]byte{bidTx}, "", nil)
+	if err != nil {
+		t.Fatalf("bid: %v", err)
+	}
+
+	// The block at height+1 has no room for the bid, so height+2 accepts it.
+	if _, _, err := service.BuildV1(ctx, height+1, validator.Address, 1, -1, nil, []byte("signature")); err != nil {
+		t.Fatalf("build %d: %v", height+1, err)
+	}
+	mockChain.Height, mockChain.Validators.Height = height+1, height+1
+	if _, _, err := service.BuildV1(ctx, height+2, validator.Address, -1, -1, nil, []byte("signature")); err != nil {
+		t.Fatalf("build %d: %v", height+2, err)
+	}
+
+	run := func(args ...string) (string, error) {
+		var stdout, stderr bytes.Buffer
+		err := run(ctx, args, &stdout, &stderr)
+		return stdout.String(), err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []int64{height + 1, height + 2} {
+		if err := writeExport(ctx, st, c.ID, h, f); err != nil {
+			t.Fatalf("write export %d: %v", h, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	capture := filepath.Join(t.TempDir(), "capture.json")
+	if err := os.WriteFile(capture, []byte(fmt.Sprintf(`{"balances": {%q: {%q: 100}}}`, searcher, storetest.Denom)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bid took part in both auctions, and only fit in the second one.
+	for _, testcase := range []struct {
+		height int64
+		want   []string
+	}{
+		{height: height + 1, want: []string{"block tx count 0", fmt.Sprintf("%s  rejected", bid.ID), "replayed build matches recorded build"}},
+		{height: height + 2, want: []string{"block tx count 1", fmt.Sprintf("%s  accepted", bid.ID)}},
+	} {
+		args := []string{"-export", filename, "-capture", capture, "-chain-id", c.ID, "-height", fmt.Sprint(testcase.height)}
+		if testcase.height == height+1 {
+			args = append(args, "-diff") // the test chain's txs have a different size than their bytes
+		}
+		out, err := run(args...)
+		if err != nil {
+			t.Fatalf("replay %d: %v\n%s", testcase.height, err, out)
+		}
+		for _, want := range testcase.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("replay %d: want %q in output\n%s", testcase.height, want, out)
+			}
+		}
+	}
+
+	if _, err := run("-export", filename, "-capture", capture, "-chain-id", c.ID, "-height", fmt.Sprint(height+1), "-max-bytes", "-1", "-diff"); !errors.Is(err, errDiffers) {
+		t.Errorf("replay with other limits: want %v, have %v", errDiffers, err)
+	}
+}
+
+func bidIDs(bids []*store.Bid) string {
+	ids := make([]string, len(bids))
+	for i, b := range bids {
+		ids[i] = b.ID.String()
+	}
+	return strings.Join(ids, ",")
+}
@@ -0,0 +1,80 @@
+package debug
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Auth is the credentials that debug requests must present, either as a bearer
+// token, or as HTTP basic auth. The zero value allows every request.
+type Auth struct {
+	BearerToken string
+	Username    string
+	Password    string
+
+	// OpenMetrics allows requests to /metrics without credentials, so that
+	// scrapers don't need them. Traces and profiles are still protected.
+	OpenMetrics bool
+}
+
+// Validate returns an error if the auth has a username without a password,
+// or a password without a username.
+func (a Auth) Validate() error {
+	if (a.Username == "") != (a.Password == "") {
+		return errors.New("basic auth needs both a username and a password")
+	}
+	return nil
+}
+
+// Enabled returns true if the auth requires any credentials.
+func (a Auth) Enabled() bool {
+	return a.BearerToken != "" || a.Username != "" || a.Password != ""
+}
+
+// basic returns true if the auth accepts HTTP basic auth, which needs both a
+// username and a password.
+func (a Auth) basic() bool {
+	return a.Username != "" && a.Password != ""
+}
+
+func (a Auth) allow(r *http.Request) bool {
+	if a.OpenMetrics && r.URL.Path == "/metrics" {
+		return true
+	}
+
+	if user, pass, ok := r.BasicAuth(); ok && a.basic() {
+		return secureCompare(user, a.Username) && secureCompare(pass, a.Password)
+	}
+
+	if header := r.Header.Get("authorization"); a.BearerToken != "" && strings.HasPrefix(header, "Bearer ") {
+		return secureCompare(strings.TrimPrefix(header, "Bearer "), a.BearerToken)
+	}
+
+	return false
+}
+
+func secureCompare(have, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(have), []byte(want)) == 1
+}
+
+func authMiddleware(auth Auth) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !auth.Enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.allow(r) {
+				if auth.Username != "" || auth.Password != "" {
+					w.Header().Set("www-authenticate", `Basic realm="zenith debug"`)
+				} else {
+					w.Header().Set("www-authenticate", `Bearer realm="zenith debug"`)
+				}
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
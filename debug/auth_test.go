@@ -0,0 +1,60 @@
+package debug
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	handler := authMiddleware(Auth{
+		BearerToken: "secret-token",
+		Username:    "admin",
+		Password:    "hunter2",
+		OpenMetrics: true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, testcase := range []struct {
+		name     string
+		path     string
+		setup    func(r *http.Request)
+		wantCode int
+	}{
+		{"no credentials", "/traces", func(*http.Request) {}, http.StatusUnauthorized},
+		{"bearer token", "/traces", func(r *http.Request) { r.Header.Set("authorization", "Bearer secret-token") }, http.StatusOK},
+		{"bad bearer token", "/traces", func(r *http.Request) { r.Header.Set("authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"basic auth", "/debug/pprof/", func(r *http.Request) { r.SetBasicAuth("admin", "hunter2") }, http.StatusOK},
+		{"bad basic auth", "/debug/pprof/", func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }, http.StatusUnauthorized},
+		{"open metrics", "/metrics", func(*http.Request) {}, http.StatusOK},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", testcase.path, nil)
+			testcase.setup(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if want, have := testcase.wantCode, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+		})
+	}
+
+	// Basic auth needs both a username and a password. With only one, the
+	// auth is invalid, and nothing is allowed, even with the other one empty.
+	for _, auth := range []Auth{{Username: "admin"}, {Password: "hunter2"}} {
+		t.Run(fmt.Sprintf("partial basic auth %+v", auth), func(t *testing.T) {
+			if err := auth.Validate(); err == nil {
+				t.Errorf("validate: want error, have none")
+			}
+
+			handler := authMiddleware(auth)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest("GET", "/traces", nil)
+			req.SetBasicAuth(auth.Username, auth.Password)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if want, have := http.StatusUnauthorized, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+		})
+	}
+}
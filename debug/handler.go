@@ -11,7 +11,36 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func NewHandler() http.Handler {
+// HandlerOption configures optional parts of the debug handler.
+type HandlerOption func(*handlerConfig)
+
+type handlerConfig struct {
+	auth   Auth
+	mounts []mount
+}
+
+type mount struct {
+	prefix  string
+	handler http.Handler
+}
+
+// WithAuth requires the credentials of the auth for debug requests.
+func WithAuth(auth Auth) HandlerOption {
+	return func(c *handlerConfig) { c.auth = auth }
+}
+
+// WithHandler serves the handler for requests with the path prefix, behind the
+// same auth as the rest of the debug handler.
+func WithHandler(prefix string, handler http.Handler) HandlerOption {
+	return func(c *handlerConfig) { c.mounts = append(c.mounts, mount{prefix, handler}) }
+}
+
+func NewHandler(options ...HandlerOption) http.Handler {
+	var cfg handlerConfig
+	for _, option := range options {
+		option(&cfg)
+	}
+
 	router := mux.NewRouter()
 	router.StrictSlash(true)
 
@@ -31,9 +60,14 @@ func NewHandler() http.Handler {
 	router.Methods("GET").Path("/traces").Handler(eztrc.TracesHandler)
 	router.Methods("GET").Path("/logs").Handler(eztrc.LogsHandler)
 
+	for _, m := range cfg.mounts {
+		router.PathPrefix(m.prefix).Handler(m.handler)
+	}
+
 	router.Methods("GET").Path("/").Handler(indexHandler(router))
 
 	router.Use(
+		authMiddleware(cfg.auth), // before tracing, so rejected requests aren't traced
 		TracingMiddleware,
 		// MetricsMiddleware, // debug endpoint metrics just pollute the dashboards
 		GZipMiddleware,
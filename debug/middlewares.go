@@ -18,7 +18,7 @@ func GZipMiddleware(next http.Handler) http.Handler {
 
 func TracingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx, finish := eztrc.Create(r.Context(), getRouteName(r))
+		ctx, finish := eztrc.Create(r.Context(), RouteName(r))
 		defer finish()
 
 		eztrc.Tracef(ctx, "%s %s %s", r.RemoteAddr, r.Method, r.URL.String())
@@ -45,7 +45,7 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		iw := newInterceptor(w)
 		defer func(b time.Time) {
-			route := getRouteName(r)
+			route := RouteName(r)
 			code := strconv.Itoa(iw.Code())
 			sec := time.Since(b).Seconds()
 			metrics.HTTPRequestDurationSeconds.WithLabelValues(route, code).Observe(sec)
@@ -55,9 +55,9 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// getRouteName only works if it's called via mux.Router.Use(middleware).
+// RouteName only works if it's called via mux.Router.Use(middleware).
 // If you try to decorate an http.Handler, it won't identify the route.
-func getRouteName(r *http.Request) string {
+func RouteName(r *http.Request) string {
 	if route := mux.CurrentRoute(r); route != nil {
 		// If an explicit name was defined, use that directly.
 		if name := route.GetName(); name != "" {
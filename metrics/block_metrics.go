@@ -11,6 +11,12 @@ var RegisterRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 	Help:      "Total number of registration requests seen by the service.",
 }, []string{"chain_id", "phase", "result"})
 
+var SearcherRegisterRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "searcher_register_requests_total",
+	Help:      "Total number of searcher registration requests seen by the service.",
+}, []string{"chain_id", "phase", "result"})
+
 var AuctionRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 	Namespace: "zenith",
 	Name:      "auction_requests_total",
@@ -35,18 +41,42 @@ var BidTxsNormalizedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 	Help:      "Total number of bid txs that changed after decode/encode during build.",
 }, []string{"chain_id"})
 
+var DecodeCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "decode_cache_requests_total",
+	Help:      "Total number of tx decodes, by whether the decoded tx was cached (hit, miss).",
+}, []string{"chain_id", "result"})
+
 var BuildRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 	Namespace: "zenith",
 	Name:      "build_requests_total",
 	Help:      "Total number of build requests seen by the service.",
 }, []string{"chain_id", "result"})
 
+var PreviewRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "preview_requests_total",
+	Help:      "Total number of block preview requests seen by the service.",
+}, []string{"chain_id", "result"})
+
+var BuildResultsReusedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "build_results_reused_total",
+	Help:      "Total number of retried build requests answered with a previously built block.",
+}, []string{"chain_id"})
+
 var BidsEvaluatedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 	Namespace: "zenith",
 	Name:      "bids_evaluated_total",
 	Help:      "Total number of bids evaluated during block building.",
 }, []string{"chain_id", "result"})
 
+var BidSelectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "bid_selections_total",
+	Help:      "Total number of winning bid selections, by strategy and whether the search completed.",
+}, []string{"chain_id", "strategy", "search"})
+
 var BlocksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 	Namespace: "zenith",
 	Name:      "blocks_total",
@@ -76,3 +106,21 @@ var ValidatorLastBuildTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
 	Name:      "validator_last_build_timestamp",
 	Help:      "UNIX timestamp of most recent build request from validator.",
 }, []string{"chain_id", "validator_addr", "result"})
+
+var BuildInclusionTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "build_inclusion_total",
+	Help:      "Built blocks checked against the committed block, by proposer and inclusion state.",
+}, []string{"chain_id", "proposer_addr", "state"})
+
+var BidInclusionTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "bid_inclusion_total",
+	Help:      "Accepted bids checked against the committed block, by bid state.",
+}, []string{"chain_id", "state"})
+
+var BidChangesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "bid_changes_total",
+	Help:      "Bid cancel and replace requests from searchers.",
+}, []string{"chain_id", "op", "result"})
@@ -20,4 +20,10 @@ var (
 		ConstLabels: prometheus.Labels{},
 		Buckets:     httpBuckets,
 	}, []string{"route", "code"})
+
+	HTTPThrottledRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "zenith",
+		Name:      "http_throttled_requests_total",
+		Help:      "HTTP requests rejected by a rate limit, by the kind of bucket that was empty.",
+	}, []string{"route", "bucket"})
 )
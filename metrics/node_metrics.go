@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var NodeRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "node_requests_total",
+	Help:      "Total number of requests to full nodes, by result (ok, error, stalled).",
+}, []string{"chain_id", "node", "result"})
+
+var NodeRequestSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "zenith",
+	Name:      "node_request_seconds",
+	Help:      "Time spent on requests to full nodes, including failed ones.",
+}, []string{"chain_id", "node"})
+
+var NodeCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "zenith",
+	Name:      "node_circuit_open",
+	Help:      "1 if a full node isn't used because it's failing or stalled, 0 otherwise.",
+}, []string{"chain_id", "node"})
+
+var NodeProbesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "node_probes_total",
+	Help:      "Total number of recovery probes of full nodes with an open circuit.",
+}, []string{"chain_id", "node", "result"})
+
+var NodeHedgesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "node_hedges_total",
+	Help:      "Total number of reads also sent to another full node, because the first was slow to answer.",
+}, []string{"chain_id"})
+
+var HeadSubscriptionUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "zenith",
+	Name:      "head_subscription_up",
+	Help:      "1 if the subscription to new blocks of a chain is receiving them, 0 otherwise.",
+}, []string{"chain_id"})
+
+var LatestHeightsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "zenith",
+	Name:      "latest_heights_total",
+	Help:      "Total number of latest height lookups, by source (subscription, rpc).",
+}, []string{"chain_id", "source"})
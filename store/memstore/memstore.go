@@ -1,6 +1,7 @@
 package memstore
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"sort"
@@ -13,12 +14,16 @@ import (
 )
 
 type Store struct {
-	mu         sync.Mutex
-	bids       map[auctionKey][]*store.Bid
-	auctions   map[auctionKey]*store.Auction
-	challenges map[string]*store.Challenge
-	validators map[validatorKey]*store.Validator
-	chains     map[string]*store.Chain
+	mu                 sync.Mutex
+	bids               map[auctionKey][]*store.Bid
+	auctions           map[auctionKey]*store.Auction
+	buildResults       map[auctionKey]*store.BuildResult
+	builds             map[auctionKey]*store.Build
+	challenges         map[string]*store.Challenge
+	searcherChallenges map[string]*store.SearcherChallenge
+	searchers          map[string]*store.Searcher
+	validators         map[validatorKey]*store.Validator
+	chains             map[string]*store.Chain
 }
 
 type validatorKey struct {
@@ -35,11 +40,15 @@ var _ store.Store = (*Store)(nil)
 
 func NewStore() *Store {
 	return &Store{
-		bids:       map[auctionKey][]*store.Bid{},
-		auctions:   map[auctionKey]*store.Auction{},
-		challenges: map[string]*store.Challenge{},
-		validators: map[validatorKey]*store.Validator{},
-		chains:     map[string]*store.Chain{},
+		bids:               map[auctionKey][]*store.Bid{},
+		auctions:           map[auctionKey]*store.Auction{},
+		buildResults:       map[auctionKey]*store.BuildResult{},
+		builds:             map[auctionKey]*store.Build{},
+		challenges:         map[string]*store.Challenge{},
+		searcherChallenges: map[string]*store.SearcherChallenge{},
+		searchers:          map[string]*store.Searcher{},
+		validators:         map[validatorKey]*store.Validator{},
+		chains:             map[string]*store.Chain{},
 	}
 }
 
@@ -65,6 +74,9 @@ func (s *Store) InsertBid(ctx context.Context, b *store.Bid) error {
 	}
 
 	b.CreatedAt = time.Now().UTC()
+	if b.FirstHeight == 0 {
+		b.FirstHeight = b.Height
+	}
 
 	newBid := *b
 	key := auctionKey{b.ChainID, b.Height}
@@ -78,14 +90,29 @@ func (s *Store) UpdateBids(ctx context.Context, bids ...*store.Bid) error {
 	defer s.mu.Unlock()
 
 	for _, b := range bids {
-		key := auctionKey{b.ChainID, b.Height}
+		for key, existing := range s.bids {
+			i := indexOfBid(existing, b.ID)
+			if i < 0 {
+				continue
+			}
 
-		for _, o := range s.bids[key] {
-			if b.ID == o.ID { // update
-				o.State = b.State
-				o.UpdatedAt = time.Now().UTC()
-				break
+			o := existing[i]
+			o.State = b.State
+			o.RejectionReason = b.RejectionReason
+			o.Priority = b.Priority
+			o.MekatekPayment = b.MekatekPayment
+			o.ValidatorPayment = b.ValidatorPayment
+			o.Payments = b.Payments
+			o.UpdatedAt = time.Now().UTC()
+
+			// A bid across a range of heights moves to the auction that accepted it.
+			if b.Height != key.height {
+				o.Height = b.Height
+				s.bids[key] = append(existing[:i:i], existing[i+1:]...)
+				newKey := auctionKey{b.ChainID, b.Height}
+				s.bids[newKey] = append(s.bids[newKey], o)
 			}
+			break
 		}
 	}
 
@@ -96,8 +123,117 @@ func (s *Store) ListBids(ctx context.Context, chainID string, height int64) ([]*
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	key := auctionKey{chainID, height}
-	return s.bids[key], nil
+	var bids []*store.Bid
+	bids = append(bids, s.bids[auctionKey{chainID, height}]...)
+
+	// Bids across a range of heights remain eligible until they're accepted.
+	for key, existing := range s.bids {
+		if key.chainID != chainID || key.height >= height {
+			continue
+		}
+		for _, b := range existing {
+			if b.MaxHeight >= height && (b.State == store.BidStatePending || b.State == store.BidStateRejected) {
+				bids = append(bids, b)
+			}
+		}
+	}
+
+	sort.SliceStable(bids, func(i, j int) bool { return bids[i].CreatedAt.Before(bids[j].CreatedAt) })
+
+	return bids, nil
+}
+
+func (s *Store) ListEligibleBids(ctx context.Context, chainID string, height int64) ([]*store.Bid, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bids []*store.Bid
+	for key, existing := range s.bids {
+		if key.chainID != chainID {
+			continue
+		}
+		for _, b := range existing {
+			if b.EligibleFor(height) {
+				bids = append(bids, b)
+			}
+		}
+	}
+
+	sort.SliceStable(bids, func(i, j int) bool { return bids[i].CreatedAt.Before(bids[j].CreatedAt) })
+
+	return bids, nil
+}
+
+func (s *Store) SelectBid(ctx context.Context, id string) (*store.Bid, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, bids := range s.bids {
+		for _, b := range bids {
+			if b.ID.String() == id {
+				return b, nil
+			}
+		}
+	}
+
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) DeleteBid(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.deleteBid(id) {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) ReplaceBid(ctx context.Context, oldID string, b *store.Bid) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.deleteBid(oldID) {
+		return store.ErrNotFound
+	}
+
+	var err error
+	if b.ID, err = uuid.NewV4(); err != nil {
+		return fmt.Errorf("generate bid ID: %w", err)
+	}
+
+	b.CreatedAt = time.Now().UTC()
+	if b.FirstHeight == 0 {
+		b.FirstHeight = b.Height
+	}
+
+	newBid := *b
+	key := auctionKey{b.ChainID, b.Height}
+	s.bids[key] = append(s.bids[key], &newBid)
+
+	return nil
+}
+
+func indexOfBid(bids []*store.Bid, id uuid.UUID) int {
+	for i, b := range bids {
+		if b.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) deleteBid(id string) bool {
+	for key, bids := range s.bids {
+		for i, b := range bids {
+			if b.ID.String() == id {
+				s.bids[key] = append(bids[:i:i], bids[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (s *Store) UpsertAuction(ctx context.Context, a *store.Auction) error {
@@ -133,6 +269,182 @@ func (s *Store) SelectAuction(ctx context.Context, chainID string, height int64)
 	return nil, store.ErrNotFound
 }
 
+func (s *Store) ResetAuctions(ctx context.Context, chainID string, minHeight int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	match := func(key auctionKey) bool { return key.chainID == chainID && key.height >= minHeight }
+
+	for key, bids := range s.bids {
+		if match(key) {
+			for _, b := range bids {
+				b.State, b.RejectionReason = store.BidStatePending, ""
+			}
+		}
+	}
+	for key := range s.buildResults {
+		if match(key) {
+			delete(s.buildResults, key)
+		}
+	}
+	for key := range s.builds {
+		if match(key) {
+			delete(s.builds, key)
+		}
+	}
+
+	var n int64
+	for key, a := range s.auctions {
+		if match(key) {
+			a.FinishedAt = time.Time{}
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (s *Store) DeleteAuctions(ctx context.Context, chainID string, minHeight int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	match := func(key auctionKey) bool { return key.chainID == chainID && key.height >= minHeight }
+
+	for key := range s.bids {
+		if match(key) {
+			delete(s.bids, key)
+		}
+	}
+	for key := range s.buildResults {
+		if match(key) {
+			delete(s.buildResults, key)
+		}
+	}
+	for key := range s.builds {
+		if match(key) {
+			delete(s.builds, key)
+		}
+	}
+
+	var n int64
+	for key := range s.auctions {
+		if match(key) {
+			delete(s.auctions, key)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (s *Store) InsertBuildResult(ctx context.Context, r *store.BuildResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := auctionKey{r.ChainID, r.Height}
+
+	if _, ok := s.auctions[key]; !ok {
+		return fmt.Errorf("auction %s/%d: %w", r.ChainID, r.Height, store.ErrNotFound)
+	}
+
+	if _, ok := s.buildResults[key]; ok {
+		return fmt.Errorf("build result for %s/%d already exists", r.ChainID, r.Height)
+	}
+
+	r.CreatedAt = time.Now().UTC()
+	newResult := *r
+	s.buildResults[key] = &newResult
+
+	return nil
+}
+
+func (s *Store) SelectBuildResult(ctx context.Context, chainID string, height int64) (*store.BuildResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := auctionKey{chainID, height}
+
+	if r := s.buildResults[key]; r != nil {
+		return r, nil
+	}
+
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) InsertBuild(ctx context.Context, b *store.Build) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := auctionKey{b.ChainID, b.Height}
+
+	if _, ok := s.builds[key]; ok {
+		return fmt.Errorf("build for %s/%d already exists", b.ChainID, b.Height)
+	}
+
+	if b.InclusionState == "" {
+		b.InclusionState = store.BuildInclusionPending
+	}
+
+	b.CreatedAt = time.Now().UTC()
+	newBuild := *b
+	newBuild.Txs = append([]store.BuildTx(nil), b.Txs...)
+	s.builds[key] = &newBuild
+
+	return nil
+}
+
+func (s *Store) SelectBuild(ctx context.Context, chainID string, height int64) (*store.Build, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := auctionKey{chainID, height}
+
+	if b := s.builds[key]; b != nil {
+		return b, nil
+	}
+
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) ListPendingBuilds(ctx context.Context, chainID string, maxHeight int64, limit int) ([]*store.Build, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var builds []*store.Build
+	for key, b := range s.builds {
+		if key.chainID == chainID && key.height <= maxHeight && b.InclusionState == store.BuildInclusionPending {
+			builds = append(builds, b)
+		}
+	}
+
+	sort.SliceStable(builds, func(i, j int) bool {
+		return builds[i].Height < builds[j].Height
+	})
+
+	if len(builds) > limit {
+		builds = builds[:limit]
+	}
+
+	return builds, nil
+}
+
+func (s *Store) UpdateBuildInclusion(ctx context.Context, b *store.Build) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := auctionKey{b.ChainID, b.Height}
+
+	o, ok := s.builds[key]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	o.InclusionState = b.InclusionState
+	o.ProposerAddress = b.ProposerAddress
+
+	return nil
+}
+
 func (s *Store) InsertChallenge(ctx context.Context, c *store.Challenge) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -176,6 +488,90 @@ func (s *Store) DeleteChallenge(ctx context.Context, id string) error {
 	return nil
 }
 
+func (s *Store) InsertSearcherChallenge(ctx context.Context, c *store.SearcherChallenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("uuid gen failed: %w", err)
+	}
+
+	c.ID = id
+	c.CreatedAt = time.Now()
+
+	cc := *c
+	s.searcherChallenges[c.ID.String()] = &cc
+
+	return nil
+}
+
+func (s *Store) SelectSearcherChallenge(ctx context.Context, id string) (*store.SearcherChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.searcherChallenges[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	cc := *c
+	return &cc, nil
+}
+
+func (s *Store) DeleteSearcherChallenge(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.searcherChallenges[id]; !ok {
+		return store.ErrNotFound
+	}
+
+	delete(s.searcherChallenges, id)
+	return nil
+}
+
+func (s *Store) UpsertSearcher(ctx context.Context, sr *store.Searcher) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.searchers {
+		if existing.ChainID == sr.ChainID && bytes.Equal(existing.PubKeyBytes, sr.PubKeyBytes) { // update
+			existing.UpdatedAt = time.Now().UTC()
+			sr.ID, sr.CreatedAt, sr.UpdatedAt = existing.ID, existing.CreatedAt, existing.UpdatedAt
+			return nil
+		}
+	}
+
+	// create
+	id, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("uuid gen failed: %w", err)
+	}
+
+	sr.ID = id
+	sr.CreatedAt = time.Now().UTC()
+	sr.UpdatedAt = sr.CreatedAt
+
+	newSearcher := *sr
+	s.searchers[sr.ID.String()] = &newSearcher
+
+	return nil
+}
+
+func (s *Store) SelectSearcher(ctx context.Context, id string) (*store.Searcher, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sr, ok := s.searchers[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	cp := *sr
+	return &cp, nil
+}
+
 func (s *Store) UpsertValidator(ctx context.Context, v *store.Validator) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -229,6 +625,21 @@ func (s *Store) ListValidators(ctx context.Context, chainID string) ([]*store.Va
 	return vs, nil
 }
 
+func (s *Store) DeleteValidator(ctx context.Context, chainID, addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := validatorKey{chainID, addr}
+
+	if _, ok := s.validators[key]; !ok {
+		return store.ErrNotFound
+	}
+
+	delete(s.validators, key)
+
+	return nil
+}
+
 func (s *Store) UpsertChain(ctx context.Context, c *store.Chain) error {
 	now := time.Now().UTC()
 
@@ -0,0 +1,20 @@
+create table build_results
+(
+    chain_id          text        not null,
+    height            bigint      not null,
+    validator_address text        not null,
+    max_bytes         bigint      not null,
+    max_gas           bigint      not null,
+    txs_hash          bytea       not null,
+    signature         bytea       not null,
+    txs               bytea[]     not null,
+    validator_payment text        not null,
+    created_at        timestamptz not null default now(),
+
+    primary key (chain_id, height),
+    foreign key (chain_id, height) references auctions (chain_id, height)
+);
+
+alter table build_results add constraint build_results_chain_id_not_empty check (chain_id != '');
+alter table build_results add constraint build_results_validator_address_not_empty check (validator_address != '');
+alter table build_results add constraint build_results_signature_not_empty check (length(signature) != 0);
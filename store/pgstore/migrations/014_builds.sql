@@ -0,0 +1,20 @@
+create table builds
+(
+    chain_id          text        not null,
+    height            bigint      not null,
+    validator_address text        not null,
+    max_bytes         bigint      not null,
+    max_gas           bigint      not null,
+    used_bytes        bigint      not null,
+    used_gas          bigint      not null,
+    payment_denom     text        not null,
+    validator_payment bigint      not null,
+    mekatek_payment   bigint      not null,
+    txs               jsonb       not null,
+    created_at        timestamptz not null default now(),
+
+    primary key (chain_id, height)
+);
+
+alter table builds add constraint builds_chain_id_not_empty check (chain_id != '');
+alter table builds add constraint builds_validator_address_not_empty check (validator_address != '');
@@ -0,0 +1,4 @@
+alter table builds add column inclusion_state  text not null default 'pending';
+alter table builds add column proposer_address text not null default '';
+
+create index builds_inclusion_state_idx on builds (chain_id, inclusion_state, height);
@@ -0,0 +1 @@
+alter table bids add column rejection_reason text;
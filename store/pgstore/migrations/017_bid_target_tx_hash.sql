@@ -0,0 +1,3 @@
+alter table bids add column target_tx_hash text;
+
+alter table bids add constraint bids_backrun_target_tx_hash check (kind != 'backrun' or target_tx_hash is not null);
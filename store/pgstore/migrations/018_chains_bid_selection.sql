@@ -0,0 +1 @@
+alter table chains add column bid_selection text not null default 'max-revenue';
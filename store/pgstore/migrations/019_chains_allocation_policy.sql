@@ -0,0 +1,9 @@
+alter table chains add column allocation_policy text not null default 'fixed';
+alter table chains add column allocation double precision;
+alter table chains add column validator_allocations jsonb not null default '{}';
+alter table chains add column allocation_tolerance double precision;
+
+alter table chains add constraint chains_allocation_range check (allocation >= 0 and allocation <= 1);
+alter table chains add constraint chains_allocation_tolerance_range check (allocation_tolerance >= 0 and allocation_tolerance <= 1);
+
+alter table auctions add column allocation_tolerance double precision not null default 0.01;
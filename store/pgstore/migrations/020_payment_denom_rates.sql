@@ -0,0 +1,3 @@
+alter table chains add column payment_denom_rates jsonb not null default '{}';
+
+alter table auctions add column payment_denom_rates jsonb not null default '{}';
@@ -0,0 +1,5 @@
+alter table bids add column max_height bigint;
+
+alter table bids add constraint bids_max_height check (max_height is null or max_height >= height);
+
+create index bids_chain_id_max_height_idx on bids (chain_id, max_height) where max_height is not null;
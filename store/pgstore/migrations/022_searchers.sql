@@ -0,0 +1,36 @@
+create table searchers
+(
+    id            uuid        not null primary key,
+    chain_id      text        not null references chains (id),
+    address       text        not null,
+    pub_key_bytes bytea       not null,
+    pub_key_type  text        not null,
+    created_at    timestamptz not null default now(),
+    updated_at    timestamptz not null default now()
+);
+
+alter table searchers add constraint searchers_chain_id_not_empty check (chain_id != '');
+alter table searchers add constraint searchers_address_not_empty check (address != '');
+alter table searchers add constraint searchers_pub_key_bytes_not_empty check (length(pub_key_bytes) != 0);
+alter table searchers add constraint searchers_pub_key_type_not_empty check (pub_key_type != '');
+
+create unique index searchers_chain_id_pub_key_bytes_idx on searchers (chain_id, pub_key_bytes);
+
+create table searcher_challenges
+(
+    id            uuid        not null primary key,
+    chain_id      text        not null references chains (id),
+    pub_key_bytes bytea       not null,
+    pub_key_type  text        not null,
+    challenge     bytea       not null,
+    created_at    timestamptz not null default now()
+);
+
+alter table searcher_challenges add constraint searcher_challenges_chain_id_not_empty check (chain_id != '');
+alter table searcher_challenges add constraint searcher_challenges_pub_key_bytes_not_empty check (length(pub_key_bytes) != 0);
+alter table searcher_challenges add constraint searcher_challenges_pub_key_type_not_empty check (pub_key_type != '');
+alter table searcher_challenges add constraint searcher_challenges_challenge_not_empty check (length(challenge) != 0);
+
+alter table bids add column searcher_id uuid references searchers (id) on delete set null;
+
+create index bids_searcher_id_idx on bids (searcher_id) where searcher_id is not null;
@@ -0,0 +1,2 @@
+-- Auctions keep the address of their validator after it's deregistered.
+alter table auctions drop constraint auctions_validator_address_fkey;
@@ -0,0 +1 @@
+alter table chains add column hedge_delay text not null default '0s';
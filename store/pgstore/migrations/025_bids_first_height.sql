@@ -0,0 +1,3 @@
+alter table bids add column first_height bigint;
+
+alter table bids add constraint bids_first_height check (first_height is null or first_height <= height);
@@ -245,6 +245,12 @@ where
   created_at <= now() - '5 minutes'::interval
 `
 
+const cleanupSearcherChallengesQuery = `
+delete from searcher_challenges
+where
+  created_at <= now() - '5 minutes'::interval
+`
+
 const cleanupAuctionsQuery = `
 with
 old as (
@@ -261,6 +267,13 @@ deleted_bids as (
   where
     bids.chain_id = old.chain_id
     and bids.height = old.height
+),
+deleted_build_results as (
+  delete from build_results
+  using old
+  where
+    build_results.chain_id = old.chain_id
+    and build_results.height = old.height
 )
 delete from auctions
 using old
@@ -279,6 +292,15 @@ func (s *Store) Cleanup(ctx context.Context) error {
 		eztrc.Tracef(ctx, "deleted %d challenges", status.RowsAffected())
 	}
 
+	{
+		status, err := s.db.Exec(ctx, cleanupSearcherChallengesQuery)
+		if err != nil {
+			return fmt.Errorf("cleanup searcher challenges: %w", err)
+		}
+
+		eztrc.Tracef(ctx, "deleted %d searcher challenges", status.RowsAffected())
+	}
+
 	{
 		status, err := s.db.Exec(ctx, cleanupAuctionsQuery)
 		if err != nil {
@@ -301,15 +323,19 @@ insert into bids
 	id,
 	chain_id,
 	height,
+	first_height,
+	max_height,
 	kind,
+	target_tx_hash,
 	txs,
 	mekatek_payment,
 	validator_payment,
 	priority,
 	state,
-	payments
+	payments,
+	searcher_id
 )
-values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+values ($1, $2, $3, $4, nullif($5, 0), $6, nullif($7, ''), $8, $9, $10, $11, $12, $13, $14)
 returning
 	created_at,
 	updated_at
@@ -323,28 +349,42 @@ func (s *Store) InsertBid(ctx context.Context, b *store.Bid) error {
 		}
 	}
 
+	if b.FirstHeight == 0 {
+		b.FirstHeight = b.Height
+	}
+
 	return s.db.QueryRow(ctx, insertBidQuery,
 		b.ID,
 		b.ChainID,
 		b.Height,
+		b.FirstHeight,
+		b.MaxHeight,
 		b.Kind,
+		b.TargetTxHash,
 		b.Txs,
 		b.MekatekPayment,
 		b.ValidatorPayment,
 		b.Priority,
 		b.State,
 		b.Payments,
+		nullUUID(b.SearcherID),
 	).Scan(&b.CreatedAt, &b.UpdatedAt)
 }
 
 const updateBidsQuery = `
 update bids
 set
-	state      = updates.state,
-	updated_at = now()
+	height            = coalesce(updates.height, bids.height),
+	state             = updates.state,
+	rejection_reason  = updates.rejection_reason,
+	priority          = coalesce(updates.priority, bids.priority),
+	mekatek_payment   = coalesce(updates.mekatek_payment, bids.mekatek_payment),
+	validator_payment = coalesce(updates.validator_payment, bids.validator_payment),
+	payments          = coalesce(updates.payments, bids.payments),
+	updated_at        = now()
 from
 	jsonb_to_recordset($1)
-	as updates(id uuid, state text)
+	as updates(id uuid, height bigint, state text, rejection_reason text, priority bigint, mekatek_payment bigint, validator_payment bigint, payments jsonb)
 where
 	bids.id = updates.id
 	and updates.state is not null
@@ -352,14 +392,22 @@ where
 `
 
 func (s *Store) UpdateBids(ctx context.Context, bids ...*store.Bid) error {
+	// Bids across a range of heights are evaluated again by each auction, so
+	// the auction that accepts one also updates its height and payments.
 	type update struct {
-		ID    uuid.UUID      `json:"id"`
-		State store.BidState `json:"state,omitempty"`
+		ID               uuid.UUID       `json:"id"`
+		Height           int64           `json:"height,omitempty"`
+		State            store.BidState  `json:"state,omitempty"`
+		RejectionReason  string          `json:"rejection_reason,omitempty"`
+		Priority         int64           `json:"priority,omitempty"`
+		MekatekPayment   int64           `json:"mekatek_payment,omitempty"`
+		ValidatorPayment int64           `json:"validator_payment,omitempty"`
+		Payments         []store.Payment `json:"payments,omitempty"`
 	}
 
 	updates := make([]update, len(bids))
 	for i, b := range bids {
-		updates[i] = update{b.ID, b.State}
+		updates[i] = update{b.ID, b.Height, b.State, b.RejectionReason, b.Priority, b.MekatekPayment, b.ValidatorPayment, b.Payments}
 	}
 	if _, err := s.db.Exec(ctx, updateBidsQuery, updates); err != nil {
 		return fmt.Errorf("update bids: %w", err)
@@ -373,20 +421,28 @@ select
 	id,
 	chain_id,
 	height,
+	first_height,
+	max_height,
 	kind,
+	target_tx_hash,
 	txs,
 	mekatek_payment,
 	validator_payment,
 	priority,
 	state,
+	rejection_reason,
 	payments,
+	searcher_id,
 	created_at,
 	updated_at
 from
 	bids
 where
 	chain_id = $1
-	and height = $2
+	and (
+		height = $2
+		or (height < $2 and max_height >= $2 and state in ('pending', 'rejected'))
+	)
 order by
 	created_at asc
 `
@@ -400,36 +456,72 @@ func (s *Store) ListBids(ctx context.Context, chainID string, height int64) ([]*
 
 	var bids []*store.Bid
 	for rows.Next() {
-		var (
-			b store.Bid
-			// Nullable types below
-			mekatekPayment   = &b.MekatekPayment
-			validatorPayment = &b.ValidatorPayment
-			priority         = &b.Priority
-			state            pgtype.Text
-			payments         = &b.Payments
-		)
-
-		if err = rows.Scan(
-			&b.ID,
-			&b.ChainID,
-			&b.Height,
-			&b.Kind,
-			&b.Txs,
-			&mekatekPayment,
-			&validatorPayment,
-			&priority,
-			&state,
-			&payments,
-			&b.CreatedAt,
-			&b.UpdatedAt,
-		); err != nil {
+		b, err := scanBid(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan: %w", err)
 		}
 
-		b.State = store.BidState(state.String)
+		bids = append(bids, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scan err: %w", err)
+	}
+
+	return bids, nil
+}
+
+const listEligibleBidsQuery = `
+select
+	id,
+	chain_id,
+	height,
+	first_height,
+	max_height,
+	kind,
+	target_tx_hash,
+	txs,
+	mekatek_payment,
+	validator_payment,
+	priority,
+	state,
+	rejection_reason,
+	payments,
+	searcher_id,
+	created_at,
+	updated_at
+from
+	bids
+where
+	chain_id = $1
+	and coalesce(first_height, height) <= $2
+	and (
+		height = $2
+		or (height < $2 and max_height >= $2 and state in ('pending', 'rejected'))
+		or (height > $2 and max_height >= $2)
+	)
+order by
+	created_at asc
+`
+
+// ListEligibleBids returns the bids that were eligible for the auction at the
+// height, whatever happened to them since. Unlike ListBids, that includes bids
+// across a range of heights that a later auction accepted.
+func (s *Store) ListEligibleBids(ctx context.Context, chainID string, height int64) ([]*store.Bid, error) {
+	rows, err := s.db.Query(ctx, listEligibleBidsQuery, chainID, height)
+	if err != nil {
+		return nil, fmt.Errorf("query rows: %w", err)
+	}
+	defer rows.Close()
+
+	var bids []*store.Bid
+	for rows.Next() {
+		b, err := scanBid(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
 
-		bids = append(bids, &b)
+		bids = append(bids, b)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -439,6 +531,130 @@ func (s *Store) ListBids(ctx context.Context, chainID string, height int64) ([]*
 	return bids, nil
 }
 
+const selectBidQuery = `
+select
+	id,
+	chain_id,
+	height,
+	first_height,
+	max_height,
+	kind,
+	target_tx_hash,
+	txs,
+	mekatek_payment,
+	validator_payment,
+	priority,
+	state,
+	rejection_reason,
+	payments,
+	searcher_id,
+	created_at,
+	updated_at
+from
+	bids
+where
+	id = $1
+`
+
+func (s *Store) SelectBid(ctx context.Context, id string) (*store.Bid, error) {
+	if _, err := uuid.FromString(id); err != nil {
+		return nil, fmt.Errorf("invalid bid ID: %w", store.ErrNotFound)
+	}
+
+	b, err := scanBid(s.db.QueryRow(ctx, selectBidQuery, id))
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return b, nil
+}
+
+const deleteBidQuery = `delete from bids where id = $1`
+
+func (s *Store) DeleteBid(ctx context.Context, id string) error {
+	if _, err := uuid.FromString(id); err != nil {
+		return fmt.Errorf("invalid bid ID: %w", store.ErrNotFound)
+	}
+
+	result, err := s.db.Exec(ctx, deleteBidQuery, id)
+	if err != nil {
+		return fmt.Errorf("execute delete: %w", err)
+	}
+
+	if result.RowsAffected() != 1 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) ReplaceBid(ctx context.Context, oldID string, b *store.Bid) error {
+	return s.Transact(ctx, func(tx store.Store) error {
+		if err := tx.DeleteBid(ctx, oldID); err != nil {
+			return fmt.Errorf("delete old bid: %w", err)
+		}
+
+		b.ID = uuid.Nil // always a new bid
+		if err := tx.InsertBid(ctx, b); err != nil {
+			return fmt.Errorf("insert new bid: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func scanBid(row pgx.Row) (*store.Bid, error) {
+	var (
+		b store.Bid
+		// Nullable types below
+		mekatekPayment   = &b.MekatekPayment
+		validatorPayment = &b.ValidatorPayment
+		priority         = &b.Priority
+		firstHeight      pgtype.Int8
+		maxHeight        pgtype.Int8
+		targetTxHash     pgtype.Text
+		state            pgtype.Text
+		rejectionReason  pgtype.Text
+		payments         = &b.Payments
+		searcherID       uuid.NullUUID
+	)
+
+	if err := row.Scan(
+		&b.ID,
+		&b.ChainID,
+		&b.Height,
+		&firstHeight,
+		&maxHeight,
+		&b.Kind,
+		&targetTxHash,
+		&b.Txs,
+		&mekatekPayment,
+		&validatorPayment,
+		&priority,
+		&state,
+		&rejectionReason,
+		&payments,
+		&searcherID,
+		&b.CreatedAt,
+		&b.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if searcherID.Valid {
+		b.SearcherID = &searcherID.UUID
+	}
+	b.FirstHeight = firstHeight.Int
+	if firstHeight.Status != pgtype.Present { // from before it was stored
+		b.FirstHeight = b.Height
+	}
+	b.MaxHeight = maxHeight.Int
+	b.TargetTxHash = targetTxHash.String
+	b.State = store.BidState(state.String)
+	b.RejectionReason = rejectionReason.String
+
+	return &b, nil
+}
+
 //
 // auctions
 //
@@ -450,14 +666,16 @@ insert into auctions
 	height,
 	validator_address,
 	validator_allocation,
+	allocation_tolerance,
 	validator_payment_address,
 	mekatek_payment_address,
 	payment_denom,
+	payment_denom_rates,
 	finished_at,
 	registered_power,
 	total_power
 )
-values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 on conflict (chain_id, height) do update
 set
 	finished_at = excluded.finished_at
@@ -466,14 +684,21 @@ returning
 `
 
 func (s *Store) UpsertAuction(ctx context.Context, a *store.Auction) error {
+	paymentDenomRates := a.PaymentDenomRates
+	if paymentDenomRates == nil {
+		paymentDenomRates = map[string]float64{} // payment_denom_rates is not null
+	}
+
 	return s.db.QueryRow(ctx, upsertAuctionQuery,
 		a.ChainID,
 		a.Height,
 		a.ValidatorAddress,
 		a.ValidatorAllocation,
+		a.AllocationTolerance,
 		a.ValidatorPaymentAddress,
 		a.MekatekPaymentAddress,
 		a.PaymentDenom,
+		paymentDenomRates,
 		nullTime(a.FinishedAt),
 		a.RegisteredPower,
 		a.TotalPower,
@@ -486,9 +711,11 @@ select
 	height,
 	validator_address,
 	validator_allocation,
+	allocation_tolerance,
 	validator_payment_address,
 	mekatek_payment_address,
 	payment_denom,
+	payment_denom_rates,
 	registered_power,
 	total_power,
 	created_at,
@@ -506,9 +733,11 @@ func (s *Store) SelectAuction(ctx context.Context, chainID string, height int64)
 		&a.Height,
 		&a.ValidatorAddress,
 		&a.ValidatorAllocation,
+		&a.AllocationTolerance,
 		&a.ValidatorPaymentAddress,
 		&a.MekatekPaymentAddress,
 		&a.PaymentDenom,
+		&a.PaymentDenomRates,
 		&a.RegisteredPower,
 		&a.TotalPower,
 		&a.CreatedAt,
@@ -520,6 +749,339 @@ func (s *Store) SelectAuction(ctx context.Context, chainID string, height int64)
 	return &a, nil
 }
 
+// resetAuctionsQuery reopens the auctions of a chain from a height, so they
+// can be built again: their bids are pending, and their build results and
+// builds are gone.
+const resetAuctionsQuery = `
+with
+reset_bids as (
+  update bids
+  set
+    state            = 'pending',
+    rejection_reason = null,
+    updated_at       = now()
+  where
+    chain_id = $1
+    and height >= $2
+    and state <> 'pending'
+),
+deleted_build_results as (
+  delete from build_results
+  where
+    chain_id = $1
+    and height >= $2
+),
+deleted_builds as (
+  delete from builds
+  where
+    chain_id = $1
+    and height >= $2
+)
+update auctions
+set
+  finished_at = null
+where
+  chain_id = $1
+  and height >= $2
+`
+
+func (s *Store) ResetAuctions(ctx context.Context, chainID string, minHeight int64) (int64, error) {
+	status, err := s.db.Exec(ctx, resetAuctionsQuery, chainID, minHeight)
+	if err != nil {
+		return 0, fmt.Errorf("reset auctions: %w", err)
+	}
+
+	return status.RowsAffected(), nil
+}
+
+// deleteAuctionsQuery deletes the auctions of a chain from a height, along
+// with their bids, build results, and builds.
+const deleteAuctionsQuery = `
+with
+deleted_bids as (
+  delete from bids
+  where
+    chain_id = $1
+    and height >= $2
+),
+deleted_build_results as (
+  delete from build_results
+  where
+    chain_id = $1
+    and height >= $2
+),
+deleted_builds as (
+  delete from builds
+  where
+    chain_id = $1
+    and height >= $2
+)
+delete from auctions
+where
+  chain_id = $1
+  and height >= $2
+`
+
+func (s *Store) DeleteAuctions(ctx context.Context, chainID string, minHeight int64) (int64, error) {
+	status, err := s.db.Exec(ctx, deleteAuctionsQuery, chainID, minHeight)
+	if err != nil {
+		return 0, fmt.Errorf("delete auctions: %w", err)
+	}
+
+	return status.RowsAffected(), nil
+}
+
+//
+// build results
+//
+
+const insertBuildResultQuery = `
+insert into build_results
+(
+	chain_id,
+	height,
+	validator_address,
+	max_bytes,
+	max_gas,
+	txs_hash,
+	signature,
+	txs,
+	validator_payment
+)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+returning
+	created_at
+`
+
+func (s *Store) InsertBuildResult(ctx context.Context, r *store.BuildResult) error {
+	return s.db.QueryRow(ctx, insertBuildResultQuery,
+		r.ChainID,
+		r.Height,
+		r.ValidatorAddress,
+		r.MaxBytes,
+		r.MaxGas,
+		r.TxsHash,
+		r.Signature,
+		r.Txs,
+		r.ValidatorPayment,
+	).Scan(&r.CreatedAt)
+}
+
+const selectBuildResultQuery = `
+select
+	chain_id,
+	height,
+	validator_address,
+	max_bytes,
+	max_gas,
+	txs_hash,
+	signature,
+	txs,
+	validator_payment,
+	created_at
+from
+	build_results
+where
+	chain_id = $1 and height = $2
+`
+
+func (s *Store) SelectBuildResult(ctx context.Context, chainID string, height int64) (*store.BuildResult, error) {
+	var r store.BuildResult
+	err := s.db.QueryRow(ctx, selectBuildResultQuery, chainID, height).Scan(
+		&r.ChainID,
+		&r.Height,
+		&r.ValidatorAddress,
+		&r.MaxBytes,
+		&r.MaxGas,
+		&r.TxsHash,
+		&r.Signature,
+		&r.Txs,
+		&r.ValidatorPayment,
+		&r.CreatedAt,
+	)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &r, nil
+}
+
+//
+// builds
+//
+
+const insertBuildQuery = `
+insert into builds
+(
+	chain_id,
+	height,
+	validator_address,
+	max_bytes,
+	max_gas,
+	used_bytes,
+	used_gas,
+	payment_denom,
+	validator_payment,
+	mekatek_payment,
+	txs,
+	inclusion_state,
+	proposer_address
+)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+returning
+	created_at
+`
+
+func (s *Store) InsertBuild(ctx context.Context, b *store.Build) error {
+	txs := b.Txs
+	if txs == nil {
+		txs = []store.BuildTx{} // txs is not null
+	}
+	if b.InclusionState == "" {
+		b.InclusionState = store.BuildInclusionPending
+	}
+	return s.db.QueryRow(ctx, insertBuildQuery,
+		b.ChainID,
+		b.Height,
+		b.ValidatorAddress,
+		b.MaxBytes,
+		b.MaxGas,
+		b.UsedBytes,
+		b.UsedGas,
+		b.PaymentDenom,
+		b.ValidatorPayment,
+		b.MekatekPayment,
+		txs,
+		b.InclusionState,
+		b.ProposerAddress,
+	).Scan(&b.CreatedAt)
+}
+
+const selectBuildQuery = `
+select
+	chain_id,
+	height,
+	validator_address,
+	max_bytes,
+	max_gas,
+	used_bytes,
+	used_gas,
+	payment_denom,
+	validator_payment,
+	mekatek_payment,
+	txs,
+	inclusion_state,
+	proposer_address,
+	created_at
+from
+	builds
+where
+	chain_id = $1 and height = $2
+`
+
+func (s *Store) SelectBuild(ctx context.Context, chainID string, height int64) (*store.Build, error) {
+	b, err := scanBuild(s.db.QueryRow(ctx, selectBuildQuery, chainID, height))
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return b, nil
+}
+
+const listPendingBuildsQuery = `
+select
+	chain_id,
+	height,
+	validator_address,
+	max_bytes,
+	max_gas,
+	used_bytes,
+	used_gas,
+	payment_denom,
+	validator_payment,
+	mekatek_payment,
+	txs,
+	inclusion_state,
+	proposer_address,
+	created_at
+from
+	builds
+where
+	chain_id = $1
+	and inclusion_state = 'pending'
+	and height <= $2
+order by
+	height asc
+limit
+	$3
+`
+
+func (s *Store) ListPendingBuilds(ctx context.Context, chainID string, maxHeight int64, limit int) ([]*store.Build, error) {
+	rows, err := s.db.Query(ctx, listPendingBuildsQuery, chainID, maxHeight, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query rows: %w", err)
+	}
+	defer rows.Close()
+
+	var builds []*store.Build
+	for rows.Next() {
+		b, err := scanBuild(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		builds = append(builds, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return builds, nil
+}
+
+const updateBuildInclusionQuery = `
+update builds
+set
+	inclusion_state  = $3,
+	proposer_address = $4
+where
+	chain_id = $1 and height = $2
+`
+
+func (s *Store) UpdateBuildInclusion(ctx context.Context, b *store.Build) error {
+	result, err := s.db.Exec(ctx, updateBuildInclusionQuery, b.ChainID, b.Height, b.InclusionState, b.ProposerAddress)
+	if err != nil {
+		return fmt.Errorf("execute update: %w", err)
+	}
+
+	if result.RowsAffected() != 1 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func scanBuild(row pgx.Row) (*store.Build, error) {
+	var b store.Build
+	if err := row.Scan(
+		&b.ChainID,
+		&b.Height,
+		&b.ValidatorAddress,
+		&b.MaxBytes,
+		&b.MaxGas,
+		&b.UsedBytes,
+		&b.UsedGas,
+		&b.PaymentDenom,
+		&b.ValidatorPayment,
+		&b.MekatekPayment,
+		&b.Txs,
+		&b.InclusionState,
+		&b.ProposerAddress,
+		&b.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
 //
 // challenges
 //
@@ -608,6 +1170,164 @@ func (s *Store) DeleteChallenge(ctx context.Context, id string) error {
 	return nil
 }
 
+//
+// searchers
+//
+
+const insertSearcherChallengeQuery = `
+insert into searcher_challenges
+(
+	id,
+	chain_id,
+	pub_key_bytes,
+	pub_key_type,
+	challenge
+)
+values ($1, $2, $3, $4, $5)
+returning
+	created_at
+`
+
+func (s *Store) InsertSearcherChallenge(ctx context.Context, c *store.SearcherChallenge) error {
+	if c.ID.IsNil() {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return fmt.Errorf("generate UUID: %w", err)
+		}
+		c.ID = id
+	}
+
+	return s.db.QueryRow(ctx, insertSearcherChallengeQuery,
+		c.ID,
+		c.ChainID,
+		c.PubKeyBytes,
+		c.PubKeyType,
+		c.Challenge,
+	).Scan(&c.CreatedAt)
+}
+
+const selectSearcherChallengeQuery = `
+select
+	id,
+	chain_id,
+	pub_key_bytes,
+	pub_key_type,
+	challenge,
+	created_at
+from
+	searcher_challenges
+where
+	id = $1
+`
+
+func (s *Store) SelectSearcherChallenge(ctx context.Context, id string) (*store.SearcherChallenge, error) {
+	if _, err := uuid.FromString(id); err != nil {
+		return nil, fmt.Errorf("invalid searcher challenge ID: %w", store.ErrNotFound)
+	}
+
+	var c store.SearcherChallenge
+	if err := s.db.QueryRow(ctx, selectSearcherChallengeQuery, id).Scan(
+		&c.ID,
+		&c.ChainID,
+		&c.PubKeyBytes,
+		&c.PubKeyType,
+		&c.Challenge,
+		&c.CreatedAt,
+	); err != nil {
+		return nil, convertError(err)
+	}
+	return &c, nil
+}
+
+const deleteSearcherChallengeQuery = `delete from searcher_challenges where id = $1`
+
+func (s *Store) DeleteSearcherChallenge(ctx context.Context, id string) error {
+	if _, err := uuid.FromString(id); err != nil {
+		return fmt.Errorf("invalid searcher challenge ID: %w", store.ErrNotFound)
+	}
+
+	result, err := s.db.Exec(ctx, deleteSearcherChallengeQuery, id)
+	if err != nil {
+		return fmt.Errorf("execute delete: %w", err)
+	}
+
+	if result.RowsAffected() != 1 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+const upsertSearcherQuery = `
+insert into searchers
+(
+	id,
+	chain_id,
+	address,
+	pub_key_bytes,
+	pub_key_type
+)
+values ($1, $2, $3, $4, $5)
+on conflict (chain_id, pub_key_bytes) do update
+set
+	updated_at = now()
+returning
+	id,
+	created_at,
+	updated_at
+`
+
+// UpsertSearcher keeps the ID of an existing searcher with the same pub key.
+func (s *Store) UpsertSearcher(ctx context.Context, sr *store.Searcher) error {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("generate UUID: %w", err)
+	}
+
+	return s.db.QueryRow(ctx, upsertSearcherQuery,
+		id,
+		sr.ChainID,
+		sr.Address,
+		sr.PubKeyBytes,
+		sr.PubKeyType,
+	).Scan(&sr.ID, &sr.CreatedAt, &sr.UpdatedAt)
+}
+
+const selectSearcherQuery = `
+select
+	id,
+	chain_id,
+	address,
+	pub_key_bytes,
+	pub_key_type,
+	created_at,
+	updated_at
+from
+	searchers
+where
+	id = $1
+`
+
+func (s *Store) SelectSearcher(ctx context.Context, id string) (*store.Searcher, error) {
+	if _, err := uuid.FromString(id); err != nil {
+		return nil, fmt.Errorf("invalid searcher ID: %w", store.ErrNotFound)
+	}
+
+	var sr store.Searcher
+	if err := s.db.QueryRow(ctx, selectSearcherQuery, id).Scan(
+		&sr.ID,
+		&sr.ChainID,
+		&sr.Address,
+		&sr.PubKeyBytes,
+		&sr.PubKeyType,
+		&sr.CreatedAt,
+		&sr.UpdatedAt,
+	); err != nil {
+		return nil, convertError(err)
+	}
+	return &sr, nil
+}
+
 //
 // validators
 //
@@ -730,6 +1450,21 @@ func (s *Store) ListValidators(ctx context.Context, chainID string) ([]*store.Va
 	return vs, nil
 }
 
+const deleteValidatorQuery = `delete from validators where chain_id = $1 and address = $2`
+
+func (s *Store) DeleteValidator(ctx context.Context, chainID, addr string) error {
+	result, err := s.db.Exec(ctx, deleteValidatorQuery, chainID, addr)
+	if err != nil {
+		return fmt.Errorf("execute delete: %w", err)
+	}
+
+	if result.RowsAffected() != 1 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
 //
 // chains
 //
@@ -741,17 +1476,33 @@ insert into chains
 	network,
 	mekatek_payment_address,
 	payment_denom,
+	payment_denom_rates,
 	timeout,
-	node_uris
+	node_uris,
+	bid_selection,
+	allocation_policy,
+	allocation,
+	validator_allocations,
+	allocation_tolerance,
+	retention_time,
+	hedge_delay
 )
-values ($1, $2, $3, $4, $5, $6)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, nullif($13, ''), $14)
 on conflict (id) do update
 set
 	network                 = excluded.network,
 	mekatek_payment_address = excluded.mekatek_payment_address,
 	payment_denom           = excluded.payment_denom,
+	payment_denom_rates     = excluded.payment_denom_rates,
 	timeout                 = excluded.timeout,
 	node_uris               = excluded.node_uris,
+	bid_selection           = excluded.bid_selection,
+	allocation_policy       = excluded.allocation_policy,
+	allocation              = excluded.allocation,
+	validator_allocations   = excluded.validator_allocations,
+	allocation_tolerance    = excluded.allocation_tolerance,
+	retention_time          = excluded.retention_time,
+	hedge_delay             = excluded.hedge_delay,
 	updated_at              = now()
 returning
 	created_at,
@@ -759,13 +1510,31 @@ returning
 `
 
 func (s *Store) UpsertChain(ctx context.Context, c *store.Chain) error {
+	validatorAllocations := c.ValidatorAllocations
+	if validatorAllocations == nil {
+		validatorAllocations = map[string]float64{} // validator_allocations is not null
+	}
+
+	paymentDenomRates := c.PaymentDenomRates
+	if paymentDenomRates == nil {
+		paymentDenomRates = map[string]float64{} // payment_denom_rates is not null
+	}
+
 	return s.db.QueryRow(ctx, upsertChainQuery,
 		c.ID,
 		c.Network,
 		c.MekatekPaymentAddress,
 		c.PaymentDenom,
+		paymentDenomRates,
 		c.Timeout.String(),
 		c.NodeURIs,
+		store.ParseBidSelection(string(c.BidSelection)),
+		store.ParseAllocationPolicy(string(c.AllocationPolicy)),
+		c.Allocation,
+		validatorAllocations,
+		c.AllocationTolerance,
+		interval(c.RetentionTime),
+		c.HedgeDelay.String(),
 	).Scan(&c.CreatedAt, &c.UpdatedAt)
 }
 
@@ -775,8 +1544,16 @@ select
 	network,
 	mekatek_payment_address,
 	payment_denom,
+	payment_denom_rates,
 	timeout,
 	node_uris,
+	bid_selection,
+	allocation_policy,
+	allocation,
+	validator_allocations,
+	allocation_tolerance,
+	coalesce(extract(epoch from retention_time::interval), 0)::bigint,
+	hedge_delay,
 	created_at,
 	updated_at
 from
@@ -792,8 +1569,16 @@ func (s *Store) SelectChain(ctx context.Context, id string) (*store.Chain, error
 		&c.Network,
 		&c.MekatekPaymentAddress,
 		&c.PaymentDenom,
+		&c.PaymentDenomRates,
 		&duration{D: &c.Timeout},
 		&c.NodeURIs,
+		&c.BidSelection,
+		&c.AllocationPolicy,
+		&c.Allocation,
+		&c.ValidatorAllocations,
+		&c.AllocationTolerance,
+		&seconds{D: &c.RetentionTime},
+		&duration{D: &c.HedgeDelay},
 		&c.CreatedAt,
 		&c.UpdatedAt,
 	)
@@ -809,8 +1594,16 @@ select
 	network,
 	mekatek_payment_address,
 	payment_denom,
+	payment_denom_rates,
 	timeout,
 	node_uris,
+	bid_selection,
+	allocation_policy,
+	allocation,
+	validator_allocations,
+	allocation_tolerance,
+	coalesce(extract(epoch from retention_time::interval), 0)::bigint,
+	hedge_delay,
 	created_at,
 	updated_at
 from
@@ -834,8 +1627,16 @@ func (s *Store) ListChains(ctx context.Context) ([]*store.Chain, error) {
 			&c.Network,
 			&c.MekatekPaymentAddress,
 			&c.PaymentDenom,
+			&c.PaymentDenomRates,
 			&duration{D: &c.Timeout},
 			&c.NodeURIs,
+			&c.BidSelection,
+			&c.AllocationPolicy,
+			&c.Allocation,
+			&c.ValidatorAllocations,
+			&c.AllocationTolerance,
+			&seconds{D: &c.RetentionTime},
+			&duration{D: &c.HedgeDelay},
 			&c.CreatedAt,
 			&c.UpdatedAt,
 		); err != nil {
@@ -879,6 +1680,13 @@ func nullTime(t time.Time) *time.Time {
 	return &t
 }
 
+func nullUUID(id *uuid.UUID) uuid.NullUUID {
+	if id == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: *id, Valid: true}
+}
+
 type duration struct{ D *time.Duration }
 
 // Scan implements the Scanner interface.
@@ -899,6 +1707,28 @@ func (v *duration) Scan(value any) error {
 	return nil
 }
 
+// seconds scans a number of seconds into a duration.
+type seconds struct{ D *time.Duration }
+
+// Scan implements the Scanner interface.
+func (v *seconds) Scan(value any) error {
+	n, ok := value.(int64)
+	if !ok {
+		return fmt.Errorf("can't scan %T into seconds", value)
+	}
+	*v.D = time.Duration(n) * time.Second
+	return nil
+}
+
+// interval formats a duration as a Postgres interval, or an empty string for
+// zero.
+func interval(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d seconds", int64(d/time.Second))
+}
+
 func convertError(err error) error {
 	if errors.Is(err, pgx.ErrNoRows) {
 		return store.ErrNotFound
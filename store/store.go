@@ -13,17 +13,39 @@ type Store interface {
 	InsertBid(ctx context.Context, bid *Bid) error
 	UpdateBids(ctx context.Context, bids ...*Bid) error
 	ListBids(ctx context.Context, chainID string, height int64) ([]*Bid, error)
+	ListEligibleBids(ctx context.Context, chainID string, height int64) ([]*Bid, error)
+	SelectBid(ctx context.Context, id string) (*Bid, error)
+	DeleteBid(ctx context.Context, id string) error
+	ReplaceBid(ctx context.Context, oldID string, b *Bid) error
 
 	UpsertAuction(ctx context.Context, a *Auction) error
 	SelectAuction(ctx context.Context, chainID string, height int64) (*Auction, error)
+	ResetAuctions(ctx context.Context, chainID string, minHeight int64) (int64, error)
+	DeleteAuctions(ctx context.Context, chainID string, minHeight int64) (int64, error)
+
+	InsertBuildResult(ctx context.Context, r *BuildResult) error
+	SelectBuildResult(ctx context.Context, chainID string, height int64) (*BuildResult, error)
+
+	InsertBuild(ctx context.Context, b *Build) error
+	SelectBuild(ctx context.Context, chainID string, height int64) (*Build, error)
+	ListPendingBuilds(ctx context.Context, chainID string, maxHeight int64, limit int) ([]*Build, error) // lowest heights first
+	UpdateBuildInclusion(ctx context.Context, b *Build) error
 
 	InsertChallenge(ctx context.Context, c *Challenge) error
 	SelectChallenge(ctx context.Context, id string) (*Challenge, error)
 	DeleteChallenge(ctx context.Context, id string) error
 
+	InsertSearcherChallenge(ctx context.Context, c *SearcherChallenge) error
+	SelectSearcherChallenge(ctx context.Context, id string) (*SearcherChallenge, error)
+	DeleteSearcherChallenge(ctx context.Context, id string) error
+
+	UpsertSearcher(ctx context.Context, s *Searcher) error
+	SelectSearcher(ctx context.Context, id string) (*Searcher, error)
+
 	UpsertValidator(ctx context.Context, v *Validator) error
 	SelectValidator(ctx context.Context, chainID, addr string) (*Validator, error)
 	ListValidators(ctx context.Context, chainID string) ([]*Validator, error)
+	DeleteValidator(ctx context.Context, chainID, addr string) error
 
 	UpsertChain(ctx context.Context, c *Chain) error
 	SelectChain(ctx context.Context, id string) (*Chain, error)
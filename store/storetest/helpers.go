@@ -18,6 +18,7 @@ import (
 const (
 	ChainID              = "test-chain-id"
 	Denom                = "uzen"
+	OtherDenom           = "uother"
 	Network              = "zenith"
 	MekatekPaymentAddr   = "zenith1kwwvsp08xyd9saq84cz4mdl0kyf58hwfzhe9hd"
 	ValidatorPaymentAddr = "zenith1srcq5ngt87ryg2s6zmpr39knpx24jv3y0ud24n"
@@ -34,8 +35,16 @@ func NewChain(t *testing.T, s store.Store) *store.Chain {
 		Network:               Network,
 		MekatekPaymentAddress: addr,
 		PaymentDenom:          Denom,
+		PaymentDenomRates:     map[string]float64{OtherDenom: 10},
 		Timeout:               time.Second,
+		HedgeDelay:            100 * time.Millisecond,
 		NodeURIs:              []string{"http://foo:4566/", "https://bar:4567/baz"},
+		BidSelection:          store.BidSelectionMaxRevenue,
+		AllocationPolicy:      store.AllocationPolicyFixed,
+		Allocation:            store.Float64(0.97),
+		ValidatorAllocations:  map[string]float64{addr: 0.9},
+		AllocationTolerance:   store.Float64(0.01),
+		RetentionTime:         72 * time.Hour,
 	}
 
 	err := s.UpsertChain(context.Background(), c)
@@ -68,6 +77,44 @@ func NewChallenge(t *testing.T, s store.Store, c *store.Chain) *store.Challenge
 	return ch
 }
 
+func NewSearcherChallenge(t *testing.T, s store.Store, c *store.Chain) *store.SearcherChallenge {
+	t.Helper()
+
+	pubKey := tm_crypto_secp256k1.GenPrivKey().PubKey()
+
+	ch := &store.SearcherChallenge{
+		ChainID:     c.ID,
+		PubKeyBytes: pubKey.Bytes(),
+		PubKeyType:  pubKey.Type(),
+		Challenge:   cryptoutil.RandomBytes(32),
+	}
+
+	if err := s.InsertSearcherChallenge(context.Background(), ch); err != nil {
+		t.Fatal(err)
+	}
+
+	return ch
+}
+
+func NewSearcher(t *testing.T, s store.Store, c *store.Chain) *store.Searcher {
+	t.Helper()
+
+	pubKey := tm_crypto_secp256k1.GenPrivKey().PubKey()
+
+	sr := &store.Searcher{
+		ChainID:     c.ID,
+		Address:     GetBech32Addr(t, c.Network, pubKey.Address().Bytes()),
+		PubKeyBytes: pubKey.Bytes(),
+		PubKeyType:  pubKey.Type(),
+	}
+
+	if err := s.UpsertSearcher(context.Background(), sr); err != nil {
+		t.Fatal(err)
+	}
+
+	return sr
+}
+
 func NewValidator(t *testing.T, s store.Store, c *store.Chain) *store.Validator {
 	t.Helper()
 
@@ -100,9 +147,11 @@ func NewAuction(t *testing.T, s store.Store, c *store.Chain, height int64, v *st
 		Height:                  height,
 		ValidatorAddress:        v.Address,
 		ValidatorAllocation:     0.9,
+		AllocationTolerance:     0.01,
 		ValidatorPaymentAddress: v.PaymentAddress,
 		MekatekPaymentAddress:   c.MekatekPaymentAddress,
 		PaymentDenom:            c.PaymentDenom,
+		PaymentDenomRates:       c.PaymentDenomRates,
 		RegisteredPower:         100,
 		TotalPower:              1000,
 	}
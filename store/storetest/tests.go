@@ -5,6 +5,7 @@ import (
 	"errors"
 	"sort"
 	"testing"
+	"time"
 
 	"zenith/store"
 
@@ -44,6 +45,7 @@ func TestStore(t *testing.T, makeStore func(*testing.T) store.Store) {
 		bid2 := NewBid(t, s, chain, auction)
 
 		bid1.State = store.BidStateRejected
+		bid1.RejectionReason = "insufficient funds"
 		bid2.State = store.BidStateAccepted
 
 		err := s.UpdateBids(ctx, bid1, bid2)
@@ -63,6 +65,167 @@ func TestStore(t *testing.T, makeStore func(*testing.T) store.Store) {
 		}
 	})
 
+	t.Run("ListBids range", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+		auction1 := NewAuction(t, s, chain, 1, validator)
+		auction2 := NewAuction(t, s, chain, 2, validator)
+		auction3 := NewAuction(t, s, chain, 3, validator)
+
+		rangeBid := *NewBid(t, s, chain, auction1)
+		if err := s.DeleteBid(ctx, rangeBid.ID.String()); err != nil {
+			t.Fatal(err)
+		}
+		rangeBid.MaxHeight = auction2.Height
+		if err := s.InsertBid(ctx, &rangeBid); err != nil {
+			t.Fatal(err)
+		}
+		bid2 := NewBid(t, s, chain, auction2)
+
+		for _, tc := range []struct {
+			height int64
+			want   []*store.Bid
+		}{
+			{auction1.Height, []*store.Bid{&rangeBid}},
+			{auction2.Height, []*store.Bid{&rangeBid, bid2}},
+			{auction3.Height, nil},
+		} {
+			bids, err := s.ListBids(ctx, chain.ID, tc.height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(bids, tc.want); diff != "" {
+				t.Fatalf("height %d: mismatch: %s", tc.height, diff)
+			}
+		}
+
+		// Once accepted by an auction, the bid belongs to it.
+		rangeBid.Height = auction2.Height
+		rangeBid.State = store.BidStateAccepted
+		rangeBid.ValidatorPayment = 800
+		if err := s.UpdateBids(ctx, &rangeBid); err != nil {
+			t.Fatal(err)
+		}
+
+		ignore := cmpopts.IgnoreFields(store.Bid{}, "UpdatedAt")
+		for _, tc := range []struct {
+			height int64
+			want   []*store.Bid
+		}{
+			{auction1.Height, nil},
+			{auction2.Height, []*store.Bid{&rangeBid, bid2}},
+		} {
+			bids, err := s.ListBids(ctx, chain.ID, tc.height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(bids, tc.want, ignore); diff != "" {
+				t.Fatalf("height %d after accept: mismatch: %s", tc.height, diff)
+			}
+		}
+
+		// But it was still eligible for the earlier auction.
+		for _, tc := range []struct {
+			height int64
+			want   []*store.Bid
+		}{
+			{auction1.Height, []*store.Bid{&rangeBid}},
+			{auction2.Height, []*store.Bid{&rangeBid, bid2}},
+			{auction3.Height, nil},
+		} {
+			bids, err := s.ListEligibleBids(ctx, chain.ID, tc.height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(bids, tc.want, ignore); diff != "" {
+				t.Fatalf("eligible at height %d: mismatch: %s", tc.height, diff)
+			}
+		}
+	})
+
+	t.Run("SelectBid", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+		auction := NewAuction(t, s, chain, 1, validator)
+		bid := NewBid(t, s, chain, auction)
+
+		have, err := s.SelectBid(ctx, bid.ID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := bid
+		if diff := cmp.Diff(have, want); diff != "" {
+			t.Fatalf("mismatch: %s", diff)
+		}
+
+		bogusUUID, _ := uuid.NewV4()
+		if _, err := s.SelectBid(ctx, bogusUUID.String()); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("select bogus bid: want %v, have %v", store.ErrNotFound, err)
+		}
+	})
+
+	t.Run("DeleteBid", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+		auction := NewAuction(t, s, chain, 1, validator)
+		bid1 := NewBid(t, s, chain, auction)
+		bid2 := NewBid(t, s, chain, auction)
+
+		if err := s.DeleteBid(ctx, bid1.ID.String()); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.DeleteBid(ctx, bid1.ID.String()); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("delete deleted bid: want %v, have %v", store.ErrNotFound, err)
+		}
+
+		bids, err := s.ListBids(ctx, chain.ID, auction.Height)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []*store.Bid{bid2}
+		if diff := cmp.Diff(bids, want); diff != "" {
+			t.Fatalf("mismatch: %s", diff)
+		}
+	})
+
+	t.Run("ReplaceBid", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+		auction := NewAuction(t, s, chain, 1, validator)
+		oldBid := NewBid(t, s, chain, auction)
+
+		newBid := *oldBid
+		newBid.Txs = [][]byte{{0x03}}
+		if err := s.ReplaceBid(ctx, oldBid.ID.String(), &newBid); err != nil {
+			t.Fatal(err)
+		}
+
+		if newBid.ID == oldBid.ID {
+			t.Fatalf("replacement bid has the old bid ID")
+		}
+
+		bids, err := s.ListBids(ctx, chain.ID, auction.Height)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []*store.Bid{&newBid}
+		if diff := cmp.Diff(bids, want); diff != "" {
+			t.Fatalf("mismatch: %s", diff)
+		}
+
+		if err := s.ReplaceBid(ctx, oldBid.ID.String(), &newBid); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("replace replaced bid: want %v, have %v", store.ErrNotFound, err)
+		}
+	})
+
 	t.Run("SelectAuction", func(t *testing.T) {
 		s := makeStore(t)
 		chain := NewChain(t, s)
@@ -80,6 +243,267 @@ func TestStore(t *testing.T, makeStore func(*testing.T) store.Store) {
 		}
 	})
 
+	t.Run("ResetAuctions", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+		auction1 := NewAuction(t, s, chain, 1, validator)
+		auction2 := NewAuction(t, s, chain, 2, validator)
+		bid1 := NewBid(t, s, chain, auction1)
+		bid2 := NewBid(t, s, chain, auction2)
+
+		for _, a := range []*store.Auction{auction1, auction2} {
+			finished := *a
+			finished.FinishedAt = time.Now().UTC()
+			if err := s.UpsertAuction(ctx, &finished); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, b := range []*store.Bid{bid1, bid2} {
+			rejected := *b
+			rejected.State, rejected.RejectionReason = store.BidStateRejected, "test"
+			if err := s.UpdateBids(ctx, &rejected); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.InsertBuildResult(ctx, &store.BuildResult{
+			ChainID:          chain.ID,
+			Height:           auction2.Height,
+			ValidatorAddress: validator.Address,
+			TxsHash:          []byte{0x01},
+			Signature:        []byte{0x02},
+			ValidatorPayment: "900" + chain.PaymentDenom,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := s.ResetAuctions(ctx, chain.ID, auction2.Height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := int64(1), n; want != have {
+			t.Errorf("reset auctions: want %d, have %d", want, have)
+		}
+
+		for _, testcase := range []struct {
+			auction      *store.Auction
+			bid          *store.Bid
+			wantFinished bool
+			wantState    store.BidState
+		}{
+			{auction1, bid1, true, store.BidStateRejected},
+			{auction2, bid2, false, store.BidStatePending},
+		} {
+			a, err := s.SelectAuction(ctx, chain.ID, testcase.auction.Height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, have := testcase.wantFinished, !a.FinishedAt.IsZero(); want != have {
+				t.Errorf("auction %d finished: want %v, have %v", a.Height, want, have)
+			}
+
+			b, err := s.SelectBid(ctx, testcase.bid.ID.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, have := testcase.wantState, b.State; want != have {
+				t.Errorf("bid at %d state: want %s, have %s", b.Height, want, have)
+			}
+		}
+
+		if _, err := s.SelectBuildResult(ctx, chain.ID, auction2.Height); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("select reset build result: want %v, have %v", store.ErrNotFound, err)
+		}
+	})
+
+	t.Run("DeleteAuctions", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+		auction1 := NewAuction(t, s, chain, 1, validator)
+		auction2 := NewAuction(t, s, chain, 2, validator)
+		bid1 := NewBid(t, s, chain, auction1)
+		bid2 := NewBid(t, s, chain, auction2)
+
+		n, err := s.DeleteAuctions(ctx, chain.ID, auction2.Height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := int64(1), n; want != have {
+			t.Errorf("delete auctions: want %d, have %d", want, have)
+		}
+
+		if _, err := s.SelectAuction(ctx, chain.ID, auction1.Height); err != nil {
+			t.Errorf("select kept auction: %v", err)
+		}
+		if _, err := s.SelectBid(ctx, bid1.ID.String()); err != nil {
+			t.Errorf("select kept bid: %v", err)
+		}
+		if _, err := s.SelectAuction(ctx, chain.ID, auction2.Height); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("select deleted auction: want %v, have %v", store.ErrNotFound, err)
+		}
+		if _, err := s.SelectBid(ctx, bid2.ID.String()); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("select deleted bid: want %v, have %v", store.ErrNotFound, err)
+		}
+	})
+
+	t.Run("SelectBuildResult", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+		auction := NewAuction(t, s, chain, 1, validator)
+
+		if _, err := s.SelectBuildResult(ctx, chain.ID, auction.Height); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("select missing build result: want %v, have %v", store.ErrNotFound, err)
+		}
+
+		result := &store.BuildResult{
+			ChainID:          chain.ID,
+			Height:           auction.Height,
+			ValidatorAddress: validator.Address,
+			MaxBytes:         1024,
+			MaxGas:           -1,
+			TxsHash:          []byte{0x01, 0x02},
+			Signature:        []byte{0x03, 0x04},
+			Txs:              [][]byte{{0x01}, {0x02}},
+			ValidatorPayment: "900" + chain.PaymentDenom,
+		}
+		if err := s.InsertBuildResult(ctx, result); err != nil {
+			t.Fatal(err)
+		}
+
+		have, err := s.SelectBuildResult(ctx, chain.ID, auction.Height)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := result
+		if diff := cmp.Diff(have, want); diff != "" {
+			t.Fatalf("mismatch: %s", diff)
+		}
+
+		if err := s.InsertBuildResult(ctx, result); err == nil {
+			t.Fatalf("second insert of build result: want error, have none")
+		}
+	})
+
+	t.Run("SelectBuild", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+		auction := NewAuction(t, s, chain, 1, validator)
+		bid := NewBid(t, s, chain, auction)
+
+		if _, err := s.SelectBuild(ctx, chain.ID, auction.Height); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("select missing build: want %v, have %v", store.ErrNotFound, err)
+		}
+
+		build := &store.Build{
+			ChainID:          chain.ID,
+			Height:           auction.Height,
+			ValidatorAddress: validator.Address,
+			MaxBytes:         1024,
+			MaxGas:           -1,
+			UsedBytes:        3,
+			UsedGas:          200,
+			PaymentDenom:     chain.PaymentDenom,
+			ValidatorPayment: 90,
+			MekatekPayment:   10,
+			Txs: []store.BuildTx{
+				{Hash: "AA", Source: store.BuildTxSourceBid, BidID: &bid.ID},
+				{Hash: "BB", Source: store.BuildTxSourceMempool},
+			},
+		}
+		if err := s.InsertBuild(ctx, build); err != nil {
+			t.Fatal(err)
+		}
+
+		have, err := s.SelectBuild(ctx, chain.ID, auction.Height)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := build
+		if diff := cmp.Diff(have, want); diff != "" {
+			t.Fatalf("mismatch: %s", diff)
+		}
+
+		if err := s.InsertBuild(ctx, build); err == nil {
+			t.Fatalf("second insert of build: want error, have none")
+		}
+	})
+
+	t.Run("ListPendingBuilds", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+
+		for _, height := range []int64{3, 1, 2} {
+			if err := s.InsertBuild(ctx, &store.Build{
+				ChainID:          chain.ID,
+				Height:           height,
+				ValidatorAddress: validator.Address,
+				PaymentDenom:     chain.PaymentDenom,
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		checked := &store.Build{
+			ChainID:         chain.ID,
+			Height:          1,
+			InclusionState:  store.BuildInclusionIncluded,
+			ProposerAddress: validator.Address,
+		}
+		if err := s.UpdateBuildInclusion(ctx, checked); err != nil {
+			t.Fatal(err)
+		}
+
+		builds, err := s.ListPendingBuilds(ctx, chain.ID, 2, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want, have := 1, len(builds); want != have {
+			t.Fatalf("pending build count: want %d, have %d", want, have)
+		}
+
+		if want, have := int64(2), builds[0].Height; want != have {
+			t.Fatalf("pending build height: want %d, have %d", want, have)
+		}
+
+		limited, err := s.ListPendingBuilds(ctx, chain.ID, 3, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want, have := 1, len(limited); want != have {
+			t.Fatalf("limited pending build count: want %d, have %d", want, have)
+		}
+
+		if want, have := int64(2), limited[0].Height; want != have {
+			t.Fatalf("limited pending build height: want %d, have %d", want, have)
+		}
+
+		build, err := s.SelectBuild(ctx, chain.ID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want, have := store.BuildInclusionIncluded, build.InclusionState; want != have {
+			t.Fatalf("inclusion state: want %s, have %s", want, have)
+		}
+
+		if want, have := validator.Address, build.ProposerAddress; want != have {
+			t.Fatalf("proposer address: want %s, have %s", want, have)
+		}
+
+		bogus := &store.Build{ChainID: chain.ID, Height: 99, InclusionState: store.BuildInclusionDropped}
+		if err := s.UpdateBuildInclusion(ctx, bogus); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("update missing build: want %v, have %v", store.ErrNotFound, err)
+		}
+	})
+
 	t.Run("SelectChallenge", func(t *testing.T) {
 		s := makeStore(t)
 		chain := NewChain(t, s)
@@ -113,6 +537,95 @@ func TestStore(t *testing.T, makeStore func(*testing.T) store.Store) {
 		}
 	})
 
+	t.Run("SelectSearcherChallenge", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		challenge := NewSearcherChallenge(t, s, chain)
+
+		have, err := s.SelectSearcherChallenge(ctx, challenge.ID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := challenge
+		if diff := cmp.Diff(have, want); diff != "" {
+			t.Fatalf("mismatch: %s", diff)
+		}
+
+		if err := s.DeleteSearcherChallenge(ctx, challenge.ID.String()); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.SelectSearcherChallenge(ctx, challenge.ID.String()); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("select deleted challenge: want %v, have %v", store.ErrNotFound, err)
+		}
+
+		if err := s.DeleteSearcherChallenge(ctx, challenge.ID.String()); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("delete deleted challenge: want %v, have %v", store.ErrNotFound, err)
+		}
+	})
+
+	t.Run("UpsertSearcher", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		searcher := NewSearcher(t, s, chain)
+
+		have, err := s.SelectSearcher(ctx, searcher.ID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := searcher
+		if diff := cmp.Diff(have, want); diff != "" {
+			t.Fatalf("mismatch: %s", diff)
+		}
+
+		again := &store.Searcher{
+			ChainID:     searcher.ChainID,
+			Address:     searcher.Address,
+			PubKeyBytes: searcher.PubKeyBytes,
+			PubKeyType:  searcher.PubKeyType,
+		}
+		if err := s.UpsertSearcher(ctx, again); err != nil {
+			t.Fatal(err)
+		}
+
+		if want, have := searcher.ID, again.ID; want != have {
+			t.Errorf("re-registered searcher ID: want %s, have %s", want, have)
+		}
+
+		bogusUUID, _ := uuid.NewV4()
+		if _, err := s.SelectSearcher(ctx, bogusUUID.String()); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("select bogus searcher: want %v, have %v", store.ErrNotFound, err)
+		}
+	})
+
+	t.Run("SelectBid searcher", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+		auction := NewAuction(t, s, chain, 1, validator)
+		searcher := NewSearcher(t, s, chain)
+
+		bid := NewBid(t, s, chain, auction)
+		signed := *bid
+		signed.ID = uuid.Nil
+		signed.SearcherID = &searcher.ID
+		if err := s.InsertBid(ctx, &signed); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []*store.Bid{bid, &signed} {
+			have, err := s.SelectBid(ctx, want.ID.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(have, want); diff != "" {
+				t.Fatalf("mismatch: %s", diff)
+			}
+		}
+	})
+
 	t.Run("SelectValidator", func(t *testing.T) {
 		s := makeStore(t)
 		chain := NewChain(t, s)
@@ -129,6 +642,25 @@ func TestStore(t *testing.T, makeStore func(*testing.T) store.Store) {
 		}
 	})
 
+	t.Run("DeleteValidator", func(t *testing.T) {
+		s := makeStore(t)
+		chain := NewChain(t, s)
+		validator := NewValidator(t, s, chain)
+		NewAuction(t, s, chain, 1, validator) // auctions outlive their validator
+
+		if err := s.DeleteValidator(ctx, chain.ID, validator.Address); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.SelectValidator(ctx, chain.ID, validator.Address); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("select deleted validator: want %v, have %v", store.ErrNotFound, err)
+		}
+
+		if err := s.DeleteValidator(ctx, chain.ID, validator.Address); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("delete deleted validator: want %v, have %v", store.ErrNotFound, err)
+		}
+	})
+
 	t.Run("ListValidators", func(t *testing.T) {
 		s := makeStore(t)
 		chain := NewChain(t, s)
@@ -2,6 +2,7 @@ package store
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -12,21 +13,114 @@ type Chain struct {
 	ID                    string
 	Network               string
 	PaymentDenom          string
+	PaymentDenomRates     map[string]float64 // other accepted denoms, to PaymentDenom per unit (0 to rely on a price source)
 	MekatekPaymentAddress string
 	Timeout               time.Duration
+	HedgeDelay            time.Duration // before a read is also sent to the next node, 0 disables hedging
 	NodeURIs              []string
+	BidSelection          BidSelection
+	AllocationPolicy      AllocationPolicy
+	Allocation            *float64           // for the fixed policy, and validators without an override, nil for the default
+	ValidatorAllocations  map[string]float64 // validator addr to allocation, for the per-validator policy
+	AllocationTolerance   *float64           // nil for the default
+	RetentionTime         time.Duration      // auctions older than this are deleted by Cleanup, 0 keeps them
 	CreatedAt             time.Time
 	UpdatedAt             time.Time
 }
 
+// Validate checks the chain against the same rules as the constraints of the
+// Postgres store, so that bad input can be rejected before it gets there.
+func (c *Chain) Validate() error {
+	switch {
+	case c.ID == "":
+		return fmt.Errorf("chain ID is empty")
+	case c.Network == "":
+		return fmt.Errorf("network is empty")
+	case c.MekatekPaymentAddress == "":
+		return fmt.Errorf("Mekatek payment address is empty")
+	case c.PaymentDenom == "":
+		return fmt.Errorf("payment denom is empty")
+	case c.Timeout <= 0:
+		return fmt.Errorf("timeout must be positive")
+	case c.HedgeDelay < 0:
+		return fmt.Errorf("hedge delay can't be negative")
+	case c.RetentionTime < 0:
+		return fmt.Errorf("retention time can't be negative")
+	case c.Allocation != nil && !unitInterval(*c.Allocation):
+		return fmt.Errorf("allocation must be between 0 and 1")
+	case c.AllocationTolerance != nil && !unitInterval(*c.AllocationTolerance):
+		return fmt.Errorf("allocation tolerance must be between 0 and 1")
+	}
+	for addr, allocation := range c.ValidatorAllocations {
+		if !unitInterval(allocation) {
+			return fmt.Errorf("allocation of validator %s must be between 0 and 1", addr)
+		}
+	}
+	for _, uri := range c.NodeURIs {
+		if strings.TrimSpace(uri) == "" {
+			return fmt.Errorf("node URI is empty")
+		}
+	}
+	return nil
+}
+
+func unitInterval(f float64) bool {
+	return f >= 0 && f <= 1
+}
+
+// Float64 returns a pointer to f, to set optional fields like Chain.Allocation.
+func Float64(f float64) *float64 {
+	return &f
+}
+
+// AllocationPolicy determines the portion of bid payments which goes to the
+// validator in the auctions of a chain.
+type AllocationPolicy string
+
+const (
+	AllocationPolicyFixed        AllocationPolicy = "fixed"         // Chain.Allocation
+	AllocationPolicyPowerLinear  AllocationPolicy = "power-linear"  // function of registered voting power
+	AllocationPolicyPerValidator AllocationPolicy = "per-validator" // Chain.ValidatorAllocations, else Chain.Allocation
+)
+
+func ParseAllocationPolicy(s string) AllocationPolicy {
+	switch strings.ToLower(s) {
+	case string(AllocationPolicyPowerLinear):
+		return AllocationPolicyPowerLinear
+	case string(AllocationPolicyPerValidator):
+		return AllocationPolicyPerValidator
+	default:
+		return AllocationPolicyFixed
+	}
+}
+
+// BidSelection is the strategy used to pick the winning bids for a block.
+type BidSelection string
+
+const (
+	BidSelectionMaxRevenue BidSelection = "max-revenue" // highest total payment
+	BidSelectionGreedy     BidSelection = "greedy"      // highest priority first
+)
+
+func ParseBidSelection(s string) BidSelection {
+	switch strings.ToLower(s) {
+	case string(BidSelectionGreedy):
+		return BidSelectionGreedy
+	default:
+		return BidSelectionMaxRevenue
+	}
+}
+
 type Auction struct {
 	ChainID                 string
 	Height                  int64
 	ValidatorAddress        string
 	ValidatorAllocation     float64
+	AllocationTolerance     float64
 	ValidatorPaymentAddress string
 	MekatekPaymentAddress   string
 	PaymentDenom            string
+	PaymentDenomRates       map[string]float64 // other accepted denom to PaymentDenom per unit
 	RegisteredPower         int64
 	TotalPower              int64
 	CreatedAt               time.Time
@@ -36,22 +130,48 @@ type Auction struct {
 type Bid struct {
 	ID               uuid.UUID
 	ChainID          string
-	Height           int64
+	Height           int64 // first auction the bid is eligible for, or the one that accepted it
+	FirstHeight      int64 // first auction the bid was eligible for, which stays put when it's accepted
+	MaxHeight        int64 // last height the bid is eligible for, zero means only Height
 	Kind             BidKind
+	TargetTxHash     string // only for backrun bids
 	Txs              [][]byte
 	Priority         int64
 	MekatekPayment   int64
 	ValidatorPayment int64
 	Payments         []Payment
-	State            BidState // the only field that can be user-modified after creation
+	SearcherID       *uuid.UUID // of the searcher that signed the bid, nil if it wasn't signed
+	State            BidState   // the only field that can be user-modified after creation
+	RejectionReason  string     // set along with State, when the bid is rejected or excluded
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
 }
 
+// EligibleFor returns true if the bid was eligible for the auction at height,
+// whatever happened to it since, like Store.ListEligibleBids.
+func (b *Bid) EligibleFor(height int64) bool {
+	firstHeight := b.FirstHeight
+	if firstHeight == 0 {
+		firstHeight = b.Height
+	}
+
+	switch {
+	case firstHeight > height:
+		return false
+	case b.Height == height:
+		return true
+	case b.Height < height: // still eligible, unless an earlier auction accepted it
+		return b.MaxHeight >= height && (b.State == BidStatePending || b.State == BidStateRejected)
+	default: // accepted by a later auction
+		return b.MaxHeight >= height
+	}
+}
+
 type Payment struct {
 	From   string `json:"from"`
 	To     string `json:"to"`
-	Amount int64  `json:"amount"`
+	Denom  string `json:"denom,omitempty"` // empty means the auction's PaymentDenom
+	Amount int64  `json:"amount"`          // in Denom
 }
 
 type BidState string
@@ -60,6 +180,8 @@ const (
 	BidStatePending  BidState = "pending"
 	BidStateAccepted BidState = "accepted"
 	BidStateRejected BidState = "rejected"
+	BidStateIncluded BidState = "included" // accepted, and committed on chain as built
+	BidStateExcluded BidState = "excluded" // accepted, but not committed on chain as built
 )
 
 func ParseBidState(s string) BidState {
@@ -68,6 +190,10 @@ func ParseBidState(s string) BidState {
 		return BidStateAccepted
 	case string(BidStateRejected):
 		return BidStateRejected
+	case string(BidStateIncluded):
+		return BidStateIncluded
+	case string(BidStateExcluded):
+		return BidStateExcluded
 	default:
 		return BidStatePending
 	}
@@ -76,19 +202,85 @@ func ParseBidState(s string) BidState {
 type BidKind string
 
 const (
-	BidKindTop   BidKind = "top"
-	BidKindBlock BidKind = "block"
+	BidKindTop     BidKind = "top"
+	BidKindBlock   BidKind = "block"
+	BidKindBackrun BidKind = "backrun" // immediately after the TargetTxHash mempool tx
 )
 
 func ParseBidKind(s string) BidKind {
 	switch strings.ToLower(s) {
 	case string(BidKindBlock):
 		return BidKindBlock
+	case string(BidKindBackrun):
+		return BidKindBackrun
 	default:
 		return BidKindTop
 	}
 }
 
+// BuildResult is the block returned to a validator for a specific build
+// request. It's stored so that an identical, retried request can be answered
+// with the same block, rather than failing because the auction is finished.
+type BuildResult struct {
+	ChainID          string
+	Height           int64
+	ValidatorAddress string
+	MaxBytes         int64
+	MaxGas           int64
+	TxsHash          []byte
+	Signature        []byte
+	Txs              [][]byte
+	ValidatorPayment string
+	CreatedAt        time.Time
+}
+
+// Build is the audit record of a finished auction: what went into the block
+// returned to the validator, where each transaction came from, and what was
+// paid. Unlike bids and auctions, builds aren't removed by Cleanup, so they
+// can be used to settle disputes long after the auction.
+type Build struct {
+	ChainID          string
+	Height           int64
+	ValidatorAddress string
+	MaxBytes         int64
+	MaxGas           int64
+	UsedBytes        int64
+	UsedGas          int64
+	PaymentDenom     string
+	ValidatorPayment int64
+	MekatekPayment   int64
+	Txs              []BuildTx
+	InclusionState   BuildInclusionState // the only field that can be user-modified after creation
+	ProposerAddress  string              // of the committed block, set with the inclusion state
+	CreatedAt        time.Time
+}
+
+// BuildInclusionState describes how a build relates to the block that was
+// eventually committed on chain at the same height.
+type BuildInclusionState string
+
+const (
+	BuildInclusionPending   BuildInclusionState = "pending"   // not yet checked
+	BuildInclusionIncluded  BuildInclusionState = "included"  // committed exactly as built
+	BuildInclusionReordered BuildInclusionState = "reordered" // all txs committed, but not as built
+	BuildInclusionDropped   BuildInclusionState = "dropped"   // some txs not committed
+	BuildInclusionUnknown   BuildInclusionState = "unknown"   // committed block couldn't be fetched in time
+)
+
+// BuildTx is a single transaction in a build, in block order.
+type BuildTx struct {
+	Hash   string        `json:"hash"`
+	Source BuildTxSource `json:"source"`
+	BidID  *uuid.UUID    `json:"bid_id,omitempty"` // set when source is bid
+}
+
+type BuildTxSource string
+
+const (
+	BuildTxSourceBid     BuildTxSource = "bid"
+	BuildTxSourceMempool BuildTxSource = "mempool"
+)
+
 type Challenge struct {
 	ID               uuid.UUID
 	ChainID          string
@@ -100,6 +292,29 @@ type Challenge struct {
 	CreatedAt        time.Time
 }
 
+// SearcherChallenge is issued to a searcher that wants to register a pub key.
+// The searcher proves it holds the private key by signing the challenge.
+type SearcherChallenge struct {
+	ID          uuid.UUID
+	ChainID     string
+	PubKeyBytes []byte
+	PubKeyType  string
+	Challenge   []byte
+	CreatedAt   time.Time
+}
+
+// Searcher is a registered searcher identity. A searcher is identified by its
+// pub key, which it uses to sign the bids it submits.
+type Searcher struct {
+	ID          uuid.UUID
+	ChainID     string
+	Address     string // account address of the pub key
+	PubKeyBytes []byte
+	PubKeyType  string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
 type Validator struct {
 	ChainID        string
 	Address        string
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"mekapi/trc/eztrc"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"zenith/chain"
@@ -16,7 +18,10 @@ import (
 	sdk_crypto_types "github.com/cosmos/cosmos-sdk/crypto/types"
 	sdk_types "github.com/cosmos/cosmos-sdk/types"
 	sdk_types_bech32 "github.com/cosmos/cosmos-sdk/types/bech32"
+	sdk_types_errors "github.com/cosmos/cosmos-sdk/types/errors"
 	sdk_types_query "github.com/cosmos/cosmos-sdk/types/query"
+	sdk_types_tx "github.com/cosmos/cosmos-sdk/types/tx"
+	sdk_x_authz "github.com/cosmos/cosmos-sdk/x/authz"
 	sdk_x_bank_types "github.com/cosmos/cosmos-sdk/x/bank/types"
 	sdk_x_staking_types "github.com/cosmos/cosmos-sdk/x/staking/types"
 	tm_crypto "github.com/tendermint/tendermint/crypto"
@@ -50,16 +55,54 @@ type Chain struct {
 
 	chainID string
 	clients *rpcClients
+	head    *headSubscription // nil without WithHeads
 }
 
 var _ chain.Chain = (*Chain)(nil)
 
+// ChainOption configures optional parts of a Chain.
+type ChainOption func(*chainConfig)
+
+type chainConfig struct {
+	nodeHealth *chain.NodeHealth
+	hedgeDelay time.Duration
+	heads      *Heads
+}
+
+// WithNodeHealth tracks the health of the chain's nodes in h, which should be
+// shared by every Chain for the same chain ID, so that it outlives them.
+func WithNodeHealth(h *chain.NodeHealth) ChainOption {
+	return func(cfg *chainConfig) { cfg.nodeHealth = h }
+}
+
+// WithHedgeDelay sends reads of the latest height, validator set, and account
+// balances to the next node too, if the first hasn't answered after the delay.
+// The first answer wins. A delay of 0, the default, disables hedging.
+func WithHedgeDelay(d time.Duration) ChainOption {
+	return func(cfg *chainConfig) { cfg.hedgeDelay = d }
+}
+
+// WithHeads takes the latest height from a subscription to the chain's new
+// blocks, kept in heads, while it's healthy, instead of asking a node.
+func WithHeads(heads *Heads) ChainOption {
+	return func(cfg *chainConfig) { cfg.heads = heads }
+}
+
 func NewChain(
 	netConf NetworkConfig,
 	chainID string,
 	rpcAddrs []string,
 	httpClient *http.Client,
+	options ...ChainOption,
 ) (*Chain, error) {
+	var cfg chainConfig
+	for _, option := range options {
+		option(&cfg)
+	}
+	if cfg.nodeHealth == nil {
+		cfg.nodeHealth = chain.NewNodeHealth()
+	}
+
 	if chainID == "" {
 		return nil, fmt.Errorf("chain ID required")
 	}
@@ -77,6 +120,11 @@ func NewChain(
 		clients = append(clients, c)
 	}
 
+	var head *headSubscription
+	if cfg.heads != nil {
+		head = cfg.heads.subscribe(chainID, rpcAddrs)
+	}
+
 	return &Chain{
 		network:             netConf.Network,
 		bech32PrefixAccAddr: netConf.Bech32PrefixAccAddr,
@@ -85,7 +133,8 @@ func NewChain(
 		txConfig:            netConf.TxConfig,
 
 		chainID: chainID,
-		clients: &rpcClients{clients},
+		clients: newRPCClients(cfg.nodeHealth, chainID, rpcAddrs, clients, netConf.StallThreshold, cfg.hedgeDelay),
+		head:    head,
 	}, nil
 }
 
@@ -126,6 +175,20 @@ func (c *Chain) VerifySignature(ctx context.Context, pubKeyType string, pubKeyBy
 	return nil
 }
 
+func (c *Chain) AccountAddress(ctx context.Context, pubKeyType string, pubKeyBytes []byte) (string, error) {
+	pubKey, err := newPubKey(pubKeyType, pubKeyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	addr, err := sdk_types_bech32.ConvertAndEncode(c.bech32PrefixAccAddr, pubKey.Address())
+	if err != nil {
+		return "", fmt.Errorf("encode as Bech32: %w", err)
+	}
+
+	return addr, nil
+}
+
 func (c *Chain) DecodeTransaction(ctx context.Context, txb []byte) (chain.Transaction, error) {
 	defaultTx, defaultErr := c.txConfig.TxDecoder()(txb)
 	if defaultErr == nil {
@@ -158,84 +221,142 @@ func (c *Chain) EncodeTransaction(ctx context.Context, tx chain.Transaction) ([]
 	return txb, nil
 }
 
-func (c *Chain) AccountBalance(ctx context.Context, height int64, addr, denom string) (int64, error) {
-	var accountBalance int64
-
-	if err := c.clients.do(ctx, func(client *tm_rpc_client_http.HTTP) error {
-		req := sdk_x_bank_types.QueryBalanceRequest{
-			Address: addr,
-			Denom:   denom,
-		}
+// CheckTransaction simulates the tx with the node's Simulate query, which
+// runs the ante handler against the latest state, verifying e.g. account
+// sequences and fees, but not signatures. Unlike CheckTx, it doesn't change the
+// node's state, so the same tx can be checked again, e.g. when it's built.
+func (c *Chain) CheckTransaction(ctx context.Context, txb []byte) error {
+	defer func(begin time.Time) {
+		metrics.OpWait("rpc_abci_simulate", time.Since(begin))
+	}(time.Now())
 
-		reqBytes, err := req.Marshal()
-		if err != nil {
-			return fmt.Errorf("marshal query balance request: %w", err)
-		}
+	req := sdk_types_tx.SimulateRequest{TxBytes: txb}
+	reqBytes, err := req.Marshal()
+	if err != nil {
+		return fmt.Errorf("marshal simulate request: %w", err)
+	}
 
-		var (
-			path = "/cosmos.bank.v1beta1.Query/Balance" // what e.g. `osmosisd query bank ...` uses
-			data = reqBytes
-			opts = tm_rpc_client.ABCIQueryOptions{Height: height}
-		)
-		abciResult, err := client.ABCIQueryWithOptions(ctx, path, data, opts)
+	err = c.clients.do(ctx, func(client *tm_rpc_client_http.HTTP) error {
+		res, err := client.ABCIQuery(ctx, "/cosmos.tx.v1beta1.Service/Simulate", reqBytes)
 		if err != nil {
-			return fmt.Errorf("ABCI query: %w", err)
-		}
-
-		if !abciResult.Response.IsOK() {
-			return fmt.Errorf("ABCI result response not OK: codespace %q, code %d, log %q", abciResult.Response.Codespace, abciResult.Response.Code, abciResult.Response.GetLog())
-		}
-
-		var response sdk_x_bank_types.QueryBalanceResponse
-		if err := response.Unmarshal(abciResult.Response.Value); err != nil {
-			return fmt.Errorf("unmarshal query balance response: %w", err)
+			return fmt.Errorf("simulate tx: %w", err)
 		}
 
 		switch {
-		case response.GetBalance() == nil:
-			eztrc.Tracef(ctx, "%s has missing balance", addr)
-		case response.GetBalance().IsNil():
-			eztrc.Tracef(ctx, "%s has nil balance of %s", addr, denom)
-		case response.GetBalance().GetDenom() != denom:
-			eztrc.Tracef(ctx, "%s gave back wrong denom %s", addr, response.Balance.GetDenom())
-		case response.GetBalance().Amount.IsNil() || response.Balance.Amount.IsZero():
-			eztrc.Tracef(ctx, "%s has 0 balance of %s", addr, denom)
+		case res.Response.IsOK():
+			return nil
+		case laterSequence(res.Response.Codespace, res.Response.Code, res.Response.Log):
+			// A later tx of a signer in the same bundle, which can only be
+			// checked after the earlier ones have been committed.
+			eztrc.Tracef(ctx, "check %s: %s", cryptoutil.HashTx(txb), res.Response.Log)
+			return nil
 		default:
-			accountBalance = response.Balance.Amount.Int64()
+			return answer(fmt.Errorf("%w: codespace %q, code %d, log %q", chain.ErrInvalidTx, res.Response.Codespace, res.Response.Code, res.Response.Log))
 		}
+	})
+	if err != nil {
+		eztrc.Tracef(ctx, "check %s: %v", cryptoutil.HashTx(txb), err)
+	}
 
-		return nil
-	}); err != nil {
-		return 0, err
+	return err
+}
+
+var sequenceMismatchRe = regexp.MustCompile(`account sequence mismatch, expected (\d+), got (\d+)`)
+
+// laterSequence is true if the response is a wrong sequence error for a
+// sequence that's after the expected one, rather than one that's been used.
+func laterSequence(codespace string, code uint32, log string) bool {
+	if codespace != sdk_types_errors.ErrWrongSequence.Codespace() || code != sdk_types_errors.ErrWrongSequence.ABCICode() {
+		return false
 	}
+	m := sequenceMismatchRe.FindStringSubmatch(log)
+	if m == nil {
+		return false
+	}
+	expected, _ := strconv.ParseUint(m[1], 10, 64)
+	got, _ := strconv.ParseUint(m[2], 10, 64)
+	return got > expected
+}
 
-	return accountBalance, nil
+func (c *Chain) AccountBalance(ctx context.Context, height int64, addr, denom string) (int64, error) {
+	return hedged(ctx, c.clients, func(ctx context.Context, client *tm_rpc_client_http.HTTP) (int64, error) {
+		return queryBalance(ctx, client, height, addr, denom)
+	})
 }
 
-func (c *Chain) LatestHeight(ctx context.Context) (int64, error) {
-	var latestHeight int64
+func queryBalance(ctx context.Context, client *tm_rpc_client_http.HTTP, height int64, addr, denom string) (int64, error) {
+	req := sdk_x_bank_types.QueryBalanceRequest{
+		Address: addr,
+		Denom:   denom,
+	}
 
-	if err := c.clients.do(ctx, func(client *tm_rpc_client_http.HTTP) error {
-		status, err := client.Status(ctx)
-		if err != nil {
-			return fmt.Errorf("check node status: %w", err)
-		}
+	reqBytes, err := req.Marshal()
+	if err != nil {
+		return 0, fmt.Errorf("marshal query balance request: %w", err)
+	}
 
-		if status.SyncInfo.CatchingUp {
-			return fmt.Errorf("node is catching up")
-		}
+	var (
+		path = "/cosmos.bank.v1beta1.Query/Balance" // what e.g. `osmosisd query bank ...` uses
+		data = reqBytes
+		opts = tm_rpc_client.ABCIQueryOptions{Height: height}
+	)
+	abciResult, err := client.ABCIQueryWithOptions(ctx, path, data, opts)
+	if err != nil {
+		return 0, fmt.Errorf("ABCI query: %w", err)
+	}
 
-		if age := time.Since(status.SyncInfo.LatestBlockTime); age > c.stallThreshold {
-			return fmt.Errorf("node appears stalled: last block was %s ago", age.Truncate(time.Second))
-		}
+	if !abciResult.Response.IsOK() {
+		return 0, answer(fmt.Errorf("ABCI result response not OK: codespace %q, code %d, log %q", abciResult.Response.Codespace, abciResult.Response.Code, abciResult.Response.GetLog()))
+	}
 
-		latestHeight = status.SyncInfo.LatestBlockHeight
-		return nil
-	}); err != nil {
-		return 0, err
+	var response sdk_x_bank_types.QueryBalanceResponse
+	if err := response.Unmarshal(abciResult.Response.Value); err != nil {
+		return 0, fmt.Errorf("unmarshal query balance response: %w", err)
+	}
+
+	switch {
+	case response.GetBalance() == nil:
+		eztrc.Tracef(ctx, "%s has missing balance", addr)
+	case response.GetBalance().IsNil():
+		eztrc.Tracef(ctx, "%s has nil balance of %s", addr, denom)
+	case response.GetBalance().GetDenom() != denom:
+		eztrc.Tracef(ctx, "%s gave back wrong denom %s", addr, response.Balance.GetDenom())
+	case response.GetBalance().Amount.IsNil() || response.Balance.Amount.IsZero():
+		eztrc.Tracef(ctx, "%s has 0 balance of %s", addr, denom)
+	default:
+		return response.Balance.Amount.Int64(), nil
 	}
 
-	return latestHeight, nil
+	return 0, nil
+}
+
+// OnNewHeight sets a func that's called with each new height seen by the new
+// block subscription, e.g. to fetch the validator set into a cache before it's
+// needed. It replaces the func of any previous Chain for the same chain ID, and
+// does nothing without WithHeads.
+func (c *Chain) OnNewHeight(f func(ctx context.Context, height int64)) {
+	if c.head != nil {
+		c.head.setOnNewHeight(f)
+	}
+}
+
+// LatestHeight comes from the new block subscription if it's healthy, and
+// otherwise from the status of a node.
+func (c *Chain) LatestHeight(ctx context.Context) (int64, error) {
+	if c.head != nil {
+		if height, ok := c.head.latest(c.stallThreshold); ok {
+			eztrc.Tracef(ctx, "latest height %d from subscription", height)
+			metrics.LatestHeightsTotal.WithLabelValues(c.chainID, "subscription").Inc()
+			return height, nil
+		}
+		eztrc.Tracef(ctx, "new block subscription unhealthy, asking a node")
+	}
+
+	metrics.LatestHeightsTotal.WithLabelValues(c.chainID, "rpc").Inc()
+
+	return hedged(ctx, c.clients, func(ctx context.Context, client *tm_rpc_client_http.HTTP) (int64, error) {
+		return nodeLatestHeight(ctx, client, c.stallThreshold)
+	})
 }
 
 func (c *Chain) ValidatorSet(ctx context.Context, targetHeight int64) (*chain.ValidatorSet, error) {
@@ -251,19 +372,13 @@ func (c *Chain) ValidatorSet(ctx context.Context, targetHeight int64) (*chain.Va
 		targetHeight = h
 	}
 
-	var validatorSet *chain.ValidatorSet
-	if err := c.clients.do(ctx, func(client *tm_rpc_client_http.HTTP) error {
+	return hedged(ctx, c.clients, func(ctx context.Context, client *tm_rpc_client_http.HTTP) (*chain.ValidatorSet, error) {
 		vs, err := getValidatorSet(ctx, client, c.codec, targetHeight)
 		if err != nil {
-			return fmt.Errorf("get validator set at %d: %w", targetHeight, err)
+			return nil, fmt.Errorf("get validator set at %d: %w", targetHeight, err)
 		}
-		validatorSet = vs
-		return nil
-	}); err != nil {
-		return nil, err
-	}
-
-	return validatorSet, nil
+		return vs, nil
+	})
 }
 
 func (c *Chain) PredictProposer(ctx context.Context, valset *chain.ValidatorSet, height int64) (*chain.Validator, error) {
@@ -311,36 +426,167 @@ func (c *Chain) PredictProposer(ctx context.Context, valset *chain.ValidatorSet,
 	return p, nil
 }
 
-func (c *Chain) GetPayment(ctx context.Context, msg chain.Message, denom string) (src, dst string, amount int64, err error) {
-	send, ok := msg.(*sdk_x_bank_types.MsgSend)
-	if !ok {
-		return "", "", 0, fmt.Errorf("irrelvant msg type %T: %w", msg, chain.ErrNoPayment)
+// maxExecDepth bounds the nesting of authz MsgExec messages that GetPayments
+// will look into.
+const maxExecDepth = 4
+
+func (c *Chain) GetPayments(ctx context.Context, msg chain.Message, denoms []string) ([]chain.Payment, error) {
+	payments, err := getPayments(msg, denoms, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payments) <= 0 {
+		return nil, fmt.Errorf("no amount of denoms in %T: %w", msg, chain.ErrNoPayment)
+	}
+
+	return payments, nil
+}
+
+func getPayments(msg chain.Message, denoms []string, depth int) ([]chain.Payment, error) {
+	switch x := msg.(type) {
+	case *sdk_x_bank_types.MsgSend:
+		var payments []chain.Payment
+		for _, denom := range denoms {
+			if amount, ok := amountOfDenom(x.Amount, denom); ok {
+				payments = append(payments, chain.Payment{From: x.FromAddress, To: x.ToAddress, Denom: denom, Amount: amount})
+			}
+		}
+		return payments, nil
+
+	case *sdk_x_bank_types.MsgMultiSend:
+		var payments []chain.Payment
+		for _, denom := range denoms {
+			payments = append(payments, getMultiSendPayments(x, denom)...)
+		}
+		return payments, nil
+
+	case *sdk_x_authz.MsgExec:
+		if depth >= maxExecDepth {
+			return nil, fmt.Errorf("exec nested deeper than %d: %w", maxExecDepth, chain.ErrNoPayment)
+		}
+
+		msgs, err := x.GetMessages()
+		if err != nil {
+			return nil, fmt.Errorf("get exec messages: %v: %w", err, chain.ErrNoPayment)
+		}
+
+		// SECURITY 🚨 The messages are executed on behalf of their signers, who
+		// are supposed to have granted that to the grantee, which signed the
+		// tx. The grant isn't checked, so anyone could claim payments from
+		// any account with an exec, and only the grantee's own payments count.
+		var payments []chain.Payment
+		for _, msg := range msgs {
+			ps, err := getPayments(msg, denoms, depth+1)
+			if err != nil {
+				continue // other messages may still have payments
+			}
+			for _, p := range ps {
+				if p.From != x.Grantee {
+					continue
+				}
+				payments = append(payments, p)
+			}
+		}
+		return payments, nil
+
+	default:
+		return nil, fmt.Errorf("irrelvant msg type %T: %w", msg, chain.ErrNoPayment)
 	}
+}
 
-	if send.Amount == nil {
-		return "", "", 0, fmt.Errorf("nil amount: %w", chain.ErrNoPayment)
+// getMultiSendPayments pairs the inputs and outputs of a MsgMultiSend. The
+// message doesn't say which input pays which output, so outputs are paid by
+// the inputs in order, which is exact for the usual case of a single input.
+func getMultiSendPayments(msg *sdk_x_bank_types.MsgMultiSend, denom string) []chain.Payment {
+	type transfer struct {
+		addr   string
+		amount int64
 	}
 
-	i := send.Amount.AmountOfNoDenomValidation(denom)
-	if i.IsNil() {
-		return "", "", 0, fmt.Errorf("nil amount of denom: %w", chain.ErrNoPayment)
+	var inputs, outputs []*transfer
+	for _, in := range msg.Inputs {
+		if amount, ok := amountOfDenom(in.Coins, denom); ok {
+			inputs = append(inputs, &transfer{in.Address, amount})
+		}
 	}
-	if !i.IsInt64() {
-		return "", "", 0, fmt.Errorf("invalid amount of denom: %w", chain.ErrNoPayment)
+	for _, out := range msg.Outputs {
+		if amount, ok := amountOfDenom(out.Coins, denom); ok {
+			outputs = append(outputs, &transfer{out.Address, amount})
+		}
 	}
-	if i.IsZero() {
-		return "", "", 0, fmt.Errorf("zero amount of denom: %w", chain.ErrNoPayment)
+
+	var payments []chain.Payment
+	for len(inputs) > 0 && len(outputs) > 0 {
+		in, out := inputs[0], outputs[0]
+
+		amount := in.amount
+		if out.amount < amount {
+			amount = out.amount
+		}
+
+		payments = append(payments, chain.Payment{From: in.addr, To: out.addr, Denom: denom, Amount: amount})
+
+		if in.amount -= amount; in.amount <= 0 {
+			inputs = inputs[1:]
+		}
+		if out.amount -= amount; out.amount <= 0 {
+			outputs = outputs[1:]
+		}
 	}
-	if i.IsNegative() {
-		return "", "", 0, fmt.Errorf("negative amount of denom: %w", chain.ErrNoPayment)
+
+	return payments
+}
+
+// amountOfDenom returns the positive, int64 amount of denom in the coins.
+func amountOfDenom(coins sdk_types.Coins, denom string) (int64, bool) {
+	if coins == nil {
+		return 0, false
+	}
+
+	i := coins.AmountOfNoDenomValidation(denom)
+	if i.IsNil() || !i.IsInt64() || !i.IsPositive() {
+		return 0, false
 	}
 
-	a := i.Int64()
-	if a <= 0 {
-		return "", "", 0, fmt.Errorf("bad amount (%d): %w", a, chain.ErrNoPayment)
+	return i.Int64(), true
+}
+
+func (c *Chain) Block(ctx context.Context, height int64) (*chain.Block, error) {
+	defer func(begin time.Time) {
+		metrics.OpWait("rpc_tm_block", time.Since(begin))
+	}(time.Now())
+
+	var block *chain.Block
+	if err := c.clients.do(ctx, func(client *tm_rpc_client_http.HTTP) error {
+		res, err := client.Block(ctx, &height)
+		switch {
+		case heightUnavailable(err):
+			return answer(fmt.Errorf("get block: %w", err))
+		case err != nil:
+			return fmt.Errorf("get block: %w", err)
+		}
+
+		if res.Block == nil {
+			return answer(fmt.Errorf("no block at height %d", height))
+		}
+
+		txs := make([][]byte, len(res.Block.Data.Txs))
+		for i, tx := range res.Block.Data.Txs {
+			txs[i] = tx
+		}
+
+		block = &chain.Block{
+			Height:          res.Block.Height,
+			ProposerAddress: res.Block.ProposerAddress.String(),
+			Txs:             txs,
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
-	return send.FromAddress, send.ToAddress, a, nil
+	return block, nil
 }
 
 //
@@ -510,7 +756,7 @@ func getStakingValidatorSet(ctx context.Context, client tm_rpc_client.Client, co
 		}
 
 		if !abciResult.Response.IsOK() {
-			return nil, fmt.Errorf("ABCI result response not OK: codespace %q, code %d, log %q", abciResult.Response.Codespace, abciResult.Response.Code, abciResult.Response.GetLog())
+			return nil, answer(fmt.Errorf("ABCI result response not OK: codespace %q, code %d, log %q", abciResult.Response.Codespace, abciResult.Response.Code, abciResult.Response.GetLog()))
 		}
 
 		var response sdk_x_staking_types.QueryValidatorsResponse
@@ -563,7 +809,10 @@ func getTendermintValidatorSet(ctx context.Context, client tm_rpc_client.Client,
 
 	for {
 		res, err := client.Validators(ctx, heightPtr, &page, &perPage)
-		if err != nil {
+		switch {
+		case heightUnavailable(err):
+			return nil, 0, answer(fmt.Errorf("failed to get latest validator set: %w", err))
+		case err != nil:
 			return nil, 0, fmt.Errorf("failed to get latest validator set: %w", err)
 		}
 
@@ -2,9 +2,17 @@ package zcosmos
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"testing"
 	"time"
+	"zenith/chain"
+
+	sdk_types "github.com/cosmos/cosmos-sdk/types"
+	sdk_x_authz "github.com/cosmos/cosmos-sdk/x/authz"
+	sdk_x_bank_types "github.com/cosmos/cosmos-sdk/x/bank/types"
+	sdk_x_staking_types "github.com/cosmos/cosmos-sdk/x/staking/types"
 )
 
 func TestChain_ValidatePaymentAddress(t *testing.T) {
@@ -49,3 +57,128 @@ func TestChain_ValidatePaymentAddress(t *testing.T) {
 		})
 	}
 }
+
+func TestChain_GetPayments(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		netConf = NetworkConfig{
+			Network:             "osmosis",
+			Bech32PrefixAccAddr: "osmo",
+			StallThreshold:      time.Hour,
+		}
+		chainID    = "osmosis-1"
+		rpcAddrs   = []string(nil)
+		httpClient = http.DefaultClient
+		denom      = "uosmo"
+		otherDenom = "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2"
+		coins      = func(amount int64) sdk_types.Coins { return sdk_types.NewCoins(sdk_types.NewInt64Coin(denom, amount)) }
+		send       = &sdk_x_bank_types.MsgSend{FromAddress: "a", ToAddress: "b", Amount: coins(100)}
+	)
+	c, err := NewChain(netConf, chainID, rpcAddrs, httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		grantee     = sdk_types.AccAddress("grantee")
+		granteeSend = &sdk_x_bank_types.MsgSend{FromAddress: grantee.String(), ToAddress: "b", Amount: coins(100)}
+		exec        = sdk_x_authz.NewMsgExec(grantee, []sdk_types.Msg{&sdk_x_staking_types.MsgDelegate{}, granteeSend})
+		nestedExec  = sdk_x_authz.NewMsgExec(grantee, []sdk_types.Msg{&exec})
+		otherExec   = sdk_x_authz.NewMsgExec(sdk_types.AccAddress("other"), []sdk_types.Msg{granteeSend})
+		nestedOther = sdk_x_authz.NewMsgExec(grantee, []sdk_types.Msg{&otherExec})
+		forgedExec  = sdk_x_authz.NewMsgExec(grantee, []sdk_types.Msg{send}) // without a grant from a
+	)
+
+	for _, tc := range []struct {
+		name string
+		msg  chain.Message
+		want []chain.Payment
+	}{
+		{
+			name: "send",
+			msg:  send,
+			want: []chain.Payment{{From: "a", To: "b", Denom: denom, Amount: 100}},
+		},
+		{
+			name: "send unaccepted denom",
+			msg:  &sdk_x_bank_types.MsgSend{FromAddress: "a", ToAddress: "b", Amount: sdk_types.NewCoins(sdk_types.NewInt64Coin("uatom", 100))},
+		},
+		{
+			name: "send many denoms",
+			msg:  &sdk_x_bank_types.MsgSend{FromAddress: "a", ToAddress: "b", Amount: sdk_types.NewCoins(sdk_types.NewInt64Coin(denom, 100), sdk_types.NewInt64Coin(otherDenom, 5))},
+			want: []chain.Payment{{From: "a", To: "b", Denom: denom, Amount: 100}, {From: "a", To: "b", Denom: otherDenom, Amount: 5}},
+		},
+		{
+			name: "multi send single input",
+			msg: &sdk_x_bank_types.MsgMultiSend{
+				Inputs:  []sdk_x_bank_types.Input{{Address: "a", Coins: coins(30)}},
+				Outputs: []sdk_x_bank_types.Output{{Address: "b", Coins: coins(10)}, {Address: "c", Coins: coins(20)}},
+			},
+			want: []chain.Payment{{From: "a", To: "b", Denom: denom, Amount: 10}, {From: "a", To: "c", Denom: denom, Amount: 20}},
+		},
+		{
+			name: "multi send many inputs",
+			msg: &sdk_x_bank_types.MsgMultiSend{
+				Inputs:  []sdk_x_bank_types.Input{{Address: "a", Coins: coins(15)}, {Address: "b", Coins: coins(15)}},
+				Outputs: []sdk_x_bank_types.Output{{Address: "c", Coins: coins(10)}, {Address: "d", Coins: coins(20)}},
+			},
+			want: []chain.Payment{{From: "a", To: "c", Denom: denom, Amount: 10}, {From: "a", To: "d", Denom: denom, Amount: 5}, {From: "b", To: "d", Denom: denom, Amount: 15}},
+		},
+		{
+			name: "exec",
+			msg:  &exec,
+			want: []chain.Payment{{From: grantee.String(), To: "b", Denom: denom, Amount: 100}},
+		},
+		{
+			name: "nested exec",
+			msg:  &nestedExec,
+			want: []chain.Payment{{From: grantee.String(), To: "b", Denom: denom, Amount: 100}},
+		},
+		{
+			name: "exec of another grantee",
+			msg:  &nestedOther,
+		},
+		{
+			name: "exec with forged grant",
+			msg:  &forgedExec,
+		},
+		{
+			name: "irrelevant",
+			msg:  &sdk_x_staking_types.MsgDelegate{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			have, err := c.GetPayments(ctx, tc.msg, []string{denom, otherDenom})
+			switch {
+			case tc.want == nil && !errors.Is(err, chain.ErrNoPayment):
+				t.Fatalf("want %v, have %v", chain.ErrNoPayment, err)
+			case tc.want != nil && err != nil:
+				t.Fatalf("unexpected failure: %v", err)
+			}
+
+			if want, have := fmt.Sprint(tc.want), fmt.Sprint(have); want != have {
+				t.Errorf("want %s, have %s", want, have)
+			}
+		})
+	}
+}
+
+func TestLaterSequence(t *testing.T) {
+	for _, testcase := range []struct {
+		codespace string
+		code      uint32
+		log       string
+		want      bool
+	}{
+		{"sdk", 32, "account sequence mismatch, expected 5, got 6: incorrect account sequence", true},
+		{"sdk", 32, "account sequence mismatch, expected 5, got 4: incorrect account sequence", false},
+		{"sdk", 32, "account sequence mismatch, expected 5, got 5: incorrect account sequence", false},
+		{"sdk", 5, "account sequence mismatch, expected 5, got 6: insufficient funds", false},
+		{"sdk", 32, "signature verification failed", false},
+	} {
+		if want, have := testcase.want, laterSequence(testcase.codespace, testcase.code, testcase.log); want != have {
+			t.Errorf("%s/%d %q: want %v, have %v", testcase.codespace, testcase.code, testcase.log, want, have)
+		}
+	}
+}
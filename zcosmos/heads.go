@@ -0,0 +1,258 @@
+package zcosmos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"zenith/chain"
+	"zenith/metrics"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	tm_rpc_client_http "github.com/tendermint/tendermint/rpc/client/http"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+const (
+	headMaxAge          = 30 * time.Second // without a new block, before the subscription is unhealthy
+	headRetryDelay      = 5 * time.Second  // between subscription attempts
+	headPrefetchTimeout = 30 * time.Second // of the validator set fetch for a new height
+	headSubscriber      = "zenith"
+)
+
+// Heads keeps a subscription to the NewBlock events of each chain, to know its
+// latest height without asking a node. Chains are re-created whenever services
+// are refreshed, so the subscriptions are kept here, and outlive them.
+type Heads struct {
+	logger log.Logger
+
+	mtx  sync.Mutex
+	subs map[string]*headSubscription // by chain ID
+}
+
+func NewHeads(logger log.Logger) *Heads {
+	return &Heads{
+		logger: logger,
+		subs:   map[string]*headSubscription{},
+	}
+}
+
+// subscribe returns the subscription of the chain, starting one if the chain
+// has none, or if its node addrs have changed.
+func (h *Heads) subscribe(chainID string, addrs []string) *headSubscription {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	key := strings.Join(addrs, " ")
+	if s, ok := h.subs[chainID]; ok {
+		if s.key == key {
+			return s
+		}
+		s.stop()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &headSubscription{
+		chainID: chainID,
+		addrs:   addrs,
+		key:     key,
+		logger:  log.With(h.logger, "chain_id", chainID),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go s.run(ctx)
+
+	h.subs[chainID] = s
+	return s
+}
+
+// Retain stops the subscriptions of chains other than the given ones, e.g.
+// after a refresh drops some chains.
+func (h *Heads) Retain(chainIDs []string) {
+	keep := map[string]bool{}
+	for _, id := range chainIDs {
+		keep[id] = true
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for id, s := range h.subs {
+		if !keep[id] {
+			s.stop()
+			delete(h.subs, id)
+		}
+	}
+}
+
+// Close stops every subscription.
+func (h *Heads) Close() {
+	h.Retain(nil)
+}
+
+//
+//
+//
+
+type headSubscription struct {
+	chainID string
+	addrs   []string
+	key     string // addrs, to tell when they change
+	logger  log.Logger
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mtx         sync.Mutex
+	height      int64
+	blockTime   time.Time
+	receivedAt  time.Time
+	up          bool
+	onNewHeight func(ctx context.Context, height int64)
+}
+
+// latest returns the latest height, if the subscription is up, and has seen a
+// block recently, which isn't older than the stall threshold.
+func (s *headSubscription) latest(stallThreshold time.Duration) (int64, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	switch {
+	case !s.up || s.height <= 0:
+		return 0, false
+	case time.Since(s.receivedAt) > headMaxAge:
+		return 0, false
+	case time.Since(s.blockTime) > stallThreshold:
+		return 0, false
+	default:
+		return s.height, true
+	}
+}
+
+// setOnNewHeight sets a func that's called with each new height, e.g. to fetch
+// its validator set into a cache. It replaces any previous func.
+func (s *headSubscription) setOnNewHeight(f func(ctx context.Context, height int64)) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.onNewHeight = f
+}
+
+func (s *headSubscription) stop() {
+	s.cancel()
+	<-s.done
+}
+
+// run subscribes to one node after another, until the context is canceled.
+func (s *headSubscription) run(ctx context.Context) {
+	defer close(s.done)
+	defer metrics.HeadSubscriptionUp.DeleteLabelValues(s.chainID)
+
+	for i := 0; ; i = (i + 1) % len(s.addrs) {
+		addr := s.addrs[i]
+
+		err := s.subscribeOnce(ctx, addr)
+		s.setUp(false)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		level.Warn(s.logger).Log("msg", "new block subscription failed", "node", chain.RedactNodeAddr(addr), "err", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(headRetryDelay):
+		}
+	}
+}
+
+func (s *headSubscription) subscribeOnce(ctx context.Context, addr string) error {
+	client, err := tm_rpc_client_http.New(addr, "/websocket")
+	if err != nil {
+		return fmt.Errorf("create client: %w", err)
+	}
+
+	if err := client.Start(); err != nil {
+		return fmt.Errorf("start websocket: %w", err)
+	}
+	defer client.Stop()
+
+	events, err := client.Subscribe(ctx, headSubscriber, tm_types.EventQueryNewBlock.String())
+	if err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		client.UnsubscribeAll(ctx, headSubscriber)
+	}()
+
+	level.Info(s.logger).Log("msg", "subscribed to new blocks", "node", chain.RedactNodeAddr(addr))
+
+	timer := time.NewTimer(headMaxAge)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-timer.C:
+			return fmt.Errorf("no new block for %s", headMaxAge)
+
+		case ev, ok := <-events:
+			if !ok {
+				return fmt.Errorf("subscription closed")
+			}
+
+			data, ok := ev.Data.(tm_types.EventDataNewBlock)
+			if !ok || data.Block == nil {
+				continue
+			}
+
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(headMaxAge)
+
+			s.newBlock(ctx, data.Block.Height, data.Block.Time)
+		}
+	}
+}
+
+func (s *headSubscription) newBlock(ctx context.Context, height int64, blockTime time.Time) {
+	s.mtx.Lock()
+	if height <= s.height {
+		s.mtx.Unlock()
+		return // e.g. a replay after reconnecting
+	}
+	s.height, s.blockTime, s.receivedAt = height, blockTime, time.Now()
+	s.setUpLocked(true)
+	onNewHeight := s.onNewHeight
+	s.mtx.Unlock()
+
+	if onNewHeight != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(ctx, headPrefetchTimeout)
+			defer cancel()
+			onNewHeight(ctx, height)
+		}()
+	}
+}
+
+func (s *headSubscription) setUp(up bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.setUpLocked(up)
+}
+
+func (s *headSubscription) setUpLocked(up bool) {
+	s.up = up
+	var v float64
+	if up {
+		v = 1
+	}
+	metrics.HeadSubscriptionUp.WithLabelValues(s.chainID).Set(v)
+}
@@ -0,0 +1,141 @@
+package zcosmos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestHeadSubscription_Latest(t *testing.T) {
+	var (
+		now            = time.Now()
+		stallThreshold = time.Minute
+	)
+
+	for _, testcase := range []struct {
+		name       string
+		up         bool
+		height     int64
+		blockTime  time.Time
+		receivedAt time.Time
+		want       bool
+	}{
+		{name: "healthy", up: true, height: 10, blockTime: now, receivedAt: now, want: true},
+		{name: "down", up: false, height: 10, blockTime: now, receivedAt: now, want: false},
+		{name: "no block yet", up: true, height: 0, want: false},
+		{name: "no block received recently", up: true, height: 10, blockTime: now, receivedAt: now.Add(-2 * headMaxAge), want: false},
+		{name: "stalled chain", up: true, height: 10, blockTime: now.Add(-2 * stallThreshold), receivedAt: now, want: false},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			s := &headSubscription{
+				chainID:    "test-chain",
+				up:         testcase.up,
+				height:     testcase.height,
+				blockTime:  testcase.blockTime,
+				receivedAt: testcase.receivedAt,
+			}
+
+			height, ok := s.latest(stallThreshold)
+			if want, have := testcase.want, ok; want != have {
+				t.Fatalf("ok: want %v, have %v", want, have)
+			}
+			if ok && height != testcase.height {
+				t.Errorf("height: want %d, have %d", testcase.height, height)
+			}
+		})
+	}
+}
+
+func TestHeadSubscription_NewBlock(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		heights = make(chan int64, 10)
+		s       = &headSubscription{chainID: "test-chain"}
+	)
+
+	s.setOnNewHeight(func(ctx context.Context, height int64) { heights <- height })
+
+	for _, height := range []int64{10, 9, 10, 11} {
+		s.newBlock(ctx, height, time.Now())
+	}
+
+	if height, ok := s.latest(time.Minute); !ok || height != 11 {
+		t.Errorf("latest: want 11, have %d (ok %v)", height, ok)
+	}
+
+	// Heights that were already seen, e.g. replayed after reconnecting, are
+	// ignored, so the func sees each height once.
+	seen := map[int64]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case height := <-heights:
+			seen[height]++
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for new height %d", i+1)
+		}
+	}
+	select {
+	case height := <-heights:
+		t.Errorf("unexpected new height %d", height)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if seen[10] != 1 || seen[11] != 1 {
+		t.Errorf("new heights: want 10 and 11 once, have %v", seen)
+	}
+}
+
+func TestHeads_Subscribe(t *testing.T) {
+	var (
+		heads    = NewHeads(log.NewNopLogger())
+		addrs    = []string{"http://127.0.0.1:1"} // nothing listens, so the subscriptions keep retrying
+		newAddrs = []string{"http://127.0.0.1:2"}
+	)
+
+	stopped := func(s *headSubscription) bool {
+		select {
+		case <-s.done:
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	}
+
+	a := heads.subscribe("a", addrs)
+	if want, have := a, heads.subscribe("a", addrs); want != have {
+		t.Errorf("same addrs: want the same subscription")
+	}
+
+	// Changing the addrs tears the old subscription down, and starts a new one.
+	a2 := heads.subscribe("a", newAddrs)
+	if a2 == a {
+		t.Fatalf("new addrs: want a new subscription")
+	}
+	if !stopped(a) {
+		t.Errorf("old subscription still running")
+	}
+
+	b := heads.subscribe("b", addrs)
+
+	heads.Retain([]string{"b"})
+	if !stopped(a2) {
+		t.Errorf("subscription of dropped chain still running")
+	}
+	if want, have := b, heads.subscribe("b", addrs); want != have {
+		t.Errorf("retained chain: want the same subscription")
+	}
+
+	// A dropped chain that comes back gets a new subscription.
+	a3 := heads.subscribe("a", newAddrs)
+	if a3 == a2 {
+		t.Errorf("dropped chain: want a new subscription")
+	}
+
+	heads.Close()
+	for _, s := range []*headSubscription{a3, b} {
+		if !stopped(s) {
+			t.Errorf("subscription of %s still running after close", s.chainID)
+		}
+	}
+}
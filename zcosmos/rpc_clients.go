@@ -2,35 +2,129 @@ package zcosmos
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"time"
+	"zenith/chain"
 
-	"github.com/hashicorp/go-multierror"
 	tm_rpc_client_http "github.com/tendermint/tendermint/rpc/client/http"
+	tm_rpc_jsonrpc_types "github.com/tendermint/tendermint/rpc/jsonrpc/types"
 )
 
+// rpcClients makes requests to the full nodes of a chain, healthiest first,
+// and tracks their health in a chain.NodeHealth.
 type rpcClients struct {
-	clients []*tm_rpc_client_http.HTTP
+	clients    []*tm_rpc_client_http.HTTP
+	pool       *chain.NodePool
+	hedgeDelay time.Duration // 0 disables hedging
 }
 
+func newRPCClients(health *chain.NodeHealth, chainID string, addrs []string, clients []*tm_rpc_client_http.HTTP, stallThreshold, hedgeDelay time.Duration) *rpcClients {
+	cs := &rpcClients{clients: clients, hedgeDelay: hedgeDelay}
+	cs.pool = health.Pool(chainID, addrs, func(ctx context.Context, i int) error {
+		_, err := nodeLatestHeight(ctx, cs.clients[i], stallThreshold)
+		return err
+	})
+	return cs
+}
+
+// do calls f with the healthiest node, and then the next, until one succeeds,
+// or answers with an error wrapped by answer, which it returns.
 func (cs *rpcClients) do(ctx context.Context, f func(*tm_rpc_client_http.HTTP) error) error {
-	merr := &multierror.Error{ErrorFormat: func(errs []error) string {
-		strs := make([]string, len(errs))
-		for i := range errs {
-			strs[i] = errs[i].Error()
+	var answerErr error
+	if err := cs.pool.Do(ctx, func(i int) error {
+		err := f(cs.clients[i])
+		if isAnswer(err) {
+			answerErr = err
+			return nil // an answer, don't ask other nodes
 		}
-		return strings.Join(strs, "; ")
-	}}
-
-	for _, c := range cs.clients {
-		err := f(c)
-		// TODO: metrics
-		switch {
-		case err == nil:
-			return nil
-		case err != nil:
-			merr = multierror.Append(merr, err)
+		return err
+	}); err != nil {
+		return err
+	}
+	return answerErr
+}
+
+// hedged calls f with the healthiest node, and if hedging is enabled and it
+// hasn't returned after the hedge delay, with the next node too, and so on. It
+// returns the result of the first call to succeed, or to answer with an error
+// wrapped by answer. Only use it for reads.
+func hedged[T any](ctx context.Context, cs *rpcClients, f func(context.Context, *tm_rpc_client_http.HTTP) (T, error)) (T, error) {
+	var (
+		mtx       sync.Mutex
+		result    T
+		answerErr error
+		done      bool
+	)
+
+	err := cs.pool.DoHedged(ctx, cs.hedgeDelay, func(ctx context.Context, i int) error {
+		v, err := f(ctx, cs.clients[i])
+		if err != nil && !isAnswer(err) {
+			return err
 		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		if !done {
+			result, answerErr, done = v, err, true
+		}
+		return nil
+	})
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if err != nil {
+		return result, err
+	}
+	return result, answerErr
+}
+
+// answerError is an error from the application of a node, rather than from
+// the node itself, e.g. a failed ABCI query, or a height the node doesn't have
+// yet or anymore. The node answered, so it's not counted as a node failure.
+type answerError struct{ err error }
+
+func (e answerError) Error() string { return e.err.Error() }
+func (e answerError) Unwrap() error { return e.err }
+
+// answer marks err as an answer of the node, for do and hedged.
+func answer(err error) error {
+	return answerError{err}
+}
+
+func isAnswer(err error) bool {
+	var a answerError
+	return errors.As(err, &a)
+}
+
+// heightUnavailable is true if the node answered that it doesn't have the
+// requested height, because it's in the future or has been pruned.
+func heightUnavailable(err error) bool {
+	var rpcErr *tm_rpc_jsonrpc_types.RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	return strings.Contains(rpcErr.Data, "must be less than or equal to the current blockchain height") ||
+		strings.Contains(rpcErr.Data, "is not available, lowest height is")
+}
+
+// nodeLatestHeight returns the latest height of the node, or an error wrapping
+// chain.ErrNodeStalled if it's behind the chain.
+func nodeLatestHeight(ctx context.Context, client *tm_rpc_client_http.HTTP, stallThreshold time.Duration) (int64, error) {
+	status, err := client.Status(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("check node status: %w", err)
+	}
+
+	if status.SyncInfo.CatchingUp {
+		return 0, fmt.Errorf("%w: node is catching up", chain.ErrNodeStalled)
+	}
+
+	if age := time.Since(status.SyncInfo.LatestBlockTime); age > stallThreshold {
+		return 0, fmt.Errorf("%w: last block was %s ago", chain.ErrNodeStalled, age.Truncate(time.Second))
 	}
 
-	return merr.ErrorOrNil()
+	return status.SyncInfo.LatestBlockHeight, nil
 }
@@ -8,6 +8,7 @@ import (
 	"io"
 	"mekapi/trc/eztrc"
 	"net/http"
+	"os"
 	"strings"
 	"syscall"
 	"time"
@@ -80,11 +81,24 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 	var (
 		apiAddr                = fs.String("api-addr", cfg.APIAddr, "public API HTTP server address")
 		debugAddr              = fs.String("debug-addr", cfg.DebugAddr, "private debug HTTP server address")
+		debugAuthToken         = fs.String("debug-auth-token", "", "if set, debug requests can authenticate with this bearer token")
+		debugAuthUser          = fs.String("debug-auth-user", "", "if set, debug requests can authenticate with HTTP basic auth, and -debug-auth-password is required")
+		debugAuthPassword      = fs.String("debug-auth-password", "", "HTTP basic auth password for debug requests, required with -debug-auth-user")
+		debugOpenMetrics       = fs.Bool("debug-open-metrics", true, "allow /metrics without debug auth")
 		storeConnStr           = fs.String("store-conn-str", "mem://store", "store connection string")
 		storeCleanupInterval   = fs.Duration("store-cleanup-interval", time.Minute, "how often to clean up the store")
 		storeMetricsInterval   = fs.Duration("store-metrics-interval", 10*time.Second, "how often to update store metrics")
 		serviceRefreshInterval = fs.Duration("service-refresh-interval", 1*time.Minute, "how often to refresh services from chain data in store")
+		inclusionInterval      = fs.Duration("inclusion-interval", 30*time.Second, "how often to check built blocks against committed blocks (0 disables)")
+		decodeCacheSize        = fs.Int("decode-cache-size", 10000, "decoded txs to keep per chain, so each is decoded once per build (0 disables)")
+		headSubscription       = fs.Bool("head-subscription", true, "track the latest height of each chain with a subscription to new blocks, and prefetch their validator sets")
+		priceFile              = fs.String("price-file", "", "JSON file of payment denom conversion rates, by base denom (optional)")
 		overrideNodes          = flagStringSet(fs, "override-node", "if set, override store node URIs, format '<chain ID>:<URI>' (optional, repeatable)")
+		captureDir             = fs.String("capture-dir", "", "if set, capture build requests and the chain state they read to JSONL files in this dir")
+		captureMaxBytes        = fs.Int64("capture-max-bytes", 64<<20, "start a new capture file past this size")
+		captureMaxFiles        = fs.Int("capture-max-files", 10, "remove the oldest capture files past this count (0 keeps all)")
+		rateLimitFile          = fs.String("rate-limit-file", "", "JSON file of API rate limits, by route and chain ID (optional)")
+		replayCaptureFile      = fs.String("replay-capture", "", "if set, replay the build requests in this capture file against the store, print the results, and exit")
 		version                = fs.Bool("version", false, "print version information and exit")
 		logLevel               = fs.String("log-level", "info", "debug, info, warn, error")
 		_                      = fs.String("config", "", "config file")
@@ -102,6 +116,16 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 		return nil
 	}
 
+	debugAuth := debug.Auth{
+		BearerToken: *debugAuthToken,
+		Username:    *debugAuthUser,
+		Password:    *debugAuthPassword,
+		OpenMetrics: *debugOpenMetrics,
+	}
+	if err := debugAuth.Validate(); err != nil {
+		return fmt.Errorf("-debug-auth-user, -debug-auth-password: %w", err)
+	}
+
 	var logger log.Logger
 	{
 		logger = log.NewLogfmtLogger(cfg.Stderr)
@@ -135,6 +159,10 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 		}
 	}
 
+	if *replayCaptureFile != "" {
+		return replayCaptures(ctx, cfg, st, *replayCaptureFile)
+	}
+
 	level.Debug(logger).Log("msg", "listing chains")
 
 	storeChains, err := st.ListChains(ctx)
@@ -180,6 +208,17 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 		}
 	}
 
+	// Chains are re-created on every refresh, so the health of their nodes is
+	// kept here.
+	nodeHealth := chain.NewNodeHealth()
+
+	// The same goes for the subscriptions to their new blocks.
+	var heads *Heads
+	if *headSubscription {
+		heads = NewHeads(log.With(logger, "module", "heads"))
+		defer heads.Close()
+	}
+
 	var manager *block.ServiceManager
 	{
 		allow := func(sc *store.Chain) bool {
@@ -193,6 +232,9 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 				sc.ID,
 				sc.NodeURIs,
 				&http.Client{Timeout: sc.Timeout},
+				WithNodeHealth(nodeHealth),
+				WithHedgeDelay(sc.HedgeDelay),
+				WithHeads(heads),
 			)
 			if err != nil {
 				return nil, fmt.Errorf("create chain: %w", err)
@@ -200,17 +242,36 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 			if err := cc.ValidatePaymentAddress(ctx, sc.MekatekPaymentAddress); err != nil {
 				return nil, fmt.Errorf("payment address (%s): %w", sc.MekatekPaymentAddress, err)
 			}
-			return chain.WithRingCache(cc), nil
+			cached := chain.WithRingCache(cc)
+			cc.OnNewHeight(func(ctx context.Context, height int64) {
+				if _, err := cached.ValidatorSet(ctx, height); err != nil {
+					level.Debug(logger).Log("msg", "prefetch validator set failed", "chain_id", sc.ID, "height", height, "err", err)
+				}
+			})
+			if *decodeCacheSize > 0 {
+				cached = chain.WithDecodeCache(cached, *decodeCacheSize)
+			}
+			if *captureDir != "" {
+				return chain.WithRecording(cached), nil
+			}
+			return cached, nil
+		}
+
+		var options []block.CoreServiceOption
+		if *priceFile != "" {
+			level.Info(logger).Log("price_file", *priceFile)
+			options = append(options, block.WithPriceSource(block.NewFilePriceSource(*priceFile)))
 		}
 
 		create := func(c chain.Chain, s store.Store) block.Service {
-			return block.NewCoreService(c, s)
+			return block.NewCoreService(c, s, options...)
 		}
 
 		m := block.NewServiceManager(st, allow, convert, create)
 		if err := m.Refresh(ctx); err != nil {
 			return fmt.Errorf("initial refresh of services: %w", err)
 		}
+		retainHeads(heads, m)
 
 		manager = m
 	}
@@ -219,11 +280,31 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 		level.Info(logger).Log("msg", "added chain", "chain_id", s.ChainID())
 	}
 
+	var handlerOptions []api.HandlerOption
+	if *captureDir != "" {
+		level.Info(logger).Log("capture_dir", *captureDir, "capture_max_bytes", *captureMaxBytes, "capture_max_files", *captureMaxFiles)
+		sink, err := api.NewFileCaptureSink(*captureDir, *captureMaxBytes, *captureMaxFiles)
+		if err != nil {
+			return fmt.Errorf("create capture sink: %w", err)
+		}
+		defer sink.Close()
+		handlerOptions = append(handlerOptions, api.WithCaptureSink(sink))
+	}
+
+	if *rateLimitFile != "" {
+		level.Info(logger).Log("rate_limit_file", *rateLimitFile)
+		limits, err := api.ReadRateLimitConfig(*rateLimitFile)
+		if err != nil {
+			return fmt.Errorf("read rate limits: %w", err)
+		}
+		handlerOptions = append(handlerOptions, api.WithRateLimiter(api.NewRateLimiter(*limits)))
+	}
+
 	var g run.Group
 
 	{
 		logger := log.With(logger, "module", "api")
-		apiHandler := api.NewHandler(st, manager, logger)
+		apiHandler := api.NewHandler(st, manager, logger, handlerOptions...)
 		server := &http.Server{Handler: apiHandler, Addr: *apiAddr}
 		g.Add(func() error {
 			level.Info(logger).Log("api_addr", *apiAddr)
@@ -237,10 +318,21 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 
 	{
 		logger := log.With(logger, "module", "debug")
-		debugHandler := debug.NewHandler()
+		debugOptions := []debug.HandlerOption{
+			debug.WithAuth(debugAuth),
+			debug.WithHandler("/nodes", nodeHealth),
+		}
+		if debugAuth.Enabled() {
+			// The admin API changes the store, so it's only served with auth.
+			adminHandler := api.NewAdminHandler(st, manager, log.With(logger, "module", "admin"))
+			debugOptions = append(debugOptions, debug.WithHandler("/admin/", adminHandler))
+		} else {
+			level.Info(logger).Log("msg", "admin API disabled, because debug auth isn't configured")
+		}
+		debugHandler := debug.NewHandler(debugOptions...)
 		server := &http.Server{Handler: debugHandler, Addr: *debugAddr}
 		g.Add(func() error {
-			level.Info(logger).Log("debug_addr", *debugAddr)
+			level.Info(logger).Log("debug_addr", *debugAddr, "debug_auth", debugAuth.Enabled())
 			return server.ListenAndServe()
 		}, func(error) {
 			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
@@ -314,6 +406,7 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 						eztrc.Errorf(ctx, "failed: %v", err)
 						level.Error(logger).Log("error", err)
 					}
+					retainHeads(heads, manager)
 					finish()
 				case <-ctx.Done():
 					return ctx.Err()
@@ -324,6 +417,34 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 		})
 	}
 
+	if *inclusionInterval > 0 {
+		logger := log.With(logger, "module", "inclusion_tracking")
+		ctx, cancel := context.WithCancel(ctx)
+		g.Add(func() error {
+			level.Info(logger).Log("interval", *inclusionInterval)
+			ticker := time.NewTicker(*inclusionInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					for _, s := range manager.AllServices() {
+						ctx, finish := eztrc.Create(ctx, "inclusion tracking")
+						eztrc.Tracef(ctx, "chain ID %s", s.ChainID())
+						if err := s.TrackInclusion(ctx); err != nil {
+							eztrc.Errorf(ctx, "failed: %v", err)
+							level.Error(logger).Log("chain_id", s.ChainID(), "error", err)
+						}
+						finish()
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}, func(error) {
+			cancel()
+		})
+	}
+
 	{
 		g.Add(run.SignalHandler(context.Background(), syscall.SIGINT, syscall.SIGTERM))
 	}
@@ -333,6 +454,76 @@ func RunMain(ctx context.Context, cfg RunConfig) error {
 	return g.Run()
 }
 
+// replayCaptures feeds each build request in the capture file through a
+// service backed by the captured chain state, and prints whether it produced
+// the same response as the original request.
+func replayCaptures(ctx context.Context, cfg RunConfig, st store.Store, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("open capture file: %w", err)
+	}
+	defer f.Close()
+
+	captures, err := api.ReadBuildCaptures(f)
+	if err != nil {
+		return fmt.Errorf("read capture file: %w", err)
+	}
+
+	var mismatches int
+	for i, c := range captures {
+		req := c.Request
+		prefix := fmt.Sprintf("%d/%d %s %s/%d", i+1, len(captures), c.Path, req.ChainID, req.Height)
+
+		sc, err := st.SelectChain(ctx, req.ChainID)
+		if err != nil {
+			return fmt.Errorf("%s: get chain: %w", prefix, err)
+		}
+
+		// The codec only decodes txs and finds payments, so its nodes aren't used.
+		codec, err := NewChain(cfg.NetworkConfig, sc.ID, sc.NodeURIs, http.DefaultClient)
+		if err != nil {
+			return fmt.Errorf("%s: create chain: %w", prefix, err)
+		}
+
+		resp, err := api.ReplayBuildCapture(ctx, c, codec, st)
+		switch {
+		case err != nil && c.Error != "":
+			fmt.Fprintf(cfg.Stdout, "%s: same: error\n", prefix)
+		case err != nil:
+			mismatches++
+			fmt.Fprintf(cfg.Stdout, "%s: differs: replay error: %v\n", prefix, err)
+		case c.Response == nil:
+			mismatches++
+			fmt.Fprintf(cfg.Stdout, "%s: differs: original error: %s\n", prefix, c.Error)
+		case !c.SameResponse(resp):
+			mismatches++
+			fmt.Fprintf(cfg.Stdout, "%s: differs: original tx count %d payment %s, replay tx count %d payment %s\n", prefix, len(c.Response.Txs), c.Response.ValidatorPayment, len(resp.Txs), resp.ValidatorPayment)
+		default:
+			fmt.Fprintf(cfg.Stdout, "%s: same: tx count %d payment %s\n", prefix, len(resp.Txs), resp.ValidatorPayment)
+		}
+	}
+
+	fmt.Fprintf(cfg.Stdout, "replayed %d, %d differ\n", len(captures), mismatches)
+
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d replayed builds differ", mismatches, len(captures))
+	}
+	return nil
+}
+
+// retainHeads stops the new block subscriptions of chains that no longer have
+// a service.
+func retainHeads(heads *Heads, manager *block.ServiceManager) {
+	if heads == nil {
+		return
+	}
+	var chainIDs []string
+	for _, s := range manager.AllServices() {
+		chainIDs = append(chainIDs, s.ChainID())
+	}
+	heads.Retain(chainIDs)
+}
+
 func IsSignalError(err error) bool {
 	var (
 		sigErrVal run.SignalError